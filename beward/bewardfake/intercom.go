@@ -0,0 +1,200 @@
+// Package bewardfake implements fake Beward intercom serving door opening and
+// system info API of beward client. It is used to try beward client without
+// real intercom, e.g. served by httptest.NewServer.
+package bewardfake
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bennyharvey/soma/beward"
+)
+
+// Challenge is auth challenge of intercom.
+type Challenge int
+
+const (
+	// BasicChallenge requires basic auth.
+	BasicChallenge Challenge = iota
+	// DigestChallenge requires digest auth without qop, as in RFC 2069.
+	DigestChallenge
+	// DigestQopChallenge requires digest auth with auth qop and opaque.
+	DigestQopChallenge
+)
+
+const (
+	realm  = "intercom"
+	opaque = "5ccc069c403ebaf9f0171e9517f40e41"
+)
+
+// Intercom is fake intercom. Doors are opened by API requests authorized
+// according to challenge, every digest challenge has a new nonce.
+type Intercom struct {
+	username  string
+	password  string
+	challenge Challenge
+
+	nonces       int
+	nonce        string
+	opens        []beward.Door
+	unauthorized int
+	mx           sync.Mutex
+}
+
+func NewIntercom(username, password string, c Challenge) *Intercom {
+	return &Intercom{
+		username:  username,
+		password:  password,
+		challenge: c,
+	}
+}
+
+// Opens returns opened doors in order.
+func (i *Intercom) Opens() []beward.Door {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	return append([]beward.Door{}, i.opens...)
+}
+
+// Unauthorized returns count of requests answered with challenge.
+func (i *Intercom) Unauthorized() int {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	return i.unauthorized
+}
+
+func (i *Intercom) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	i.mx.Lock()
+	defer i.mx.Unlock()
+
+	if !i.authorized(r) {
+		i.unauthorized++
+		w.Header().Set("WWW-Authenticate", i.newChallenge())
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	action := r.URL.Query().Get("action")
+
+	switch r.URL.Path {
+	case "/cgi-bin/intercom_cgi":
+		d := beward.Door(action)
+		if d != beward.MainDoor && d != beward.AltDoor {
+			fmt.Fprintf(w, "Error: bad action %s\r\n", action)
+			return
+		}
+		i.opens = append(i.opens, d)
+		fmt.Fprint(w, "OK\r\n")
+
+	case "/cgi-bin/systeminfo_cgi":
+		if action != "get" {
+			fmt.Fprintf(w, "Error: bad action %s\r\n", action)
+			return
+		}
+		fmt.Fprint(w, "DeviceModel=DS06M\r\nSoftwareVersion=2.0.0\r\n")
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (i *Intercom) newChallenge() string {
+	switch i.challenge {
+	case BasicChallenge:
+		return fmt.Sprintf(`Basic realm="%s"`, realm)
+	case DigestChallenge:
+		i.nonces++
+		i.nonce = md5Hex(strconv.Itoa(i.nonces))
+		return fmt.Sprintf(`Digest realm="%s", nonce="%s"`, realm, i.nonce)
+	default:
+		i.nonces++
+		i.nonce = md5Hex(strconv.Itoa(i.nonces))
+		return fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="%s", algorithm=MD5`,
+			realm, i.nonce, opaque)
+	}
+}
+
+func (i *Intercom) authorized(r *http.Request) bool {
+	if i.challenge == BasicChallenge {
+		username, password, ok := r.BasicAuth()
+		return ok && username == i.username && password == i.password
+	}
+
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Digest ") || i.nonce == "" {
+		return false
+	}
+
+	ps := parseParams(h[len("Digest "):])
+
+	if ps["username"] != i.username || ps["realm"] != realm || ps["nonce"] != i.nonce ||
+		ps["uri"] != r.URL.RequestURI() {
+		return false
+	}
+
+	ha1 := md5Hex(i.username + ":" + realm + ":" + i.password)
+	ha2 := md5Hex(r.Method + ":" + ps["uri"])
+
+	if i.challenge == DigestChallenge {
+		return ps["response"] == md5Hex(ha1+":"+i.nonce+":"+ha2)
+	}
+
+	if ps["qop"] != "auth" || ps["nc"] == "" || ps["cnonce"] == "" || ps["opaque"] != opaque {
+		return false
+	}
+
+	return ps["response"] == md5Hex(ha1+":"+i.nonce+":"+ps["nc"]+":"+ps["cnonce"]+":auth:"+ha2)
+}
+
+// parseParams parses comma separated key=value params with optionally quoted
+// values.
+func parseParams(s string) map[string]string {
+	ps := map[string]string{}
+
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				break
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+
+		ps[key] = value
+	}
+
+	return ps
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
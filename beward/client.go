@@ -0,0 +1,182 @@
+package beward
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Auth is HTTP authentication scheme of intercom API.
+type Auth string
+
+const (
+	// AutoAuth answers basic or digest challenge of intercom.
+	AutoAuth   Auth = ""
+	BasicAuth  Auth = "basic"
+	DigestAuth Auth = "digest"
+)
+
+func (a Auth) Validate() error {
+	switch a {
+	case AutoAuth, BasicAuth, DigestAuth:
+		return nil
+	}
+	return fmt.Errorf("unknown auth %s", a)
+}
+
+// Door is intercom lock output.
+type Door string
+
+const (
+	MainDoor Door = "maindoor"
+	AltDoor  Door = "altdoor"
+)
+
+const (
+	intercomAPIPath   = "/cgi-bin/intercom_cgi"
+	systemInfoAPIPath = "/cgi-bin/systeminfo_cgi"
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Client is Beward intercom HTTP API client. Address is intercom host with
+// optional port and scheme, http is used if scheme is omitted.
+type Client struct {
+	baseURI  string
+	username string
+	password string
+	auth     Auth
+	http     *http.Client
+}
+
+func NewClient(address, username, password string, auth Auth, timeout time.Duration) *Client {
+	baseURI := address
+	if !strings.Contains(baseURI, "://") {
+		baseURI = "http://" + baseURI
+	}
+
+	return &Client{
+		baseURI:  strings.TrimRight(baseURI, "/"),
+		username: username,
+		password: password,
+		auth:     auth,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// get requests API path with query and returns response body. Basic auth is
+// sent with the first request, digest auth answers intercom challenge.
+func (c *Client) get(path string, query url.Values) (string, error) {
+	uri := path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	res, err := c.do(uri, func(req *http.Request) error {
+		if c.auth == BasicAuth {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized && c.auth != BasicAuth {
+		challenge := res.Header.Get("WWW-Authenticate")
+
+		_ = res.Body.Close()
+
+		res, err = c.do(uri, func(req *http.Request) error {
+			return c.answer(req, challenge)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", ErrUnauthorized
+	default:
+		return "", fmt.Errorf("expected 200 status code but got %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("read response body: %w", err)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (c *Client) do(uri string, authorize func(*http.Request) error) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURI+uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new HTTP request: %w", err)
+	}
+
+	err = authorize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP get: %w", err)
+	}
+
+	return res, nil
+}
+
+// answer authorizes request according to challenge of auth scheme.
+func (c *Client) answer(req *http.Request, challenge string) error {
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		if c.auth != AutoAuth {
+			return fmt.Errorf("intercom requires basic auth but %s is configured", c.auth)
+		}
+		req.SetBasicAuth(c.username, c.password)
+		return nil
+	}
+
+	dc, err := parseDigestChallenge(challenge)
+	if err != nil {
+		return fmt.Errorf("parse digest challenge: %w", err)
+	}
+
+	h, err := dc.authorization(c.username, c.password, req.Method, req.URL.RequestURI())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", h)
+
+	return nil
+}
+
+// OpenDoor opens door lock.
+func (c *Client) OpenDoor(d Door) error {
+	body, err := c.get(intercomAPIPath, url.Values{"action": {string(d)}})
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(strings.ToLower(body), "error") {
+		return fmt.Errorf("open %s failed: %s", d, body)
+	}
+
+	return nil
+}
+
+// Ping checks that intercom API responds and accepts credentials.
+func (c *Client) Ping() error {
+	_, err := c.get(systemInfoAPIPath, url.Values{"action": {"get"}})
+	return err
+}
@@ -0,0 +1,110 @@
+package beward_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/beward"
+	"github.com/bennyharvey/soma/beward/bewardfake"
+)
+
+const testTimeout = time.Second
+
+func newTestIntercom(t *testing.T, c bewardfake.Challenge) (*bewardfake.Intercom, string) {
+	t.Helper()
+
+	i := bewardfake.NewIntercom("some_username", "some_password", c)
+
+	s := httptest.NewServer(i)
+	t.Cleanup(s.Close)
+
+	return i, s.URL
+}
+
+func TestClientAuth(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		challenge bewardfake.Challenge
+		auth      beward.Auth
+		ok        bool
+	}{
+		{name: "basic challenge, auto auth", challenge: bewardfake.BasicChallenge, auth: beward.AutoAuth, ok: true},
+		{name: "basic challenge, basic auth", challenge: bewardfake.BasicChallenge, auth: beward.BasicAuth, ok: true},
+		{name: "basic challenge, digest auth", challenge: bewardfake.BasicChallenge, auth: beward.DigestAuth},
+		{name: "digest challenge, auto auth", challenge: bewardfake.DigestChallenge, auth: beward.AutoAuth, ok: true},
+		{name: "digest challenge, digest auth", challenge: bewardfake.DigestChallenge, auth: beward.DigestAuth, ok: true},
+		{name: "digest qop challenge, auto auth", challenge: bewardfake.DigestQopChallenge, auth: beward.AutoAuth, ok: true},
+		{name: "digest qop challenge, digest auth", challenge: bewardfake.DigestQopChallenge, auth: beward.DigestAuth, ok: true},
+		{name: "digest qop challenge, basic auth", challenge: bewardfake.DigestQopChallenge, auth: beward.BasicAuth},
+	} {
+		i, uri := newTestIntercom(t, c.challenge)
+
+		err := beward.NewClient(uri, "some_username", "some_password", c.auth, testTimeout).OpenDoor(beward.MainDoor)
+		if c.ok && err != nil {
+			t.Errorf("%s: open door: %v", c.name, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s: door opened with mismatching auth", c.name)
+		}
+
+		if opens := i.Opens(); c.ok != (len(opens) == 1) {
+			t.Errorf("%s: got opens %v", c.name, opens)
+		}
+	}
+}
+
+func TestClientBasicAuthIsSentFirst(t *testing.T) {
+	i, uri := newTestIntercom(t, bewardfake.BasicChallenge)
+
+	err := beward.NewClient(uri, "some_username", "some_password", beward.BasicAuth, testTimeout).Ping()
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	if i.Unauthorized() != 0 {
+		t.Fatalf("got %d challenges, want basic auth without challenge", i.Unauthorized())
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	for _, challenge := range []bewardfake.Challenge{
+		bewardfake.BasicChallenge,
+		bewardfake.DigestChallenge,
+		bewardfake.DigestQopChallenge,
+	} {
+		i, uri := newTestIntercom(t, challenge)
+
+		err := beward.NewClient(uri, "some_username", "other_password", beward.AutoAuth, testTimeout).Ping()
+		if !errors.Is(err, beward.ErrUnauthorized) {
+			t.Errorf("challenge %d: got error %v, want %v", challenge, err, beward.ErrUnauthorized)
+		}
+
+		if i.Unauthorized() != 2 {
+			t.Errorf("challenge %d: got %d challenges, want the only answer", challenge, i.Unauthorized())
+		}
+	}
+}
+
+func TestClientOpenDoor(t *testing.T) {
+	i, uri := newTestIntercom(t, bewardfake.DigestQopChallenge)
+
+	c := beward.NewClient(uri, "some_username", "some_password", beward.AutoAuth, testTimeout)
+
+	for _, d := range []beward.Door{beward.MainDoor, beward.AltDoor} {
+		err := c.OpenDoor(d)
+		if err != nil {
+			t.Fatalf("open %s: %v", d, err)
+		}
+	}
+
+	if opens := i.Opens(); len(opens) != 2 || opens[0] != beward.MainDoor || opens[1] != beward.AltDoor {
+		t.Fatalf("got opens %v, want main door and alternate door", opens)
+	}
+
+	err := c.OpenDoor("otherdoor")
+	if err == nil {
+		t.Fatal("door opened despite error response")
+	}
+}
@@ -0,0 +1,137 @@
+package beward
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// digestChallenge is WWW-Authenticate digest challenge, only MD5 algorithm
+// and auth qop are supported.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+func parseDigestChallenge(header string) (digestChallenge, error) {
+	const prefix = "Digest "
+
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return digestChallenge{}, errors.New("not digest challenge")
+	}
+
+	var dc digestChallenge
+
+	for _, param := range splitDigestParams(header[len(prefix):]) {
+		eq := strings.IndexByte(param, '=')
+		if eq < 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(param[:eq]))
+		value := strings.Trim(strings.TrimSpace(param[eq+1:]), `"`)
+
+		switch key {
+		case "realm":
+			dc.realm = value
+		case "nonce":
+			dc.nonce = value
+		case "opaque":
+			dc.opaque = value
+		case "algorithm":
+			dc.algorithm = value
+		case "qop":
+			for _, qop := range strings.Split(value, ",") {
+				if strings.TrimSpace(qop) == "auth" {
+					dc.qop = "auth"
+				}
+			}
+			if dc.qop == "" {
+				return digestChallenge{}, fmt.Errorf("unsupported qop %s", value)
+			}
+		}
+	}
+
+	if dc.nonce == "" {
+		return digestChallenge{}, errors.New("no nonce")
+	}
+
+	if dc.algorithm != "" && !strings.EqualFold(dc.algorithm, "MD5") {
+		return digestChallenge{}, fmt.Errorf("unsupported algorithm %s", dc.algorithm)
+	}
+
+	return dc, nil
+}
+
+// splitDigestParams splits comma separated params, commas inside quoted
+// values are kept.
+func splitDigestParams(s string) []string {
+	var (
+		params []string
+		quoted bool
+		start  int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(params, s[start:])
+}
+
+// authorization returns Authorization header value for request of method to
+// uri answering the challenge.
+func (dc digestChallenge) authorization(username, password, method, uri string) (string, error) {
+	ha1 := md5Hex(username + ":" + dc.realm + ":" + password)
+	ha2 := md5Hex(method + ":" + uri)
+
+	h := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`,
+		username, dc.realm, dc.nonce, uri)
+
+	if dc.qop == "" {
+		h += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+dc.nonce+":"+ha2))
+	} else {
+		cnonceBytes := make([]byte, 8)
+
+		_, err := rand.Read(cnonceBytes)
+		if err != nil {
+			return "", fmt.Errorf("generate cnonce: %w", err)
+		}
+
+		const nc = "00000001"
+
+		cnonce := hex.EncodeToString(cnonceBytes)
+
+		h += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s", response="%s"`, dc.qop, nc, cnonce,
+			md5Hex(ha1+":"+dc.nonce+":"+nc+":"+cnonce+":"+dc.qop+":"+ha2))
+	}
+
+	if dc.opaque != "" {
+		h += fmt.Sprintf(`, opaque="%s"`, dc.opaque)
+	}
+
+	if dc.algorithm != "" {
+		h += ", algorithm=" + dc.algorithm
+	}
+
+	return h, nil
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,6 @@
+package beward
+
+// NotifyAddr returns address notifications are received at.
+func (po *PassageOpener) NotifyAddr() string {
+	return po.server.Addr
+}
@@ -1,51 +1,218 @@
 package beward
 
 import (
-	"soma/entity"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
 	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
 )
 
-// PassageOpener passge opener
+func init() {
+	passage.Register(entity.Beward, newPassageOpener)
+}
+
+const (
+	defaultTimeout = 5 * time.Second
+
+	// Paths of intercom HTTP notifications, intercom event actions should
+	// request them at notify_bind_addr with GET method.
+	DoorbellNotificationPath = "/doorbell"
+	CallNotificationPath     = "/call"
+
+	// NotificationTokenParam is query parameter of notification token.
+	NotificationTokenParam = "token"
+
+	controllerEventsBufferSize = 16
+)
+
+type options struct {
+	Username       string `yaml:"username"`
+	Password       string `yaml:"password"`
+	Auth           Auth   `yaml:"auth"`
+	Timeout        string `yaml:"timeout"`
+	NotifyBindAddr string `yaml:"notify_bind_addr"`
+	NotifyToken    string `yaml:"notify_token"`
+}
+
+func newPassageOpener(c passage.Config) (passage.Opener, error) {
+	var o options
+
+	err := c.DecodeOptions(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.Auth.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+
+	if o.Timeout != "" {
+		timeout, err = time.ParseDuration(o.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout parse: %w", err)
+		}
+	}
+
+	return NewPassageOpener(NewClient(c.Address, o.Username, o.Password, o.Auth, timeout), c.Direction,
+		o.NotifyBindAddr, o.NotifyToken)
+}
+
+// PassageOpener opens main intercom door for in direction and alternate one
+// for out direction. With non empty notifyBindAddr it receives intercom
+// doorbell and call HTTP notifications and reports them as controller events.
+// Notifications must carry notifyToken in query if it is not empty.
 type PassageOpener struct {
-	BaseURI   string
-	Direction entity.Direction
-	lastOpen  time.Time
+	client      *Client
+	direction   entity.Direction
+	notifyToken string
+
+	lastOpen   time.Time
+	lastOpenMx sync.Mutex
+
+	controllerEvents chan entity.ControllerEvent
+	server           *http.Server
+
+	log *logrus.Entry
+	wg  sync.WaitGroup
 }
 
-// NewPassageOpener passage opener
-func NewPassageOpener(baseURI string, direction entity.Direction) *PassageOpener {
-	return &PassageOpener{
-		BaseURI:   baseURI,
-		Direction: direction,
+func NewPassageOpener(c *Client, direction entity.Direction, notifyBindAddr, notifyToken string) (
+	*PassageOpener, error) {
+
+	po := &PassageOpener{
+		client:      c,
+		direction:   direction,
+		notifyToken: notifyToken,
+		log:         logrus.WithField("subsystem", "beward_passage_opener"),
+	}
+
+	if notifyBindAddr == "" {
+		return po, nil
+	}
+
+	l, err := net.Listen("tcp", notifyBindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen notifications: %w", err)
 	}
+
+	po.controllerEvents = make(chan entity.ControllerEvent, controllerEventsBufferSize)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(DoorbellNotificationPath, po.notificationHandler(entity.ControllerDoorbell))
+	mux.HandleFunc(CallNotificationPath, po.notificationHandler(entity.ControllerCall))
+
+	po.server = &http.Server{Addr: l.Addr().String(), Handler: mux}
+
+	po.wg.Add(1)
+	go func() {
+		defer po.wg.Done()
+
+		err := po.server.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			po.log.WithError(err).Error("failed to serve notifications")
+		}
+	}()
+
+	return po, nil
 }
 
-const (
-	openAPIPath = `/cgi-bin/intercom_cgi?user=admin&pwd=admin&action=maindoor`
+// Close stops receiving notifications.
+func (po *PassageOpener) Close() error {
+	if po.server == nil {
+		return nil
+	}
 
-	inDirectionNum  = "0"
-	outDirectionNum = "1"
-)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
 
-// OpenPassage opens passge
-func (po *PassageOpener) OpenPassage() error {
-	
-	// res, err := http.Get("http://" + po.BaseURI + openAPIPath)
-	// if err != nil {
-	// 	return fmt.Errorf("HTTP request: %w", err)
-	// }
+	err := po.server.Shutdown(ctx)
+	po.wg.Wait()
 
-	// if res.StatusCode != 200 {
-	// 	return fmt.Errorf("expected 200 status code but got %d",
-	// 		res.StatusCode)
-	// }
+	close(po.controllerEvents)
+
+	return err
+}
+
+func (po *PassageOpener) door() Door {
+	if po.direction == entity.Out {
+		return AltDoor
+	}
+	return MainDoor
+}
 
+func (po *PassageOpener) OpenPassage() error {
+	err := po.client.OpenDoor(po.door())
+	if err != nil {
+		return err
+	}
+
+	po.lastOpenMx.Lock()
 	po.lastOpen = time.Now()
+	po.lastOpenMx.Unlock()
 
 	return nil
 }
 
-// LastOpenTime lot
 func (po *PassageOpener) LastOpenTime() time.Time {
+	po.lastOpenMx.Lock()
+	defer po.lastOpenMx.Unlock()
 	return po.lastOpen
 }
+
+func (po *PassageOpener) Ping() error {
+	return po.client.Ping()
+}
+
+// ControllerEvents returns doorbell and call notifications, it is nil if
+// notifications are not received.
+func (po *PassageOpener) ControllerEvents() <-chan entity.ControllerEvent {
+	if po.controllerEvents == nil {
+		return nil
+	}
+	return po.controllerEvents
+}
+
+// notificationHandler reports notification of kind. Notification is dropped
+// if events are not read fast enough, so intercom is not held waiting.
+func (po *PassageOpener) notificationHandler(kind entity.ControllerEventKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.URL.Query().Get(NotificationTokenParam)
+		if po.notifyToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(po.notifyToken)) != 1 {
+			po.log.WithFields(logrus.Fields{
+				"controller_event": kind,
+				"remote_addr":      r.RemoteAddr,
+			}).Warn("notification with invalid token rejected")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		select {
+		case po.controllerEvents <- entity.ControllerEvent{
+			Kind:      kind,
+			Direction: po.direction,
+			Time:      time.Now(),
+		}:
+		default:
+			po.log.WithField("controller_event", kind).Warn("controller events buffer is full, notification dropped")
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
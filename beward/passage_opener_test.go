@@ -0,0 +1,118 @@
+package beward_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bennyharvey/soma/beward"
+	"github.com/bennyharvey/soma/beward/bewardfake"
+	"github.com/bennyharvey/soma/entity"
+)
+
+func newTestPassageOpener(t *testing.T, uri string, direction entity.Direction, notifyBindAddr,
+	notifyToken string) *beward.PassageOpener {
+
+	t.Helper()
+
+	c := beward.NewClient(uri, "some_username", "some_password", beward.AutoAuth, testTimeout)
+
+	po, err := beward.NewPassageOpener(c, direction, notifyBindAddr, notifyToken)
+	if err != nil {
+		t.Fatalf("create passage opener: %v", err)
+	}
+
+	t.Cleanup(func() { po.Close() })
+
+	return po
+}
+
+func TestPassageOpenerDirection(t *testing.T) {
+	i, uri := newTestIntercom(t, bewardfake.DigestQopChallenge)
+
+	for _, direction := range []entity.Direction{entity.In, entity.Out} {
+		po := newTestPassageOpener(t, uri, direction, "", "")
+
+		if po.ControllerEvents() != nil {
+			t.Fatal("controller events are reported without notifications")
+		}
+
+		err := po.OpenPassage()
+		if err != nil {
+			t.Fatalf("%s: open passage: %v", direction, err)
+		}
+
+		if po.LastOpenTime().IsZero() {
+			t.Errorf("%s: last open time is not set", direction)
+		}
+
+		err = po.Ping()
+		if err != nil {
+			t.Errorf("%s: ping: %v", direction, err)
+		}
+	}
+
+	if opens := i.Opens(); len(opens) != 2 || opens[0] != beward.MainDoor || opens[1] != beward.AltDoor {
+		t.Fatalf("got opens %v, want main door for in and alternate door for out", opens)
+	}
+}
+
+func TestPassageOpenerNotifications(t *testing.T) {
+	_, uri := newTestIntercom(t, bewardfake.BasicChallenge)
+
+	po := newTestPassageOpener(t, uri, entity.Out, "127.0.0.1:0", "some_token")
+
+	notify := func(method, path, token string) int {
+		t.Helper()
+
+		req, err := http.NewRequest(method, "http://"+po.NotifyAddr()+path+"?token="+token, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+
+		_ = res.Body.Close()
+
+		return res.StatusCode
+	}
+
+	for _, c := range []struct {
+		method string
+		token  string
+		status int
+	}{
+		{method: http.MethodPost, token: "some_token", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, token: "", status: http.StatusForbidden},
+		{method: http.MethodGet, token: "other_token", status: http.StatusForbidden},
+	} {
+		if status := notify(c.method, beward.DoorbellNotificationPath, c.token); status != c.status {
+			t.Errorf("%s with token %q: got status %d, want %d", c.method, c.token, status, c.status)
+		}
+	}
+
+	for _, c := range []struct {
+		path string
+		kind entity.ControllerEventKind
+	}{
+		{path: beward.DoorbellNotificationPath, kind: entity.ControllerDoorbell},
+		{path: beward.CallNotificationPath, kind: entity.ControllerCall},
+	} {
+		if status := notify(http.MethodGet, c.path, "some_token"); status != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", c.path, status)
+		}
+
+		e := <-po.ControllerEvents()
+		if e.Kind != c.kind || e.Direction != entity.Out || e.Time.IsZero() {
+			t.Fatalf("%s: got controller event %+v", c.path, e)
+		}
+	}
+
+	select {
+	case e := <-po.ControllerEvents():
+		t.Fatalf("got controller event %+v of rejected notification", e)
+	default:
+	}
+}
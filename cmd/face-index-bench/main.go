@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/hnsw"
+)
+
+// face-index-bench measures closest person face lookup latency and recall of
+// the HNSW face index against exact scan on a synthetic gallery.
+func main() {
+	var (
+		facesCount   int
+		queriesCount int
+		cfg          = hnsw.DefaultConfig
+	)
+
+	flag.IntVar(&facesCount, "faces", 100000, "gallery size")
+	flag.IntVar(&queriesCount, "queries", 1000, "queries count")
+	flag.IntVar(&cfg.M, "m", cfg.M, "HNSW M")
+	flag.IntVar(&cfg.EfConstruction, "ef-construction", cfg.EfConstruction, "HNSW ef construction")
+	flag.IntVar(&cfg.EfSearch, "ef-search", cfg.EfSearch, "HNSW ef search")
+	flag.Parse()
+
+	rnd := rand.New(rand.NewSource(42))
+
+	pfs := make([]entity.PersonFace, facesCount)
+	for i := range pfs {
+		pfs[i] = entity.PersonFace{
+			ID:         int64(i + 1),
+			PersonID:   int64(i/4 + 1),
+			Descriptor: randomDescriptor(rnd),
+		}
+	}
+
+	logrus.WithField("faces", facesCount).Info("gallery generated")
+
+	idx := hnsw.New(cfg)
+
+	st := time.Now()
+	idx.Reset(pfs)
+
+	logrus.WithField("build_duration", time.Since(st)).Info("index built")
+
+	queries := make([]entity.FaceDescriptor, queriesCount)
+	for i := range queries {
+		// Queries are noisy copies of gallery faces, as real recognitions are.
+		q := pfs[rnd.Intn(len(pfs))].Descriptor
+		for j := range q {
+			q[j] += float32(rnd.NormFloat64() * 0.01)
+		}
+		queries[i] = q
+	}
+
+	var (
+		exactDurations []time.Duration
+		indexDurations []time.Duration
+		hits           int
+	)
+
+	for _, q := range queries {
+		st := time.Now()
+		exact := exactClosest(pfs, q)
+		exactDurations = append(exactDurations, time.Since(st))
+
+		st = time.Now()
+		ms := idx.Search(q, 1)
+		indexDurations = append(indexDurations, time.Since(st))
+
+		if len(ms) > 0 && ms[0].ID == exact.ID {
+			hits++
+		}
+	}
+
+	logrus.WithFields(durationFields(exactDurations)).Info("exact scan")
+	logrus.WithFields(durationFields(indexDurations)).Info("hnsw index")
+	logrus.WithField("recall", float64(hits)/float64(len(queries))).Info("hnsw recall@1")
+}
+
+func randomDescriptor(rnd *rand.Rand) (fd entity.FaceDescriptor) {
+	var norm float64
+	for i := range fd {
+		v := rnd.NormFloat64()
+		fd[i] = float32(v)
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	for i := range fd {
+		fd[i] /= float32(norm)
+	}
+	return fd
+}
+
+func exactClosest(pfs []entity.PersonFace, fd entity.FaceDescriptor) entity.PersonFace {
+	closestPF := pfs[0]
+	closestDistance := entity.FaceDescriptorDistance(closestPF.Descriptor, fd)
+
+	for _, pf := range pfs[1:] {
+		distance := entity.FaceDescriptorDistance(pf.Descriptor, fd)
+		if distance < closestDistance {
+			closestPF = pf
+			closestDistance = distance
+		}
+	}
+
+	return closestPF
+}
+
+func durationFields(ds []time.Duration) logrus.Fields {
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+
+	var total time.Duration
+	for _, d := range ds {
+		total += d
+	}
+
+	return logrus.Fields{
+		"avg": total / time.Duration(len(ds)),
+		"p50": ds[len(ds)/2],
+		"p99": ds[len(ds)*99/100],
+		"max": ds[len(ds)-1],
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -17,6 +18,67 @@ type config struct {
 	RabbitMQExchange    string  `yaml:"rabbitmq_exchange"`
 	PassageID           string  `yaml:"passage_id"`
 	ConfidenceLimit     float64 `yaml:"confidence_limit"`
+	Liveness            struct {
+		Texture *textureLivenessConfig `yaml:"texture"`
+		Motion  *motionLivenessConfig  `yaml:"motion"`
+	} `yaml:"liveness"`
+}
+
+type textureLivenessConfig struct {
+	SharpnessLimit     float64 `yaml:"sharpness_limit"`
+	HighFrequencyLimit float64 `yaml:"high_frequency_limit"`
+}
+
+func (c textureLivenessConfig) Validate() error {
+	if c.SharpnessLimit <= 0 {
+		return errors.New("sharpness_limit is invalid")
+	}
+	if c.HighFrequencyLimit <= 0 || c.HighFrequencyLimit > 1 {
+		return errors.New("high_frequency_limit is invalid")
+	}
+	return nil
+}
+
+type motionLivenessConfigRaw struct {
+	MinMotion   float64 `yaml:"min_motion"`
+	MaxMotion   float64 `yaml:"max_motion"`
+	MaxInterval string  `yaml:"max_interval"`
+}
+
+type motionLivenessConfig struct {
+	motionLivenessConfigRaw
+	MaxInterval time.Duration
+}
+
+func (c *motionLivenessConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	err := unmarshal(&c.motionLivenessConfigRaw)
+	if err != nil {
+		return err
+	}
+
+	c.MaxInterval = time.Second
+
+	if c.motionLivenessConfigRaw.MaxInterval != "" {
+		c.MaxInterval, err = time.ParseDuration(c.motionLivenessConfigRaw.MaxInterval)
+		if err != nil {
+			return fmt.Errorf("max_interval parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c motionLivenessConfig) Validate() error {
+	if c.MinMotion <= 0 {
+		return errors.New("min_motion is invalid")
+	}
+	if c.MaxMotion <= c.MinMotion {
+		return errors.New("max_motion is invalid")
+	}
+	if c.MaxInterval <= 0 {
+		return errors.New("max_interval is invalid")
+	}
+	return nil
 }
 
 func (c config) Validate() error {
@@ -38,6 +100,18 @@ func (c config) Validate() error {
 	if c.ConfidenceLimit < 0 {
 		return errors.New("confidence_limit is invalid")
 	}
+	if c.Liveness.Texture != nil {
+		err := c.Liveness.Texture.Validate()
+		if err != nil {
+			return fmt.Errorf("texture liveness config validate: %w", err)
+		}
+	}
+	if c.Liveness.Motion != nil {
+		err := c.Liveness.Motion.Validate()
+		if err != nil {
+			return fmt.Errorf("motion liveness config validate: %w", err)
+		}
+	}
 	return nil
 }
 
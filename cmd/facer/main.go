@@ -7,12 +7,11 @@ import (
 	"syscall"
 	"time"
 
-	"../../facer"
-	"../../rmq"
-
-	"../../dlib"
-
 	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/dlib"
+	"github.com/bennyharvey/soma/facer"
+	"github.com/bennyharvey/soma/rmq"
 )
 
 func main() {
@@ -28,9 +27,9 @@ func main() {
 
 	flag.StringVar(&configPath, "c", "", "config file path")
 	flag.Parse()
-	
+
 	// logrus.Info(configPath)
-	
+
 	c, err := loadConfig(configPath)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to load config")
@@ -70,7 +69,26 @@ func main() {
 
 	logrus.Info("rmq_recognized_face_publisher created and started")
 
-	dfh := facer.NewDetectedFaceHandler(c.ConfidenceLimit, fr, rfp)
+	var lcs []facer.LivenessChecker
+
+	if c.Liveness.Texture != nil {
+		lcs = append(lcs, facer.NewTextureLivenessChecker(c.Liveness.Texture.SharpnessLimit,
+			c.Liveness.Texture.HighFrequencyLimit))
+
+		logrus.Info("facer_texture_liveness_checker created")
+	}
+
+	if c.Liveness.Motion != nil {
+		mlc := facer.NewMotionLivenessChecker(c.Liveness.Motion.MinMotion, c.Liveness.Motion.MaxMotion,
+			c.Liveness.Motion.MaxInterval)
+		defer mlc.Close()
+
+		lcs = append(lcs, mlc)
+
+		logrus.Info("facer_motion_liveness_checker created")
+	}
+
+	dfh := facer.NewDetectedFaceHandler(c.ConfidenceLimit, lcs, fr, rfp)
 
 	logrus.Info("facer_detected_face_handler created")
 
@@ -84,7 +102,7 @@ func main() {
 
 	logrus.Info("started")
 
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 	logrus.Infof("captured %v signal, stopping", <-signals)
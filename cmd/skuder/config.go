@@ -7,19 +7,44 @@ import (
 	"time"
 
 	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/hnsw"
+	"github.com/bennyharvey/soma/passage"
+	"github.com/bennyharvey/soma/skuder"
 	"gopkg.in/yaml.v2"
 )
 
+type votingConfigRaw struct {
+	MinMatches         int     `yaml:"min_matches"`
+	Window             string  `yaml:"window"`
+	MaxAverageDistance float64 `yaml:"max_average_distance"`
+}
+
+// defaultPassagePingPeriod is how often passage controllers are pinged if
+// ping_period is not set, zero ping_period disables pings.
+const defaultPassagePingPeriod = 30 * time.Second
+
 type passageOpenerConfigRaw struct {
-	Type          entity.PassageType `yaml:"type"`
-	Address       string             `yaml:"address"`
-	Direction     entity.Direction   `yaml:"direction"`
-	WaitAfterOpen string             `yaml:"wait_after_open"`
+	Type               entity.PassageType `yaml:"type"`
+	Address            string             `yaml:"address"`
+	Direction          entity.Direction   `yaml:"direction"`
+	Zone               string             `yaml:"zone"`
+	Mode               entity.PassageMode `yaml:"mode"`
+	SecondFactorWindow string             `yaml:"second_factor_window"`
+	WaitAfterOpen      string             `yaml:"wait_after_open"`
+	Voting             votingConfigRaw    `yaml:"voting"`
+	LivenessThreshold  float64            `yaml:"liveness_threshold"`
+	PingPeriod         string             `yaml:"ping_period"`
+
+	// Options are passage type specific settings.
+	Options map[string]interface{} `yaml:",inline"`
 }
 
 type passageOpenerConfig struct {
 	passageOpenerConfigRaw
-	WaitAfterOpen time.Duration
+	SecondFactorWindow time.Duration
+	WaitAfterOpen      time.Duration
+	Voting             skuder.VotingPolicy
+	PingPeriod         time.Duration
 }
 
 func (sc *passageOpenerConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -32,18 +57,48 @@ func (sc *passageOpenerConfig) UnmarshalYAML(unmarshal func(interface{}) error)
 
 	sc.passageOpenerConfigRaw = cRaw
 
+	if sc.Mode == "" {
+		sc.Mode = entity.FaceMode
+	}
+
+	if cRaw.SecondFactorWindow != "" {
+		sc.SecondFactorWindow, err = time.ParseDuration(cRaw.SecondFactorWindow)
+		if err != nil {
+			return fmt.Errorf("second_factor_window parse: %w", err)
+		}
+	}
+
 	sc.WaitAfterOpen, err = time.ParseDuration(cRaw.WaitAfterOpen)
 	if err != nil {
 		return fmt.Errorf("wait_after_open parse: %w", err)
 	}
 
+	sc.PingPeriod = defaultPassagePingPeriod
+
+	if cRaw.PingPeriod != "" {
+		sc.PingPeriod, err = time.ParseDuration(cRaw.PingPeriod)
+		if err != nil {
+			return fmt.Errorf("ping_period parse: %w", err)
+		}
+	}
+
+	sc.Voting = skuder.VotingPolicy{
+		MinMatches:         cRaw.Voting.MinMatches,
+		MaxAverageDistance: cRaw.Voting.MaxAverageDistance,
+	}
+
+	if cRaw.Voting.Window != "" {
+		sc.Voting.Window, err = time.ParseDuration(cRaw.Voting.Window)
+		if err != nil {
+			return fmt.Errorf("voting window parse: %w", err)
+		}
+	}
+
 	return nil
 }
 
 func (c passageOpenerConfig) Validate() error {
-	switch c.Type {
-	case entity.Z5R, entity.Sigur, entity.Dummy, entity.Beward:
-	default:
+	if !passage.Registered(c.Type) {
 		return errors.New("type is unknown")
 	}
 	if c.Address == "" {
@@ -52,9 +107,380 @@ func (c passageOpenerConfig) Validate() error {
 	if !(c.Direction == entity.In || c.Direction == entity.Out) {
 		return errors.New("direction is invalid")
 	}
+	switch c.Mode {
+	case entity.FaceMode:
+	case entity.FaceOrCardMode, entity.FaceAndCardMode:
+		if c.Mode == entity.FaceAndCardMode && c.SecondFactorWindow <= 0 {
+			return errors.New("second_factor_window is invalid")
+		}
+		if !passage.ReportsCredentials(c.Type, passage.Config{
+			Address:   c.Address,
+			Direction: c.Direction,
+			Options:   c.Options,
+		}) {
+			return fmt.Errorf("mode %s needs passage opener reporting credentials", c.Mode)
+		}
+	default:
+		return errors.New("mode is unknown")
+	}
 	if c.WaitAfterOpen < 0 {
 		return errors.New("wait_after_open is invalid")
 	}
+	if c.Voting.MinMatches < 0 {
+		return errors.New("voting min_matches is invalid")
+	}
+	if c.Voting.Window < 0 || (c.Voting.MinMatches > 1 && c.Voting.Window == 0) {
+		return errors.New("voting window is invalid")
+	}
+	if c.Voting.MaxAverageDistance < 0 {
+		return errors.New("voting max_average_distance is invalid")
+	}
+	if c.PingPeriod < 0 {
+		return errors.New("ping_period is invalid")
+	}
+	if c.LivenessThreshold < 0 || c.LivenessThreshold > 1 {
+		return errors.New("liveness_threshold is invalid")
+	}
+	return nil
+}
+
+type faceIndexConfig struct {
+	hnsw.Config    `yaml:",inline"`
+	Type           string `yaml:"type"`
+	ExactScanBelow int    `yaml:"exact_scan_below"`
+}
+
+func (c faceIndexConfig) Validate() error {
+	switch c.Type {
+	case "", "exact", "hnsw":
+	default:
+		return errors.New("type is unknown")
+	}
+	if c.ExactScanBelow < 0 {
+		return errors.New("exact_scan_below is invalid")
+	}
+	if c.M < 0 || c.EfConstruction < 0 || c.EfSearch < 0 {
+		return errors.New("hnsw parameters are invalid")
+	}
+	return nil
+}
+
+type eventRetentionConfigRaw struct {
+	Enabled       bool              `yaml:"enabled"`
+	CheckPeriod   string            `yaml:"check_period"`
+	Archive       bool              `yaml:"archive"`
+	DefaultMaxAge string            `yaml:"default_max_age"`
+	MaxAges       map[string]string `yaml:"max_ages"`
+}
+
+type eventRetentionConfig struct {
+	Enabled bool
+	skuder.RetentionPolicy
+}
+
+func (c *eventRetentionConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cRaw eventRetentionConfigRaw
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	c.Enabled = cRaw.Enabled
+	c.Archive = cRaw.Archive
+
+	if cRaw.CheckPeriod != "" {
+		c.CheckPeriod, err = time.ParseDuration(cRaw.CheckPeriod)
+		if err != nil {
+			return fmt.Errorf("check_period parse: %w", err)
+		}
+	}
+
+	if cRaw.DefaultMaxAge != "" {
+		c.DefaultMaxAge, err = time.ParseDuration(cRaw.DefaultMaxAge)
+		if err != nil {
+			return fmt.Errorf("default_max_age parse: %w", err)
+		}
+	}
+
+	c.MaxAges = map[entity.EventType]time.Duration{}
+
+	for et, age := range cRaw.MaxAges {
+		c.MaxAges[entity.EventType(et)], err = time.ParseDuration(age)
+		if err != nil {
+			return fmt.Errorf("max_ages %s parse: %w", et, err)
+		}
+	}
+
+	return nil
+}
+
+func (c eventRetentionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CheckPeriod <= 0 {
+		return errors.New("check_period is invalid")
+	}
+	if c.DefaultMaxAge < 0 {
+		return errors.New("default_max_age is invalid")
+	}
+	for et, age := range c.MaxAges {
+		if age <= 0 {
+			return fmt.Errorf("max_ages %s is invalid", et)
+		}
+	}
+	return nil
+}
+
+type eventPartitionsConfigRaw struct {
+	Ahead       int    `yaml:"ahead"`
+	CheckPeriod string `yaml:"check_period"`
+}
+
+type eventPartitionsConfig struct {
+	Ahead       int
+	CheckPeriod time.Duration
+}
+
+func (c *eventPartitionsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cRaw eventPartitionsConfigRaw
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	if cRaw.Ahead != 0 {
+		c.Ahead = cRaw.Ahead
+	}
+
+	if cRaw.CheckPeriod != "" {
+		c.CheckPeriod, err = time.ParseDuration(cRaw.CheckPeriod)
+		if err != nil {
+			return fmt.Errorf("check_period parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c eventPartitionsConfig) Validate() error {
+	if c.Ahead < 1 {
+		return errors.New("ahead is invalid")
+	}
+	if c.CheckPeriod <= 0 {
+		return errors.New("check_period is invalid")
+	}
+	return nil
+}
+
+type visitorsConfigRaw struct {
+	CleanupPeriod string `yaml:"cleanup_period"`
+}
+
+type visitorsConfig struct {
+	CleanupPeriod time.Duration
+}
+
+func (c *visitorsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cRaw visitorsConfigRaw
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	if cRaw.CleanupPeriod != "" {
+		c.CleanupPeriod, err = time.ParseDuration(cRaw.CleanupPeriod)
+		if err != nil {
+			return fmt.Errorf("cleanup_period parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c visitorsConfig) Validate() error {
+	if c.CleanupPeriod <= 0 {
+		return errors.New("cleanup_period is invalid")
+	}
+	return nil
+}
+
+type faceClusteringConfigRaw struct {
+	Enabled     bool    `yaml:"enabled"`
+	CheckPeriod string  `yaml:"check_period"`
+	Distance    float64 `yaml:"distance"`
+	BatchSize   int     `yaml:"batch_size"`
+}
+
+type faceClusteringConfig struct {
+	faceClusteringConfigRaw
+	CheckPeriod time.Duration
+}
+
+func (c *faceClusteringConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cRaw faceClusteringConfigRaw
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	c.faceClusteringConfigRaw = cRaw
+
+	if cRaw.CheckPeriod != "" {
+		c.CheckPeriod, err = time.ParseDuration(cRaw.CheckPeriod)
+		if err != nil {
+			return fmt.Errorf("check_period parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c faceClusteringConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CheckPeriod <= 0 {
+		return errors.New("check_period is invalid")
+	}
+	if c.Distance <= 0 {
+		return errors.New("distance is invalid")
+	}
+	if c.BatchSize <= 0 {
+		return errors.New("batch_size is invalid")
+	}
+	return nil
+}
+
+type notifierConfigRaw struct {
+	Type     string   `yaml:"type"`
+	URL      string   `yaml:"url"`
+	Timeout  string   `yaml:"timeout"`
+	Address  string   `yaml:"address"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+type notifierConfig struct {
+	notifierConfigRaw
+	Timeout time.Duration
+}
+
+func (c *notifierConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cRaw notifierConfigRaw
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	c.notifierConfigRaw = cRaw
+
+	if cRaw.Timeout != "" {
+		c.Timeout, err = time.ParseDuration(cRaw.Timeout)
+		if err != nil {
+			return fmt.Errorf("timeout parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c notifierConfig) Validate() error {
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return errors.New("url is empty")
+		}
+		if c.Timeout < 0 {
+			return errors.New("timeout is invalid")
+		}
+	case "email":
+		if c.Address == "" {
+			return errors.New("address is empty")
+		}
+		if c.From == "" {
+			return errors.New("from is empty")
+		}
+		if len(c.To) == 0 {
+			return errors.New("to is empty")
+		}
+	default:
+		return errors.New("type is unknown")
+	}
+	return nil
+}
+
+type mqttBridgeConfigRaw struct {
+	Enabled     bool               `yaml:"enabled"`
+	Broker      string             `yaml:"broker"`
+	ClientID    string             `yaml:"client_id"`
+	Username    string             `yaml:"username"`
+	Password    string             `yaml:"password"`
+	Timeout     string             `yaml:"timeout"`
+	TopicPrefix string             `yaml:"topic_prefix"`
+	QoS         byte               `yaml:"qos"`
+	EventTypes  []entity.EventType `yaml:"event_types"`
+}
+
+type mqttBridgeConfig struct {
+	mqttBridgeConfigRaw
+	Timeout time.Duration
+}
+
+func (c *mqttBridgeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	cRaw := mqttBridgeConfigRaw{
+		ClientID:    "soma-event-bridge",
+		TopicPrefix: "soma/passages",
+		EventTypes:  []entity.EventType{entity.PassageOpen, entity.PersonRecognize},
+	}
+
+	err := unmarshal(&cRaw)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal: %w", err)
+	}
+
+	c.mqttBridgeConfigRaw = cRaw
+	c.Timeout = 5 * time.Second
+
+	if cRaw.Timeout != "" {
+		c.Timeout, err = time.ParseDuration(cRaw.Timeout)
+		if err != nil {
+			return fmt.Errorf("timeout parse: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c mqttBridgeConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Broker == "" {
+		return errors.New("broker is empty")
+	}
+	if c.ClientID == "" {
+		return errors.New("client_id is empty")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("timeout is invalid")
+	}
+	if c.TopicPrefix == "" {
+		return errors.New("topic_prefix is empty")
+	}
+	if c.QoS > 2 {
+		return errors.New("qos is invalid")
+	}
+	if len(c.EventTypes) == 0 {
+		return errors.New("event_types is empty")
+	}
 	return nil
 }
 
@@ -92,10 +518,22 @@ type config struct {
 	RabbitMQURI              string                         `yaml:"rabbitmq_uri"`
 	RabbitMQExchange         string                         `yaml:"rabbitmq_exchange"`
 	PostgresURI              string                         `yaml:"postgres_uri"`
+	MigrateOnStart           bool                           `yaml:"migrate_on_start"`
 	DetectConfidenceLimit    float64                        `yaml:"detect_confidence_limit"`
 	DescriptorsMatchDistance float64                        `yaml:"descriptors_match_distance"`
+	AmbiguityMargin          float64                        `yaml:"ambiguity_margin"`
+	MatchCandidatesCount     int                            `yaml:"match_candidates_count"`
+	FaceIndex                faceIndexConfig                `yaml:"face_index"`
 	PassageOpeners           map[string]passageOpenerConfig `yaml:"passage_openers"`
+	AntiPassback             map[string]entity.PassbackMode `yaml:"anti_passback"`
+	AccessControl            bool                           `yaml:"access_control"`
 	PhotoStoragePath         string                         `yaml:"photo_storage_path"`
+	EventPartitions          eventPartitionsConfig          `yaml:"event_partitions"`
+	EventRetention           eventRetentionConfig           `yaml:"event_retention"`
+	Visitors                 visitorsConfig                 `yaml:"visitors"`
+	FaceClustering           faceClusteringConfig           `yaml:"face_clustering"`
+	Notifiers                []notifierConfig               `yaml:"notifiers"`
+	MQTTBridge               mqttBridgeConfig               `yaml:"mqtt_bridge"`
 	WebServer                webServerConfig                `yaml:"web_server"`
 }
 
@@ -124,13 +562,56 @@ func (c config) Validate() error {
 	if c.DescriptorsMatchDistance < 0 {
 		return errors.New("descriptors_match_distance is invalid")
 	}
+	if c.AmbiguityMargin < 0 {
+		return errors.New("ambiguity_margin is invalid")
+	}
+	if c.MatchCandidatesCount < 0 {
+		return errors.New("match_candidates_count is invalid")
+	}
+	err := c.FaceIndex.Validate()
+	if err != nil {
+		return fmt.Errorf("face_index: %w", err)
+	}
 	for passageID, po := range c.PassageOpeners {
 		err := po.Validate()
 		if err != nil {
 			return fmt.Errorf("passage_opener %s: %w", passageID, err)
 		}
 	}
-	err := c.WebServer.Validate()
+	for zone, mode := range c.AntiPassback {
+		switch mode {
+		case entity.PassbackSoft, entity.PassbackStrict:
+		default:
+			return fmt.Errorf("anti_passback %s: mode is unknown", zone)
+		}
+	}
+	err = c.EventPartitions.Validate()
+	if err != nil {
+		return fmt.Errorf("event_partitions: %w", err)
+	}
+	err = c.EventRetention.Validate()
+	if err != nil {
+		return fmt.Errorf("event_retention: %w", err)
+	}
+	err = c.Visitors.Validate()
+	if err != nil {
+		return fmt.Errorf("visitors: %w", err)
+	}
+	err = c.FaceClustering.Validate()
+	if err != nil {
+		return fmt.Errorf("face_clustering: %w", err)
+	}
+	for i, n := range c.Notifiers {
+		err := n.Validate()
+		if err != nil {
+			return fmt.Errorf("notifier %d: %w", i, err)
+		}
+	}
+	err = c.MQTTBridge.Validate()
+	if err != nil {
+		return fmt.Errorf("mqtt_bridge: %w", err)
+	}
+	err = c.WebServer.Validate()
 	if err != nil {
 		return fmt.Errorf("web_server: %w", err)
 	}
@@ -143,7 +624,10 @@ func loadConfig(configPath string) (config, error) {
 		return config{}, fmt.Errorf("read config %s file: %w", configPath, err)
 	}
 
-	var c config
+	c := config{
+		EventPartitions: eventPartitionsConfig{Ahead: 2, CheckPeriod: time.Hour},
+		Visitors:        visitorsConfig{CleanupPeriod: time.Minute},
+	}
 
 	err = yaml.Unmarshal(configYAML, &c)
 	if err != nil {
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/mqtt"
+	"github.com/bennyharvey/soma/pg"
+)
+
+// eventStorage is pg storage which also publishes recorded events with MQTT
+// event bridge if it is set.
+type eventStorage struct {
+	*pg.Storage
+	bridge *mqtt.EventBridge
+}
+
+func (s eventStorage) AddEvent(e entity.Event) error {
+	err := s.Storage.AddEvent(e)
+	if err != nil {
+		return err
+	}
+
+	if s.bridge != nil {
+		s.bridge.Publish(e)
+	}
+
+	return nil
+}
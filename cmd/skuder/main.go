@@ -12,14 +12,21 @@ import (
 	"github.com/bennyharvey/soma/dlib"
 	"github.com/bennyharvey/soma/entity"
 	"github.com/bennyharvey/soma/file"
-	"../../pg"
+	"github.com/bennyharvey/soma/hnsw"
+	"github.com/bennyharvey/soma/mqtt"
+	"github.com/bennyharvey/soma/notify"
+	"github.com/bennyharvey/soma/passage"
+	"github.com/bennyharvey/soma/pg"
 	"github.com/bennyharvey/soma/rmq"
-	"github.com/bennyharvey/soma/sigur"
 	"github.com/bennyharvey/soma/skuder"
-	// "github.com/bennyharvey/soma/web"
-	"../../web"
-	"soma/z5r"
-	"soma/beward"
+	"github.com/bennyharvey/soma/web"
+
+	// Passage opener types are registered by importing their packages.
+	_ "github.com/bennyharvey/soma/beward"
+	_ "github.com/bennyharvey/soma/httpopener"
+	_ "github.com/bennyharvey/soma/modbus"
+	_ "github.com/bennyharvey/soma/sigur"
+	_ "github.com/bennyharvey/soma/z5r"
 )
 
 func main() {
@@ -42,6 +49,30 @@ func main() {
 		logrus.WithError(err).Fatal("failed to load config")
 	}
 
+	if flag.Arg(0) == "migrate" {
+		if c.PostgresURI == "" {
+			logrus.Fatal("invalid config: postgres_uri is empty")
+		}
+
+		pgStorage, err := pg.NewStorage(c.PostgresURI)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create pg_storage")
+		}
+
+		err = runMigrate(pgStorage, flag.Args()[1:])
+
+		closeErr := pgStorage.Close()
+		if closeErr != nil {
+			logrus.WithError(closeErr).Error("failed to close pg_storage")
+		}
+
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to migrate pg_storage")
+		}
+
+		return
+	}
+
 	err = c.Validate()
 	if err != nil {
 		logrus.WithError(err).Fatal("invalid config")
@@ -57,7 +88,15 @@ func main() {
 		logrus.Info("environment variables set")
 	}
 
-	pgStorage, err := pg.NewStorage(c.PostgresURI)
+	var pgOpts []pg.StorageOption
+
+	if c.FaceIndex.Type == "hnsw" {
+		pgOpts = append(pgOpts, pg.WithFaceIndex(func() pg.FaceIndex {
+			return hnsw.New(c.FaceIndex.Config)
+		}, c.FaceIndex.ExactScanBelow))
+	}
+
+	pgStorage, err := pg.NewStorage(c.PostgresURI, pgOpts...)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to create pg_storage")
 	}
@@ -72,54 +111,86 @@ func main() {
 
 	logrus.Info("pg_storage created")
 
-	// err = pgStorage.Migrate()
-	// if err != nil {
-	// 	logrus.WithError(err).Fatal("failed to migrate pg_storage")
-	// }
+	if c.MigrateOnStart {
+		err = pgStorage.Migrate()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to migrate pg_storage")
+		}
 
-	// logrus.Info("pg_storage migrated")
+		logrus.Info("pg_storage migrated")
+	}
 
-	err = pgStorage.LoadPersonFaces()
+	err = pgStorage.ListenGalleryChanges()
 	if err != nil {
-		logrus.WithError(err).Fatal("failed to load person faces")
+		logrus.WithError(err).Fatal("failed to listen gallery changes")
 	}
 
-	logrus.Info("person faces loaded")
+	logrus.Info("person faces loaded, listening gallery changes")
 
-	photoStorage := file.NewPhotoStorage(c.PhotoStoragePath)
+	ep := skuder.NewEventPartitioner(c.EventPartitions.Ahead, c.EventPartitions.CheckPeriod, pgStorage)
+	defer func() {
+		ep.Stop()
+		logrus.Info("event_partitioner stopped")
+	}()
 
-	logrus.Info("photo_storage created")
+	logrus.Info("event_partitioner created and started")
 
-	for passageID, poc := range c.PassageOpeners {
-		log := logrus.WithField("passage_id", passageID)
+	if c.EventRetention.Enabled {
+		er := skuder.NewEventRetainer(c.EventRetention.RetentionPolicy, pgStorage)
+		defer func() {
+			er.Stop()
+			logrus.Info("event_retainer stopped")
+		}()
 
-		var po skuder.PassageOpener
-
-		switch poc.Type {
-			case entity.Sigur:
-				po = sigur.NewPassageOpener(poc.Address, poc.Direction)
-			case entity.Z5R:
-				po = z5r.NewPassageOpener(poc.Address, poc.Direction)
-			case entity.Beward:
-				po = beward.NewPassageOpener(poc.Address, poc.Direction)
-			case entity.Dummy:
-				po = newDummyPassageOpener()
-		}
+		logrus.Info("event_retainer created and started")
+	}
 
-		rfh := skuder.NewRecognizedFaceHandler(passageID, poc.WaitAfterOpen, c.DescriptorsMatchDistance,
-			c.DetectConfidenceLimit, pgStorage, photoStorage, po)
+	vc := skuder.NewVisitorCleaner(c.Visitors.CleanupPeriod, pgStorage)
+	defer func() {
+		vc.Stop()
+		logrus.Info("visitor_cleaner stopped")
+	}()
 
-		log.Info("recognized_face_handler created")
+	logrus.Info("visitor_cleaner created and started")
 
-		rfc := rmq.NewRecognizedFaceConsumer(c.RabbitMQURI, c.RabbitMQExchange, passageID, rfh)
+	if c.FaceClustering.Enabled {
+		fc := skuder.NewFaceClusterer(c.FaceClustering.Distance, c.FaceClustering.BatchSize,
+			c.FaceClustering.CheckPeriod, pgStorage)
 		defer func() {
-			rfc.Stop()
-			logrus.Info("recognized_face_consumer stopped")
+			fc.Stop()
+			logrus.Info("face_clusterer stopped")
 		}()
 
-		log.Info("recognized_face_consumer created and started")
+		logrus.Info("face_clusterer created and started")
 	}
 
+	dbStorage := eventStorage{Storage: pgStorage}
+
+	if c.MQTTBridge.Enabled {
+		eb, err := mqtt.NewEventBridge(mqtt.ClientConfig{
+			Broker:   c.MQTTBridge.Broker,
+			ClientID: c.MQTTBridge.ClientID,
+			Username: c.MQTTBridge.Username,
+			Password: c.MQTTBridge.Password,
+			Timeout:  c.MQTTBridge.Timeout,
+		}, c.MQTTBridge.TopicPrefix, c.MQTTBridge.QoS, c.MQTTBridge.EventTypes)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create mqtt_event_bridge")
+		}
+		defer func() {
+			eb.Stop()
+			logrus.Info("mqtt_event_bridge stopped")
+		}()
+
+		dbStorage.bridge = eb
+
+		logrus.Info("mqtt_event_bridge created")
+	}
+
+	photoStorage := file.NewPhotoStorage(c.PhotoStoragePath)
+
+	logrus.Info("photo_storage created")
+
 	fd, err := dlib.NewFaceDetector(c.DetectorModelPath)
 	if err != nil {
 		logrus.WithError(err).Error("failed to create dlib_face_detector")
@@ -142,9 +213,43 @@ func main() {
 
 	logrus.Info("dlib_face_recognizer created")
 
+	var (
+		passageDirections = map[string]entity.Direction{}
+		passbackZones     = map[string]string{}
+		passages          = map[string]*passage.Passage{}
+		webPassages       = map[string]web.Passage{}
+	)
+
+	for passageID, poc := range c.PassageOpeners {
+		passageDirections[passageID] = poc.Direction
+
+		if poc.Zone != "" && c.AntiPassback[poc.Zone] != entity.PassbackOff {
+			passbackZones[passageID] = poc.Zone
+		}
+
+		p, err := passage.New(passageID, poc.Type, passage.Config{
+			Address:   poc.Address,
+			Direction: poc.Direction,
+			Options:   poc.Options,
+		}, poc.PingPeriod)
+		if err != nil {
+			logrus.WithError(err).WithField("passage_id", passageID).Fatal("failed to create passage")
+		}
+		defer func() {
+			p.Stop()
+			logrus.WithField("passage_id", p.ID()).Info("passage stopped")
+		}()
+
+		passages[passageID] = p
+		webPassages[passageID] = p
+	}
+
+	logrus.Info("passages created")
+
 	ws, err := web.NewServer(c.WebServer.BindAddr, c.WebServer.JWTSigningKey, c.WebServer.TLSCrtFilePath,
-		c.WebServer.TLSKeyFilePath, c.DetectConfidenceLimit, c.WebServer.PassageNames, c.WebServer.Debug,
-		pgStorage, photoStorage, fd, fr)
+		c.WebServer.TLSKeyFilePath, c.DetectConfidenceLimit, c.WebServer.PassageNames, passageDirections,
+		passbackZones, webPassages, c.WebServer.Debug,
+		dbStorage, photoStorage, fd, fr)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to create web_server")
 	}
@@ -155,29 +260,62 @@ func main() {
 
 	logrus.Info("web_server created and started")
 
-	logrus.Info("started")
+	notifiers := []skuder.Notifier{ws}
 
-	signals := make(chan os.Signal)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	for _, nc := range c.Notifiers {
+		switch nc.Type {
+		case "webhook":
+			notifiers = append(notifiers, notify.NewWebhook(nc.URL, nc.Timeout))
+		case "email":
+			notifiers = append(notifiers, notify.NewEmail(nc.Address, nc.Username, nc.Password, nc.From, nc.To))
+		}
+	}
 
-	logrus.Infof("captured %v signal, stopping", <-signals)
+	logrus.Info("notifiers created")
 
-	st = time.Now()
-}
+	for passageID, poc := range c.PassageOpeners {
+		log := logrus.WithField("passage_id", passageID)
 
-type dummyPassageOpener struct {
-	lastOpenTime time.Time
-}
+		po := passages[passageID]
+
+		rfh := skuder.NewRecognizedFaceHandler(skuder.RecognizedFaceHandlerConfig{
+			PassageID:             passageID,
+			Direction:             poc.Direction,
+			Zone:                  poc.Zone,
+			PassbackMode:          c.AntiPassback[poc.Zone],
+			AccessControl:         c.AccessControl,
+			WaitAfterOpen:         poc.WaitAfterOpen,
+			Voting:                poc.Voting,
+			LivenessThreshold:     poc.LivenessThreshold,
+			Mode:                  poc.Mode,
+			SecondFactorWindow:    poc.SecondFactorWindow,
+			MatchDistance:         c.DescriptorsMatchDistance,
+			AmbiguityMargin:       c.AmbiguityMargin,
+			CandidatesCount:       c.MatchCandidatesCount,
+			DetectConfidenceLimit: c.DetectConfidenceLimit,
+		}, dbStorage, photoStorage, po, notifiers)
+		defer func() {
+			rfh.Stop()
+			logrus.Info("recognized_face_handler stopped")
+		}()
 
-func newDummyPassageOpener() *dummyPassageOpener {
-	return &dummyPassageOpener{}
-}
+		log.Info("recognized_face_handler created")
 
-func (po *dummyPassageOpener) OpenPassage() error {
-	po.lastOpenTime = time.Now()
-	return nil
-}
+		rfc := rmq.NewRecognizedFaceConsumer(c.RabbitMQURI, c.RabbitMQExchange, passageID, rfh)
+		defer func() {
+			rfc.Stop()
+			logrus.Info("recognized_face_consumer stopped")
+		}()
+
+		log.Info("recognized_face_consumer created and started")
+	}
+
+	logrus.Info("started")
 
-func (po *dummyPassageOpener) LastOpenTime() time.Time {
-	return po.lastOpenTime
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	logrus.Infof("captured %v signal, stopping", <-signals)
+
+	st = time.Now()
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/pg"
+)
+
+const migrateUsage = `usage: skuder -c config migrate <command>
+
+commands:
+  up [n]         apply n (all by default) not applied migrations
+  down [n]       roll back n (one by default) last applied migrations
+  status         list migrations and whether they are applied
+  to <version>   apply or roll back migrations up to version`
+
+func runMigrate(s *pg.Storage, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	parseN := func(def int) (int, error) {
+		if len(args) < 2 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid migrations count %q", args[1])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "up":
+		max, err := parseN(0)
+		if err != nil {
+			return err
+		}
+		n, err := s.MigrateUp(max)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("applied", n).Info("migrated up")
+
+	case "down":
+		max, err := parseN(1)
+		if err != nil {
+			return err
+		}
+		n, err := s.MigrateDown(max)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("rolled_back", n).Info("migrated down")
+
+	case "to":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		n, err := s.MigrateTo(version)
+		if err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"version":  version,
+			"migrated": n,
+		}).Info("migrated to version")
+
+	case "status":
+		mss, err := s.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, ms := range mss {
+			status := "pending"
+			if ms.Applied {
+				status = "applied"
+			}
+			fmt.Printf("%-8d %-40s %s\n", ms.Version, ms.ID, status)
+		}
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
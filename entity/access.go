@@ -0,0 +1,172 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimeWindow is time of day range from From inclusive to To exclusive in
+// HH:MM format, To can be 24:00. Weekday is used in weekly schedules only.
+type TimeWindow struct {
+	Weekday time.Weekday `json:"weekday"`
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+}
+
+func (tw TimeWindow) Validate() error {
+	if tw.Weekday < time.Sunday || tw.Weekday > time.Saturday {
+		return errors.New("weekday is invalid")
+	}
+	from, err := parseDayTime(tw.From)
+	if err != nil {
+		return fmt.Errorf("from is invalid: %w", err)
+	}
+	to, err := parseDayTime(tw.To)
+	if err != nil {
+		return fmt.Errorf("to is invalid: %w", err)
+	}
+	if from >= to {
+		return errors.New("from is not before to")
+	}
+	return nil
+}
+
+func (tw TimeWindow) contains(t time.Time) bool {
+	from, err := parseDayTime(tw.From)
+	if err != nil {
+		return false
+	}
+	to, err := parseDayTime(tw.To)
+	if err != nil {
+		return false
+	}
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	return from <= d && d < to
+}
+
+func parseDayTime(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// TimeWindows is stored in DB as JSON.
+type TimeWindows []TimeWindow
+
+func (tws *TimeWindows) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, tws)
+	case string:
+		return json.Unmarshal([]byte(src), tws)
+	case nil:
+		*tws = nil
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %T to TimeWindows", src)
+}
+
+func (tws TimeWindows) Value() (driver.Value, error) {
+	if tws == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(tws)
+}
+
+// AccessGroup allows its persons to pass its passages within weekly Windows.
+// On holidays HolidayWindows are used instead, their weekdays are ignored.
+type AccessGroup struct {
+	ID             int64          `json:"id" db:"id"`
+	Name           string         `json:"name" db:"name"`
+	PassageIDs     pq.StringArray `json:"passage_ids" db:"passage_ids"`
+	Windows        TimeWindows    `json:"windows" db:"windows"`
+	HolidayWindows TimeWindows    `json:"holiday_windows" db:"holiday_windows"`
+	PersonIDs      pq.Int64Array  `json:"person_ids" db:"person_ids"`
+}
+
+func (ag AccessGroup) hasPassage(passageID string) bool {
+	for _, id := range ag.PassageIDs {
+		if id == passageID {
+			return true
+		}
+	}
+	return false
+}
+
+func (ag AccessGroup) allows(t time.Time, holiday bool) bool {
+	if holiday {
+		for _, tw := range ag.HolidayWindows {
+			if tw.contains(t) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, tw := range ag.Windows {
+		if tw.Weekday == t.Weekday() && tw.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+type Holiday struct {
+	Date string `json:"date" db:"date"`
+	Name string `json:"name" db:"name"`
+}
+
+type AccessDenyReason string
+
+const (
+	AccessNoGroup           AccessDenyReason = "no_access_group"
+	AccessPassageNotAllowed AccessDenyReason = "passage_not_allowed"
+	AccessOutOfSchedule     AccessDenyReason = "out_of_schedule"
+	AccessHoliday           AccessDenyReason = "holiday"
+)
+
+// CheckAccess checks whether person with access groups ags may pass passage
+// at local time t which is holiday or not. It returns empty reason if access
+// is granted and IDs of access groups which allow the passage.
+func CheckAccess(ags []AccessGroup, passageID string, t time.Time, holiday bool) (AccessDenyReason, []int64) {
+	if len(ags) == 0 {
+		return AccessNoGroup, nil
+	}
+
+	var agIDs []int64
+
+	for _, ag := range ags {
+		if !ag.hasPassage(passageID) {
+			continue
+		}
+
+		agIDs = append(agIDs, ag.ID)
+
+		if ag.allows(t, holiday) {
+			return "", agIDs
+		}
+	}
+
+	if len(agIDs) == 0 {
+		return AccessPassageNotAllowed, nil
+	}
+
+	if holiday {
+		return AccessHoliday, agIDs
+	}
+
+	return AccessOutOfSchedule, agIDs
+}
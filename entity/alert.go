@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AlertType string
+
+const (
+	WatchlistAlert AlertType = "watchlist_hit"
+)
+
+// AlertStatus is alert workflow state: active alert is acknowledged by
+// operator who handles it and then resolved.
+type AlertStatus string
+
+const (
+	AlertActive       AlertStatus = "active"
+	AlertAcknowledged AlertStatus = "acknowledged"
+	AlertResolved     AlertStatus = "resolved"
+)
+
+type Alert struct {
+	ID             int64           `json:"id" db:"id"`
+	Time           time.Time       `json:"time" db:"time"`
+	Type           AlertType       `json:"type" db:"type"`
+	Status         AlertStatus     `json:"status" db:"status"`
+	PassageID      string          `json:"passage_id" db:"passage_id"`
+	PersonID       *int64          `json:"person_id" db:"person_id"`
+	Data           json.RawMessage `json:"data" db:"data"`
+	AcknowledgedBy *string         `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at" db:"acknowledged_at"`
+	ResolvedBy     *string         `json:"resolved_by" db:"resolved_by"`
+	ResolvedAt     *time.Time      `json:"resolved_at" db:"resolved_at"`
+	Comment        string          `json:"comment" db:"comment"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type ControllerEventKind string
+
+const (
+	ControllerDoorOpen    ControllerEventKind = "door_open"
+	ControllerCardPresent ControllerEventKind = "card_present"
+	ControllerDoorbell    ControllerEventKind = "doorbell"
+	ControllerCall        ControllerEventKind = "call"
+)
+
+// ControllerEvent is event reported by passage controller itself, like door
+// opened by controller, card presented to its reader or intercom doorbell.
+type ControllerEvent struct {
+	Kind      ControllerEventKind
+	Direction Direction
+	Card      string
+	Time      time.Time
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+type CredentialType string
+
+const (
+	Card CredentialType = "card"
+	PIN  CredentialType = "pin"
+)
+
+// Credential is person's card number or PIN code used as the second factor
+// or instead of face depending on passage mode.
+type Credential struct {
+	ID       int64          `json:"id" db:"id"`
+	PersonID int64          `json:"person_id" db:"person_id"`
+	Type     CredentialType `json:"type" db:"type"`
+	Value    string         `json:"value" db:"value"`
+}
+
+// CredentialRead is card read or PIN entry reported by passage controller.
+type CredentialRead struct {
+	Type  CredentialType
+	Value string
+	Time  time.Time
+}
+
+type PassageMode string
+
+const (
+	FaceMode        PassageMode = "face"
+	FaceOrCardMode  PassageMode = "face_or_card"
+	FaceAndCardMode PassageMode = "face_and_card"
+)
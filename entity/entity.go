@@ -20,15 +20,21 @@ type RecognizedFace struct {
 	DetectedFace
 	Descriptor    FaceDescriptor
 	RecognizeTime time.Time
+	// Liveness is score from 0 for spoofed face to 1 for live one, nil if
+	// liveness is not checked.
+	Liveness *float64
 }
 
 type PassageType string
 
 const (
-	Z5R   PassageType = "z5r"
-	Sigur PassageType = "sigur"
-	Dummy PassageType = "dummy"
+	Z5R    PassageType = "z5r"
+	Sigur  PassageType = "sigur"
+	Dummy  PassageType = "dummy"
 	Beward PassageType = "beward"
+	HTTP   PassageType = "http"
+	Modbus PassageType = "modbus"
+	MQTT   PassageType = "mqtt"
 )
 
 type Direction string
@@ -38,6 +44,26 @@ const (
 	Out Direction = "out"
 )
 
+// PassbackMode is anti-passback mode of a zone. In strict mode person can't
+// enter zone twice without leaving it and leave it twice without entering,
+// in soft mode such passages are allowed but recorded.
+type PassbackMode string
+
+const (
+	PassbackOff    PassbackMode = ""
+	PassbackSoft   PassbackMode = "soft"
+	PassbackStrict PassbackMode = "strict"
+)
+
+// PassbackState is the last passage of person in or out of a zone.
+type PassbackState struct {
+	PersonID  int64     `json:"person_id" db:"person_id"`
+	Zone      string    `json:"zone" db:"zone"`
+	Direction Direction `json:"direction" db:"direction"`
+	PassageID string    `json:"passage_id" db:"passage_id"`
+	Time      time.Time `json:"time" db:"time"`
+}
+
 var EqualFacesMaxDistance float32 = 0.5
 
 type Role string
@@ -54,11 +80,14 @@ type User struct {
 	Role         Role   `json:"role" db:"role"`
 }
 
+// Person with not empty Watchlist category is never let in, recognizing
+// such person raises an alert.
 type Person struct {
-	ID       int64  `json:"id" db:"id"`
-	Name     string `json:"name" db:"name"`
-	Position string `json:"position" db:"position"`
-	Unit     string `json:"unit" db:"unit"`
+	ID        int64  `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	Position  string `json:"position" db:"position"`
+	Unit      string `json:"unit" db:"unit"`
+	Watchlist string `json:"watchlist" db:"watchlist"`
 }
 
 type PersonFace struct {
@@ -67,3 +96,38 @@ type PersonFace struct {
 	Descriptor FaceDescriptor `json:"descriptor" db:"descriptor"`
 	PhotoID    string         `json:"photo_id" db:"photo_id"`
 }
+
+type PersonFaceMatch struct {
+	PersonFace
+	Distance float64 `json:"distance"`
+}
+
+type PersonMatch struct {
+	PersonID int64             `json:"person_id"`
+	Distance float64           `json:"distance"`
+	Faces    []PersonFaceMatch `json:"faces"`
+}
+
+// GroupPersonFaceMatches groups person face matches sorted by distance into
+// person matches, also sorted by distance of the closest person face.
+func GroupPersonFaceMatches(pfms []PersonFaceMatch) []PersonMatch {
+	var (
+		pms   []PersonMatch
+		index = map[int64]int{}
+	)
+
+	for _, pfm := range pfms {
+		i, exists := index[pfm.PersonID]
+		if !exists {
+			i = len(pms)
+			index[pfm.PersonID] = i
+			pms = append(pms, PersonMatch{
+				PersonID: pfm.PersonID,
+				Distance: pfm.Distance,
+			})
+		}
+		pms[i].Faces = append(pms[i].Faces, pfm)
+	}
+
+	return pms
+}
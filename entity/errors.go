@@ -5,6 +5,20 @@ import "errors"
 var (
 	ErrUserNotFound   = errors.New("user not found")
 	ErrPersonNotFound = errors.New("person not found")
+
+	ErrPassbackStateNotFound = errors.New("passback state not found")
+
+	ErrAccessGroupNotFound = errors.New("access group not found")
+	ErrHolidayNotFound     = errors.New("holiday not found")
+
+	ErrAlertNotFound       = errors.New("alert not found")
+	ErrAlertStatusConflict = errors.New("alert status conflict")
+
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	ErrVisitorNotFound = errors.New("visitor not found")
+
+	ErrFaceClusterNotFound = errors.New("face cluster not found")
 )
 
 type InvalidParamErr struct {
@@ -1,7 +1,10 @@
 package entity
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -11,14 +14,35 @@ const (
 	PassageOpen     EventType = "passage_open"
 	FaceRecognize   EventType = "face_recognize"
 	PersonRecognize EventType = "person_recognize"
+	AmbiguousMatch  EventType = "ambiguous_match"
+	PassbackDenied  EventType = "passback_denied"
+	AccessDenied    EventType = "access_denied"
+	Visit           EventType = "visit"
+	WatchlistHit    EventType = "watchlist_hit"
+	LivenessFail    EventType = "liveness_fail"
+	VisitorDenied   EventType = "visitor_denied"
+	Controller      EventType = "controller"
 )
 
+type PassbackReason string
+
+const (
+	PassbackAlreadyIn  PassbackReason = "already_in"
+	PassbackAlreadyOut PassbackReason = "already_out"
+)
+
+// PassageOpenData is recorded when passage is opened. Passage opened
+// manually by operator has OperatorLogin and Reason set, person is optional
+// then.
 type PassageOpenData struct {
-	PersonID       int64  `json:"person_id"`
-	PersonName     string `json:"person_name"`
-	PersonPosition string `json:"person_position"`
-	PersonUnit     string `json:"person_unit"`
-	PassageID      string `json:"passage_id"`
+	PersonID       int64          `json:"person_id"`
+	PersonName     string         `json:"person_name"`
+	PersonPosition string         `json:"person_position"`
+	PersonUnit     string         `json:"person_unit"`
+	PassageID      string         `json:"passage_id"`
+	Credential     CredentialType `json:"credential,omitempty"`
+	OperatorLogin  string         `json:"operator_login,omitempty"`
+	Reason         string         `json:"reason,omitempty"`
 }
 
 type FaceRecognizedData struct {
@@ -38,6 +62,118 @@ type PersonRecognizeData struct {
 	DescriptorsDistance float64        `json:"descriptors_distance"`
 }
 
+type MatchCandidate struct {
+	PersonID   int64   `json:"person_id"`
+	PersonName string  `json:"person_name"`
+	Distance   float64 `json:"distance"`
+}
+
+type AmbiguousMatchData struct {
+	PhotoID          string           `json:"photo_id"`
+	PassageID        string           `json:"passage_id"`
+	DetectConfidence float64          `json:"detect_confidence"`
+	FaceDescriptor   FaceDescriptor   `json:"face_descriptor"`
+	Candidates       []MatchCandidate `json:"candidates"`
+}
+
+// PassbackDeniedData is recorded on anti-passback violation. In soft mode
+// passage is opened anyway, which is reflected by Opened.
+type PassbackDeniedData struct {
+	PhotoID         string         `json:"photo_id"`
+	PersonID        int64          `json:"person_id"`
+	PersonName      string         `json:"person_name"`
+	PersonPosition  string         `json:"person_position"`
+	PersonUnit      string         `json:"person_unit"`
+	PassageID       string         `json:"passage_id"`
+	Zone            string         `json:"zone"`
+	Mode            PassbackMode   `json:"mode"`
+	Reason          PassbackReason `json:"reason"`
+	LastPassageID   string         `json:"last_passage_id"`
+	LastPassageTime time.Time      `json:"last_passage_time"`
+	Opened          bool           `json:"opened"`
+}
+
+// AccessDeniedData is recorded when person's access groups don't allow to
+// pass. AccessGroupIDs are groups allowing the passage but not at the time.
+type AccessDeniedData struct {
+	PhotoID        string           `json:"photo_id"`
+	PersonID       int64            `json:"person_id"`
+	PersonName     string           `json:"person_name"`
+	PersonPosition string           `json:"person_position"`
+	PersonUnit     string           `json:"person_unit"`
+	PassageID      string           `json:"passage_id"`
+	Reason         AccessDenyReason `json:"reason"`
+	AccessGroupIDs []int64          `json:"access_group_ids"`
+	Holiday        string           `json:"holiday,omitempty"`
+}
+
+// VisitData collapses repeated recognitions of person at passage. Photo is
+// the best matching one.
+type VisitData struct {
+	PhotoID             string    `json:"photo_id"`
+	PersonID            int64     `json:"person_id"`
+	PersonName          string    `json:"person_name"`
+	PersonPosition      string    `json:"person_position"`
+	PersonUnit          string    `json:"person_unit"`
+	PassageID           string    `json:"passage_id"`
+	FirstSeen           time.Time `json:"first_seen"`
+	LastSeen            time.Time `json:"last_seen"`
+	Recognitions        int       `json:"recognitions"`
+	DetectConfidence    float64   `json:"detect_confidence"`
+	DescriptorsDistance float64   `json:"descriptors_distance"`
+	Opened              bool      `json:"opened"`
+}
+
+type WatchlistHitData struct {
+	PhotoID             string         `json:"photo_id"`
+	PersonID            int64          `json:"person_id"`
+	PersonName          string         `json:"person_name"`
+	PersonPosition      string         `json:"person_position"`
+	PersonUnit          string         `json:"person_unit"`
+	Watchlist           string         `json:"watchlist"`
+	PassageID           string         `json:"passage_id"`
+	DetectConfidence    float64        `json:"detect_confidence"`
+	FaceDescriptor      FaceDescriptor `json:"face_descriptor"`
+	DescriptorsDistance float64        `json:"descriptors_distance"`
+}
+
+// VisitorDeniedData is recorded when visitor pass doesn't allow to pass.
+type VisitorDeniedData struct {
+	PhotoID      string            `json:"photo_id"`
+	PersonID     int64             `json:"person_id"`
+	PersonName   string            `json:"person_name"`
+	HostPersonID *int64            `json:"host_person_id"`
+	PassageID    string            `json:"passage_id"`
+	Reason       VisitorDenyReason `json:"reason"`
+	ValidFrom    time.Time         `json:"valid_from"`
+	ValidTo      time.Time         `json:"valid_to"`
+	Entries      int               `json:"entries"`
+	MaxEntries   int               `json:"max_entries"`
+}
+
+// ControllerData is recorded for passage controller events. Person is set
+// if presented card is person's credential.
+type ControllerData struct {
+	PassageID  string              `json:"passage_id"`
+	Kind       ControllerEventKind `json:"kind"`
+	Direction  Direction           `json:"direction"`
+	Card       string              `json:"card,omitempty"`
+	PersonID   int64               `json:"person_id,omitempty"`
+	PersonName string              `json:"person_name,omitempty"`
+}
+
+// LivenessFailData is recorded when matched face is not live enough to open
+// passage. Liveness is absent when face was not checked by facer.
+type LivenessFailData struct {
+	PhotoID             string   `json:"photo_id"`
+	PersonID            int64    `json:"person_id"`
+	PassageID           string   `json:"passage_id"`
+	Liveness            *float64 `json:"liveness"`
+	LivenessThreshold   float64  `json:"liveness_threshold"`
+	DetectConfidence    float64  `json:"detect_confidence"`
+	DescriptorsDistance float64  `json:"descriptors_distance"`
+}
+
 type Event struct {
 	ID   int64           `json:"id" db:"id"`
 	Time time.Time       `json:"time" db:"time"`
@@ -46,6 +182,37 @@ type Event struct {
 	Data json.RawMessage `json:"data" db:"data"`
 }
 
+// WithoutFaceDescriptor returns event or alert data without face descriptor,
+// which is biometric data and mustn't leave the system. Data which is not a
+// JSON object is returned as is.
+func WithoutFaceDescriptor(data json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return data
+	}
+
+	if _, exists := fields["face_descriptor"]; !exists {
+		return data
+	}
+
+	delete(fields, "face_descriptor")
+
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return data
+	}
+
+	return stripped
+}
+
+type EventPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
 type EventsFilters struct {
 	From time.Time
 	To   time.Time
@@ -64,12 +231,51 @@ type EventsFilters struct {
 	Offset int
 	Limit  int
 
+	After     EventsCursor
+	WithTotal bool
+
 	Set struct {
 		From, To, Type, PassageID, PersonID, PersonName, PersonPosition, PersonUnit, OrderBy, OrderDirection,
-		OffSet, Limit bool
+		OffSet, Limit, After, WithTotal bool
 	}
 }
 
+// EventsCursor points to the last event of events page ordered by time and
+// id, next page starts right after it.
+type EventsCursor struct {
+	Time time.Time
+	ID   int64
+}
+
+var ErrInvalidEventsCursor = errors.New("invalid events cursor")
+
+func (c EventsCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%d:%d", c.Time.UnixNano(), c.ID)))
+}
+
+func ParseEventsCursor(s string) (EventsCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return EventsCursor{}, ErrInvalidEventsCursor
+	}
+
+	var nsec, id int64
+
+	_, err = fmt.Sscanf(string(b), "%d:%d", &nsec, &id)
+	if err != nil {
+		return EventsCursor{}, ErrInvalidEventsCursor
+	}
+
+	return EventsCursor{Time: time.Unix(0, nsec), ID: id}, nil
+}
+
+type EventsPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      *int64  `json:"total,omitempty"`
+}
+
 type EventsFilter func(p *EventsFilters)
 
 func EventsFrom(from time.Time) EventsFilter {
@@ -114,6 +320,20 @@ func EventsPersonName(name string) EventsFilter {
 	}
 }
 
+func EventsPersonPosition(position string) EventsFilter {
+	return func(p *EventsFilters) {
+		p.PersonPosition = position
+		p.Set.PersonPosition = true
+	}
+}
+
+func EventsPersonUnit(unit string) EventsFilter {
+	return func(p *EventsFilters) {
+		p.PersonUnit = unit
+		p.Set.PersonUnit = true
+	}
+}
+
 func EventsOrderBy(orderBy string) EventsFilter {
 	return func(p *EventsFilters) {
 		p.OrderBy = orderBy
@@ -141,3 +361,17 @@ func EventsOffset(offset int) EventsFilter {
 		p.Set.OffSet = true
 	}
 }
+
+func EventsAfter(c EventsCursor) EventsFilter {
+	return func(p *EventsFilters) {
+		p.After = c
+		p.Set.After = true
+	}
+}
+
+func EventsWithTotal() EventsFilter {
+	return func(p *EventsFilters) {
+		p.WithTotal = true
+		p.Set.WithTotal = true
+	}
+}
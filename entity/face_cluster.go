@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FaceCluster is candidate identity made of similar unknown faces. Descriptor
+// is the mean of its faces descriptors, PhotoIDs are photos of faces closest
+// to it.
+type FaceCluster struct {
+	ID         int64          `json:"id" db:"id"`
+	Descriptor FaceDescriptor `json:"-" db:"descriptor"`
+	Count      int            `json:"count" db:"count"`
+	FirstSeen  time.Time      `json:"first_seen" db:"first_seen"`
+	LastSeen   time.Time      `json:"last_seen" db:"last_seen"`
+	PhotoIDs   pq.StringArray `json:"photo_ids" db:"photo_ids"`
+}
+
+// AddFace adds face descriptor seen at time t to cluster and returns its
+// distance to cluster descriptor before the addition.
+func (fc *FaceCluster) AddFace(fd FaceDescriptor, t time.Time) float64 {
+	if fc.Count == 0 {
+		fc.Descriptor = fd
+		fc.Count = 1
+		fc.FirstSeen = t
+		fc.LastSeen = t
+		return 0
+	}
+
+	distance := FaceDescriptorDistance(fc.Descriptor, fd)
+
+	n := float32(fc.Count)
+	for i := range fc.Descriptor {
+		fc.Descriptor[i] = (fc.Descriptor[i]*n + fd[i]) / (n + 1)
+	}
+
+	fc.Count++
+
+	if t.Before(fc.FirstSeen) {
+		fc.FirstSeen = t
+	}
+	if t.After(fc.LastSeen) {
+		fc.LastSeen = t
+	}
+
+	return distance
+}
+
+// FaceClusterFace is unknown face of face_recognize event put to cluster.
+type FaceClusterFace struct {
+	ID         int64          `json:"id" db:"id"`
+	ClusterID  int64          `json:"cluster_id" db:"cluster_id"`
+	EventID    int64          `json:"event_id" db:"event_id"`
+	Time       time.Time      `json:"time" db:"time"`
+	Descriptor FaceDescriptor `json:"-" db:"descriptor"`
+	PhotoID    string         `json:"photo_id" db:"photo_id"`
+	Distance   float64        `json:"distance" db:"distance"`
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+type PassageHealth string
+
+const (
+	PassageHealthUnknown PassageHealth = "unknown"
+	PassageHealthOK      PassageHealth = "ok"
+	PassageHealthFailed  PassageHealth = "failed"
+)
+
+// PassageStatus is passage controller state as seen by skuder. Health is
+// updated by periodic pings and by passage openings, Error is the last
+// failure reason.
+type PassageStatus struct {
+	PassageID    string        `json:"passage_id"`
+	Type         PassageType   `json:"type"`
+	Direction    Direction     `json:"direction"`
+	LastOpenTime time.Time     `json:"last_open_time"`
+	Health       PassageHealth `json:"health"`
+	Error        string        `json:"error,omitempty"`
+	CheckTime    time.Time     `json:"check_time"`
+}
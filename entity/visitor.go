@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Visitor is temporary person like contractor or guest invited by host
+// person. Visitor may pass PassageIDs from ValidFrom to ValidTo and enter at
+// most MaxEntries times, zero MaxEntries is unlimited. Visitor faces are
+// enrolled as person faces and removed once the pass expires.
+type Visitor struct {
+	PersonID     int64          `json:"person_id" db:"person_id"`
+	Name         string         `json:"name" db:"name"`
+	HostPersonID *int64         `json:"host_person_id" db:"host_person_id"`
+	Purpose      string         `json:"purpose" db:"purpose"`
+	ValidFrom    time.Time      `json:"valid_from" db:"valid_from"`
+	ValidTo      time.Time      `json:"valid_to" db:"valid_to"`
+	PassageIDs   pq.StringArray `json:"passage_ids" db:"passage_ids"`
+	MaxEntries   int            `json:"max_entries" db:"max_entries"`
+	Entries      int            `json:"entries" db:"entries"`
+	FacesRemoved bool           `json:"faces_removed" db:"faces_removed"`
+}
+
+func (v Visitor) Validate() error {
+	if strings.TrimSpace(v.Name) == "" {
+		return errors.New("name is empty")
+	}
+	if v.HostPersonID == nil {
+		return errors.New("host_person_id is empty")
+	}
+	if v.ValidFrom.IsZero() || v.ValidTo.IsZero() || !v.ValidFrom.Before(v.ValidTo) {
+		return errors.New("validity window is invalid")
+	}
+	if len(v.PassageIDs) == 0 {
+		return errors.New("passage_ids is empty")
+	}
+	if v.MaxEntries < 0 {
+		return errors.New("max_entries is invalid")
+	}
+	return nil
+}
+
+type VisitorDenyReason string
+
+const (
+	VisitorNotYetValid       VisitorDenyReason = "not_yet_valid"
+	VisitorExpired           VisitorDenyReason = "expired"
+	VisitorPassageNotAllowed VisitorDenyReason = "passage_not_allowed"
+	VisitorEntriesExhausted  VisitorDenyReason = "entries_exhausted"
+)
+
+// Check checks whether visitor may pass passage in direction at time t. It
+// returns empty reason if pass allows it. Entries limit applies to entering
+// only, so visitor who used all of them still may leave.
+func (v Visitor) Check(passageID string, d Direction, t time.Time) VisitorDenyReason {
+	if t.Before(v.ValidFrom) {
+		return VisitorNotYetValid
+	}
+	if !t.Before(v.ValidTo) {
+		return VisitorExpired
+	}
+
+	var allowed bool
+
+	for _, id := range v.PassageIDs {
+		if id == passageID {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return VisitorPassageNotAllowed
+	}
+
+	if d == In && v.MaxEntries > 0 && v.Entries >= v.MaxEntries {
+		return VisitorEntriesExhausted
+	}
+
+	return ""
+}
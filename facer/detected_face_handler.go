@@ -19,14 +19,22 @@ type RecognizedFacePublisher interface {
 
 type DetectedFaceHandler struct {
 	confidenceLimit         float64
+	livenessCheckers        []LivenessChecker
 	faceRecognizer          FaceRecognizer
 	recognizedFacePublisher RecognizedFacePublisher
 	log                     *logrus.Entry
 }
 
-func NewDetectedFaceHandler(confidenceLimit float64, fr FaceRecognizer, rfp RecognizedFacePublisher) *DetectedFaceHandler {
+// NewDetectedFaceHandler creates handler which recognizes detected faces and
+// publishes them. Liveness score of face is the lowest one of liveness
+// checkers, it is not set if there are no checkers or none of them scored the
+// face.
+func NewDetectedFaceHandler(confidenceLimit float64, lcs []LivenessChecker, fr FaceRecognizer,
+	rfp RecognizedFacePublisher) *DetectedFaceHandler {
+
 	return &DetectedFaceHandler{
 		confidenceLimit:         confidenceLimit,
+		livenessCheckers:        lcs,
 		faceRecognizer:          fr,
 		recognizedFacePublisher: rfp,
 		log:                     logrus.WithField("subsystem", "facer_detected_face_handler"),
@@ -51,6 +59,14 @@ func (dfh *DetectedFaceHandler) HandleDetectedFace(df entity.DetectedFace) {
 		}
 	}()
 
+	var liveness *float64
+
+	if len(dfh.livenessCheckers) > 0 {
+		liveness, err = minLiveness(dfh.livenessCheckers, df, photo)
+		if err != nil {
+			dfh.log.WithError(err).Error("failed to check liveness")
+		}
+	}
 
 	descr, err := dfh.faceRecognizer.RecognizeFace(photo)
 	if err != nil {
@@ -62,5 +78,6 @@ func (dfh *DetectedFaceHandler) HandleDetectedFace(df entity.DetectedFace) {
 		DetectedFace:  df,
 		Descriptor:    descr,
 		RecognizeTime: time.Now(),
+		Liveness:      liveness,
 	})
 }
@@ -0,0 +1,292 @@
+package facer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// LivenessChecker scores how likely detected face belongs to live person
+// rather than to printed photo or screen replay. Score is from 0 for spoofed
+// face to 1 for live one. ErrNotScored is returned if checker can't score
+// the face yet.
+type LivenessChecker interface {
+	CheckLiveness(df entity.DetectedFace, photo gocv.Mat) (float64, error)
+}
+
+var ErrNotScored = errors.New("liveness is not scored")
+
+// livenessPhotoSize is side of square face photos are scaled to before
+// liveness checks, so scores don't depend on face size in frame.
+const livenessPhotoSize = 128
+
+// livenessLowFrequencyRadius is radius of DFT spectrum low frequency area,
+// which carries face shape rather than skin texture.
+const livenessLowFrequencyRadius = livenessPhotoSize / 8
+
+var errEmptyPhoto = errors.New("empty photo")
+
+// grayPhoto converts photo to grayscale one of livenessPhotoSize.
+func grayPhoto(photo gocv.Mat) (gocv.Mat, error) {
+	if photo.Empty() {
+		return gocv.Mat{}, errEmptyPhoto
+	}
+
+	gray := gocv.NewMat()
+
+	switch photo.Channels() {
+	case 1:
+		photo.CopyTo(&gray)
+	case 3:
+		gocv.CvtColor(photo, &gray, gocv.ColorBGRToGray)
+	case 4:
+		gocv.CvtColor(photo, &gray, gocv.ColorBGRAToGray)
+	default:
+		_ = gray.Close()
+		return gocv.Mat{}, fmt.Errorf("unsupported channels count %d", photo.Channels())
+	}
+
+	gocv.Resize(gray, &gray, image.Pt(livenessPhotoSize, livenessPhotoSize), 0, 0,
+		gocv.InterpolationLinear)
+
+	return gray, nil
+}
+
+// ramp maps x to [0, 1] linearly growing from 0 at from to 1 at to.
+func ramp(x, from, to float64) float64 {
+	if x <= from {
+		return 0
+	}
+	if x >= to {
+		return 1
+	}
+	return (x - from) / (to - from)
+}
+
+// TextureLivenessChecker scores face by its fine texture. Recaptured prints
+// and screens are blurred and lose skin details, so both sharpness (variance
+// of Laplacian) and share of high frequencies in DFT spectrum are lower than
+// for live faces. Each of them gives half of the score growing linearly up to
+// its limit.
+type TextureLivenessChecker struct {
+	sharpnessLimit     float64
+	highFrequencyLimit float64
+}
+
+func NewTextureLivenessChecker(sharpnessLimit float64, highFrequencyLimit float64) *TextureLivenessChecker {
+	return &TextureLivenessChecker{
+		sharpnessLimit:     sharpnessLimit,
+		highFrequencyLimit: highFrequencyLimit,
+	}
+}
+
+func (tlc *TextureLivenessChecker) CheckLiveness(_ entity.DetectedFace, photo gocv.Mat) (float64, error) {
+	gray, err := grayPhoto(photo)
+	if err != nil {
+		return 0, fmt.Errorf("gray photo: %w", err)
+	}
+	defer gray.Close()
+
+	sharpness := laplacianVariance(gray)
+	highFrequency := highFrequencyShare(gray)
+
+	return ramp(sharpness, 0, tlc.sharpnessLimit)/2 +
+		ramp(highFrequency, 0, tlc.highFrequencyLimit)/2, nil
+}
+
+func laplacianVariance(gray gocv.Mat) float64 {
+	lap := gocv.NewMat()
+	defer lap.Close()
+
+	gocv.Laplacian(gray, &lap, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault)
+
+	mean := gocv.NewMat()
+	defer mean.Close()
+
+	std := gocv.NewMat()
+	defer std.Close()
+
+	gocv.MeanStdDev(lap, &mean, &std)
+
+	sd := std.GetDoubleAt(0, 0)
+
+	return sd * sd
+}
+
+// highFrequencyShare returns share of DFT spectrum magnitude outside low
+// frequency area, DC component excluded.
+func highFrequencyShare(gray gocv.Mat) float64 {
+	f := gocv.NewMat()
+	defer f.Close()
+
+	gray.ConvertTo(&f, gocv.MatTypeCV32F)
+
+	spectrum := gocv.NewMat()
+	defer spectrum.Close()
+
+	gocv.DFT(f, &spectrum, gocv.DftComplexOutput)
+
+	planes := gocv.Split(spectrum)
+	defer func() {
+		for _, p := range planes {
+			_ = p.Close()
+		}
+	}()
+
+	if len(planes) != 2 {
+		return 0
+	}
+
+	mag := gocv.NewMat()
+	defer mag.Close()
+
+	gocv.Magnitude(planes[0], planes[1], &mag)
+
+	var total, high float64
+
+	rows, cols := mag.Rows(), mag.Cols()
+
+	// Spectrum is not shifted, so low frequencies are in its corners.
+	for y := 0; y < rows; y++ {
+		fy := y
+		if rows-y < fy {
+			fy = rows - y
+		}
+		for x := 0; x < cols; x++ {
+			if x == 0 && y == 0 {
+				continue
+			}
+			fx := x
+			if cols-x < fx {
+				fx = cols - x
+			}
+
+			m := float64(mag.GetFloatAt(y, x))
+
+			total += m
+			if fx*fx+fy*fy > livenessLowFrequencyRadius*livenessLowFrequencyRadius {
+				high += m
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return high / total
+}
+
+type motionFrame struct {
+	gray gocv.Mat
+	time time.Time
+}
+
+// MotionLivenessChecker scores face by its motion between consecutive frames
+// of a stream. Photo held in front of camera is almost still, while live face
+// always moves a little: mean absolute difference of consecutive face photos
+// from minMotion to maxMotion scores 1 and falls linearly to 0 outside of the
+// range. Larger difference means rigid waving of photo or other face. Face
+// without previous frame within maxInterval is not scored, so passage is
+// opened on frames after the first one. Streams are expected to show one face
+// at once.
+type MotionLivenessChecker struct {
+	minMotion   float64
+	maxMotion   float64
+	maxInterval time.Duration
+
+	frames   map[string]motionFrame
+	framesMx sync.Mutex
+}
+
+func NewMotionLivenessChecker(minMotion float64, maxMotion float64, maxInterval time.Duration) *MotionLivenessChecker {
+	return &MotionLivenessChecker{
+		minMotion:   minMotion,
+		maxMotion:   maxMotion,
+		maxInterval: maxInterval,
+		frames:      map[string]motionFrame{},
+	}
+}
+
+func (mlc *MotionLivenessChecker) CheckLiveness(df entity.DetectedFace, photo gocv.Mat) (float64, error) {
+	gray, err := grayPhoto(photo)
+	if err != nil {
+		return 0, fmt.Errorf("gray photo: %w", err)
+	}
+
+	mlc.framesMx.Lock()
+	defer mlc.framesMx.Unlock()
+
+	prev, exists := mlc.frames[df.StreamID]
+
+	mlc.frames[df.StreamID] = motionFrame{gray: gray, time: df.FrameTime}
+
+	if !exists {
+		return 0, ErrNotScored
+	}
+
+	defer prev.gray.Close()
+
+	interval := df.FrameTime.Sub(prev.time)
+	if interval <= 0 || interval > mlc.maxInterval {
+		return 0, ErrNotScored
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+
+	gocv.AbsDiff(prev.gray, gray, &diff)
+
+	motion := diff.Mean().Val1
+
+	if motion < mlc.minMotion {
+		return ramp(motion, 0, mlc.minMotion), nil
+	}
+	if motion > mlc.maxMotion {
+		return 1 - ramp(motion, mlc.maxMotion, 2*mlc.maxMotion), nil
+	}
+
+	return 1, nil
+}
+
+// Close frees remembered frames.
+func (mlc *MotionLivenessChecker) Close() {
+	mlc.framesMx.Lock()
+	defer mlc.framesMx.Unlock()
+
+	for streamID, f := range mlc.frames {
+		_ = f.gray.Close()
+		delete(mlc.frames, streamID)
+	}
+}
+
+// minLiveness returns the lowest score of liveness checkers, so face must
+// pass all of them. Checkers which didn't score face are skipped, score is
+// nil if none of them did.
+func minLiveness(lcs []LivenessChecker, df entity.DetectedFace, photo gocv.Mat) (*float64, error) {
+	var score *float64
+
+	for _, lc := range lcs {
+		s, err := lc.CheckLiveness(df, photo)
+		if err == ErrNotScored {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if score == nil {
+			score = &s
+		} else {
+			*score = math.Min(*score, s)
+		}
+	}
+
+	return score, nil
+}
@@ -6,6 +6,7 @@ require (
 	github.com/Boostport/migration v0.21.0
 	github.com/GeertJohan/go.rice v1.0.2
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/gobuffalo/packr v1.30.1
 	github.com/iancoleman/strcase v0.1.3
 	github.com/jmoiron/sqlx v1.3.1
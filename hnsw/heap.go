@@ -0,0 +1,84 @@
+package hnsw
+
+type candidate struct {
+	id   int32
+	dist float32
+}
+
+type minHeap []candidate
+
+func (h *minHeap) push(c candidate) {
+	*h = append(*h, c)
+	hs := *h
+	for i := len(hs) - 1; i > 0; {
+		p := (i - 1) / 2
+		if hs[p].dist <= hs[i].dist {
+			break
+		}
+		hs[p], hs[i] = hs[i], hs[p]
+		i = p
+	}
+}
+
+func (h *minHeap) pop() candidate {
+	hs := *h
+	top := hs[0]
+	last := len(hs) - 1
+	hs[0] = hs[last]
+	hs = hs[:last]
+	for i := 0; ; {
+		l, r, m := 2*i+1, 2*i+2, i
+		if l < len(hs) && hs[l].dist < hs[m].dist {
+			m = l
+		}
+		if r < len(hs) && hs[r].dist < hs[m].dist {
+			m = r
+		}
+		if m == i {
+			break
+		}
+		hs[m], hs[i] = hs[i], hs[m]
+		i = m
+	}
+	*h = hs
+	return top
+}
+
+type maxHeap []candidate
+
+func (h *maxHeap) push(c candidate) {
+	*h = append(*h, c)
+	hs := *h
+	for i := len(hs) - 1; i > 0; {
+		p := (i - 1) / 2
+		if hs[p].dist >= hs[i].dist {
+			break
+		}
+		hs[p], hs[i] = hs[i], hs[p]
+		i = p
+	}
+}
+
+func (h *maxHeap) pop() candidate {
+	hs := *h
+	top := hs[0]
+	last := len(hs) - 1
+	hs[0] = hs[last]
+	hs = hs[:last]
+	for i := 0; ; {
+		l, r, m := 2*i+1, 2*i+2, i
+		if l < len(hs) && hs[l].dist > hs[m].dist {
+			m = l
+		}
+		if r < len(hs) && hs[r].dist > hs[m].dist {
+			m = r
+		}
+		if m == i {
+			break
+		}
+		hs[m], hs[i] = hs[i], hs[m]
+		i = m
+	}
+	*h = hs
+	return top
+}
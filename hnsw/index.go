@@ -0,0 +1,374 @@
+package hnsw
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Config holds HNSW graph parameters. M is the number of neighbours kept per
+// node on upper layers (2*M on the ground layer), EfConstruction and EfSearch
+// are the candidate list sizes used on insert and on search.
+type Config struct {
+	M              int `yaml:"m"`
+	EfConstruction int `yaml:"ef_construction"`
+	EfSearch       int `yaml:"ef_search"`
+}
+
+var DefaultConfig = Config{
+	M:              16,
+	EfConstruction: 200,
+	EfSearch:       64,
+}
+
+// staleDeletedRatio is the share of deleted nodes after which the graph
+// should be rebuilt from the live nodes.
+const staleDeletedRatio = 0.5
+
+type node struct {
+	pf      entity.PersonFace
+	friends [][]int32
+	deleted bool
+}
+
+// Index is a hierarchical navigable small world graph over person face
+// descriptors. It is not safe for concurrent modification: callers must
+// serialize Reset, Add and Remove against each other and against Search.
+// Concurrent Search calls are safe.
+type Index struct {
+	cfg       Config
+	levelMult float64
+	rnd       *rand.Rand
+
+	nodes    []node
+	ids      map[int64]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+
+	visited sync.Pool
+}
+
+func New(cfg Config) *Index {
+	if cfg.M < 2 {
+		cfg.M = DefaultConfig.M
+	}
+	if cfg.EfConstruction < cfg.M {
+		cfg.EfConstruction = DefaultConfig.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = DefaultConfig.EfSearch
+	}
+
+	idx := &Index{
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		rnd:       rand.New(rand.NewSource(1)),
+	}
+
+	idx.clear()
+
+	return idx
+}
+
+func (idx *Index) clear() {
+	idx.nodes = nil
+	idx.ids = map[int64]int32{}
+	idx.entry = -1
+	idx.maxLevel = 0
+	idx.deleted = 0
+}
+
+func (idx *Index) Len() int {
+	return len(idx.ids)
+}
+
+func (idx *Index) Reset(pfs []entity.PersonFace) {
+	idx.clear()
+	idx.nodes = make([]node, 0, len(pfs))
+	for _, pf := range pfs {
+		idx.Add(pf)
+	}
+}
+
+func (idx *Index) Add(pf entity.PersonFace) {
+	if _, exists := idx.ids[pf.ID]; exists {
+		idx.Remove(pf.ID)
+	}
+
+	level := int(-math.Log(1-idx.rnd.Float64()) * idx.levelMult)
+
+	n := int32(len(idx.nodes))
+	idx.nodes = append(idx.nodes, node{
+		pf:      pf,
+		friends: make([][]int32, level+1),
+	})
+	idx.ids[pf.ID] = n
+
+	if idx.entry < 0 {
+		idx.entry = n
+		idx.maxLevel = level
+		return
+	}
+
+	q := pf.Descriptor
+	ep := idx.entry
+
+	for l := idx.maxLevel; l > level; l-- {
+		ep = idx.greedyClosest(q, ep, l)
+	}
+
+	for l := min(level, idx.maxLevel); l >= 0; l-- {
+		cs := idx.searchLayer(q, ep, idx.cfg.EfConstruction, l)
+
+		friends := idx.selectNeighbours(cs, idx.cfg.M)
+		idx.nodes[n].friends[l] = friends
+
+		for _, f := range friends {
+			idx.link(f, n, l)
+		}
+
+		ep = cs[0].id
+	}
+
+	if level > idx.maxLevel {
+		idx.maxLevel = level
+		idx.entry = n
+	}
+}
+
+// Remove marks person face as deleted. Deleted nodes are still used to
+// navigate the graph but never returned, so the graph should be rebuilt once
+// it is stale.
+func (idx *Index) Remove(personFaceID int64) {
+	n, exists := idx.ids[personFaceID]
+	if !exists {
+		return
+	}
+
+	delete(idx.ids, personFaceID)
+	idx.nodes[n].deleted = true
+	idx.deleted++
+
+	if len(idx.ids) == 0 {
+		idx.clear()
+	}
+}
+
+// Stale reports whether too many deleted nodes piled up in the graph.
+func (idx *Index) Stale() bool {
+	return float64(idx.deleted) > staleDeletedRatio*float64(len(idx.nodes))
+}
+
+func (idx *Index) Search(fd entity.FaceDescriptor, k int) []entity.PersonFaceMatch {
+	if idx.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	ep := idx.entry
+
+	for l := idx.maxLevel; l > 0; l-- {
+		ep = idx.greedyClosest(fd, ep, l)
+	}
+
+	ef := idx.cfg.EfSearch
+	if ef < k {
+		ef = k
+	}
+
+	var ms []entity.PersonFaceMatch
+
+	for _, c := range idx.searchLayer(fd, ep, ef, 0) {
+		nd := idx.nodes[c.id]
+		if nd.deleted {
+			continue
+		}
+		ms = append(ms, entity.PersonFaceMatch{
+			PersonFace: nd.pf,
+			Distance:   math.Sqrt(float64(c.dist)),
+		})
+		if len(ms) == k {
+			break
+		}
+	}
+
+	return ms
+}
+
+func (idx *Index) link(from, to int32, level int) {
+	friends := append(idx.nodes[from].friends[level], to)
+
+	maxFriends := idx.cfg.M
+	if level == 0 {
+		maxFriends *= 2
+	}
+
+	if len(friends) > maxFriends {
+		q := idx.nodes[from].pf.Descriptor
+		cs := make([]candidate, len(friends))
+		for i, f := range friends {
+			cs[i] = candidate{id: f, dist: squaredDistance(q, idx.nodes[f].pf.Descriptor)}
+		}
+		sort.Slice(cs, func(i, j int) bool { return cs[i].dist < cs[j].dist })
+		friends = idx.selectNeighbours(cs, maxFriends)
+	}
+
+	idx.nodes[from].friends[level] = friends
+}
+
+// selectNeighbours implements the HNSW neighbour selection heuristic: a
+// candidate is kept only if it is closer to the query than to any neighbour
+// kept so far, which keeps the graph navigable across clusters. Candidates
+// must be sorted by distance.
+func (idx *Index) selectNeighbours(cs []candidate, m int) []int32 {
+	friends := make([]int32, 0, m)
+
+	for _, c := range cs {
+		if len(friends) == m {
+			break
+		}
+		good := true
+		for _, f := range friends {
+			if squaredDistance(idx.nodes[c.id].pf.Descriptor, idx.nodes[f].pf.Descriptor) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			friends = append(friends, c.id)
+		}
+	}
+
+	if len(friends) < m {
+		for _, c := range cs {
+			if len(friends) == m {
+				break
+			}
+			if !containsID(friends, c.id) {
+				friends = append(friends, c.id)
+			}
+		}
+	}
+
+	return friends
+}
+
+func (idx *Index) greedyClosest(q entity.FaceDescriptor, ep int32, level int) int32 {
+	dist := squaredDistance(q, idx.nodes[ep].pf.Descriptor)
+
+	for changed := true; changed; {
+		changed = false
+		for _, f := range idx.nodes[ep].friends[level] {
+			d := squaredDistance(q, idx.nodes[f].pf.Descriptor)
+			if d < dist {
+				ep, dist, changed = f, d, true
+			}
+		}
+	}
+
+	return ep
+}
+
+// searchLayer returns up to ef closest to q nodes of the level sorted by
+// distance.
+func (idx *Index) searchLayer(q entity.FaceDescriptor, ep int32, ef int, level int) []candidate {
+	vs := idx.acquireVisited()
+	defer idx.visited.Put(vs)
+
+	vs.visit(ep)
+
+	epc := candidate{id: ep, dist: squaredDistance(q, idx.nodes[ep].pf.Descriptor)}
+
+	candidates := minHeap{epc}
+	results := maxHeap{epc}
+
+	for len(candidates) > 0 {
+		c := candidates.pop()
+		if c.dist > results[0].dist && len(results) >= ef {
+			break
+		}
+
+		for _, f := range idx.nodes[c.id].friends[level] {
+			if !vs.visit(f) {
+				continue
+			}
+			d := squaredDistance(q, idx.nodes[f].pf.Descriptor)
+			if len(results) < ef || d < results[0].dist {
+				candidates.push(candidate{id: f, dist: d})
+				results.push(candidate{id: f, dist: d})
+				if len(results) > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].dist < results[j].dist })
+
+	return results
+}
+
+func (idx *Index) acquireVisited() *visitedSet {
+	vs, _ := idx.visited.Get().(*visitedSet)
+	if vs == nil {
+		vs = &visitedSet{}
+	}
+	vs.reset(len(idx.nodes))
+	return vs
+}
+
+type visitedSet struct {
+	marks []uint32
+	gen   uint32
+}
+
+func (vs *visitedSet) reset(size int) {
+	if len(vs.marks) < size {
+		vs.marks = make([]uint32, size)
+		vs.gen = 0
+	}
+	vs.gen++
+	if vs.gen == 0 {
+		for i := range vs.marks {
+			vs.marks[i] = 0
+		}
+		vs.gen = 1
+	}
+}
+
+// visit marks node as visited and reports whether it was not visited before.
+func (vs *visitedSet) visit(n int32) bool {
+	if vs.marks[n] == vs.gen {
+		return false
+	}
+	vs.marks[n] = vs.gen
+	return true
+}
+
+func squaredDistance(a, b entity.FaceDescriptor) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+func containsID(ids []int32, id int32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
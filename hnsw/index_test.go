@@ -0,0 +1,265 @@
+package hnsw_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/hnsw"
+)
+
+const (
+	testPersons        = 500
+	testFacesPerPerson = 4
+)
+
+func randomDescriptor(rnd *rand.Rand) (fd entity.FaceDescriptor) {
+	var norm float64
+	for i := range fd {
+		v := rnd.NormFloat64()
+		fd[i] = float32(v)
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	for i := range fd {
+		fd[i] /= float32(norm)
+	}
+	return fd
+}
+
+func noisyDescriptor(rnd *rand.Rand, fd entity.FaceDescriptor, sigma float64) entity.FaceDescriptor {
+	for i := range fd {
+		fd[i] += float32(rnd.NormFloat64() * sigma)
+	}
+	return fd
+}
+
+// clusteredGallery generates faces of persons scattered around person's own
+// point, as faces of the same person are closer to each other than to faces
+// of other persons.
+func clusteredGallery(rnd *rand.Rand, persons int) []entity.PersonFace {
+	var pfs []entity.PersonFace
+
+	for p := 1; p <= persons; p++ {
+		center := randomDescriptor(rnd)
+		for i := 0; i < testFacesPerPerson; i++ {
+			pfs = append(pfs, entity.PersonFace{
+				ID:         int64(len(pfs) + 1),
+				PersonID:   int64(p),
+				Descriptor: noisyDescriptor(rnd, center, 0.03),
+			})
+		}
+	}
+
+	return pfs
+}
+
+func exactSearch(pfs []entity.PersonFace, fd entity.FaceDescriptor, k int) []entity.PersonFaceMatch {
+	ms := make([]entity.PersonFaceMatch, 0, len(pfs))
+	for _, pf := range pfs {
+		ms = append(ms, entity.PersonFaceMatch{
+			PersonFace: pf,
+			Distance:   entity.FaceDescriptorDistance(pf.Descriptor, fd),
+		})
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Distance < ms[j].Distance })
+
+	if len(ms) > k {
+		ms = ms[:k]
+	}
+
+	return ms
+}
+
+func TestIndexSearchRecall(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pfs := clusteredGallery(rnd, testPersons)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+	idx.Reset(pfs)
+
+	if idx.Len() != len(pfs) {
+		t.Fatalf("got length %d, want %d", idx.Len(), len(pfs))
+	}
+
+	const (
+		queries = 200
+		k       = 5
+	)
+
+	var hits int
+
+	for i := 0; i < queries; i++ {
+		q := noisyDescriptor(rnd, pfs[rnd.Intn(len(pfs))].Descriptor, 0.01)
+
+		ms := idx.Search(q, k)
+		if len(ms) != k {
+			t.Fatalf("got %d matches, want %d", len(ms), k)
+		}
+
+		for j, m := range ms {
+			if d := entity.FaceDescriptorDistance(m.Descriptor, q); math.Abs(d-m.Distance) > 1e-4 {
+				t.Fatalf("got match distance %f, want %f", m.Distance, d)
+			}
+			if j > 0 && m.Distance < ms[j-1].Distance {
+				t.Fatalf("matches are not sorted by distance: %+v", ms)
+			}
+		}
+
+		exact := exactSearch(pfs, q, k)
+
+		for _, em := range exact {
+			for _, m := range ms {
+				if m.ID == em.ID {
+					hits++
+					break
+				}
+			}
+		}
+	}
+
+	if recall := float64(hits) / (queries * k); recall < 0.95 {
+		t.Fatalf("got recall@%d %.3f, want at least 0.95", k, recall)
+	}
+}
+
+func TestIndexAdd(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	pfs := clusteredGallery(rnd, 50)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+	for _, pf := range pfs {
+		idx.Add(pf)
+	}
+
+	// Adding face with the same ID replaces it.
+	moved := pfs[0]
+	moved.Descriptor = randomDescriptor(rnd)
+	idx.Add(moved)
+
+	if idx.Len() != len(pfs) {
+		t.Fatalf("got length %d, want %d", idx.Len(), len(pfs))
+	}
+
+	ms := idx.Search(moved.Descriptor, 1)
+	if len(ms) != 1 || ms[0].ID != moved.ID || ms[0].Distance > 1e-4 {
+		t.Fatalf("got matches %+v, want replaced face %d", ms, moved.ID)
+	}
+
+	ms = idx.Search(pfs[0].Descriptor, len(pfs))
+	for _, m := range ms {
+		if m.ID == moved.ID && m.Distance < 1e-4 {
+			t.Fatalf("replaced descriptor of face %d is still found", moved.ID)
+		}
+	}
+}
+
+func TestIndexRemove(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	pfs := clusteredGallery(rnd, 100)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+	idx.Reset(pfs)
+
+	removed := map[int64]bool{}
+
+	for _, pf := range pfs {
+		if pf.PersonID%2 == 0 {
+			idx.Remove(pf.ID)
+			removed[pf.ID] = true
+		}
+	}
+
+	idx.Remove(int64(len(pfs) + 1))
+
+	if idx.Len() != len(pfs)-len(removed) {
+		t.Fatalf("got length %d, want %d", idx.Len(), len(pfs)-len(removed))
+	}
+
+	for _, pf := range pfs {
+		ms := idx.Search(pf.Descriptor, 3)
+		if len(ms) != 3 {
+			t.Fatalf("got %d matches, want 3", len(ms))
+		}
+
+		for _, m := range ms {
+			if removed[m.ID] {
+				t.Fatalf("removed face %d is found", m.ID)
+			}
+		}
+
+		if !removed[pf.ID] && ms[0].ID != pf.ID {
+			t.Fatalf("got closest face %d, want face %d itself", ms[0].ID, pf.ID)
+		}
+	}
+
+	for _, pf := range pfs {
+		idx.Remove(pf.ID)
+	}
+
+	if idx.Len() != 0 || len(idx.Search(pfs[0].Descriptor, 1)) != 0 {
+		t.Fatal("emptied index still finds faces")
+	}
+}
+
+func TestIndexStale(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	pfs := clusteredGallery(rnd, 25)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+	idx.Reset(pfs)
+
+	for i, pf := range pfs[:len(pfs)/2] {
+		if idx.Stale() {
+			t.Fatalf("index is stale after %d of %d faces removed", i, len(pfs))
+		}
+		idx.Remove(pf.ID)
+	}
+
+	idx.Remove(pfs[len(pfs)/2].ID)
+
+	if !idx.Stale() {
+		t.Fatal("index is not stale after more than a half of faces removed")
+	}
+
+	idx.Reset(pfs[len(pfs)/2+1:])
+
+	if idx.Stale() {
+		t.Fatal("index is stale after reset")
+	}
+}
+
+func BenchmarkIndexSearch(b *testing.B) {
+	rnd := rand.New(rand.NewSource(5))
+	pfs := clusteredGallery(rnd, 2500)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+	idx.Reset(pfs)
+
+	queries := make([]entity.FaceDescriptor, 1000)
+	for i := range queries {
+		queries[i] = noisyDescriptor(rnd, pfs[rnd.Intn(len(pfs))].Descriptor, 0.01)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		idx.Search(queries[i%len(queries)], 2)
+	}
+}
+
+func BenchmarkIndexAdd(b *testing.B) {
+	rnd := rand.New(rand.NewSource(6))
+	pfs := clusteredGallery(rnd, b.N/testFacesPerPerson+1)
+
+	idx := hnsw.New(hnsw.DefaultConfig)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		idx.Add(pfs[i])
+	}
+}
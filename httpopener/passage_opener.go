@@ -0,0 +1,350 @@
+// Package httpopener implements passage opener which sends templated HTTP
+// request, so relay boards and access control systems with HTTP API are driven
+// by config instead of code.
+package httpopener
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
+)
+
+func init() {
+	passage.Register(entity.HTTP, func(c passage.Config) (passage.Opener, error) {
+		var o Options
+
+		err := c.DecodeOptions(&o)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewPassageOpener(c.PassageID, c.Direction, c.Address, o)
+	})
+}
+
+const (
+	defaultMethod  = http.MethodPost
+	defaultTimeout = 5 * time.Second
+
+	// maxResponseSize limits response body matched with response regexp.
+	maxResponseSize = 1 << 20
+)
+
+// Options of HTTP passage opener. Method, headers and body are templates like
+// URL. Request is basic authorized if username is not empty. Response must
+// have one of status codes, any 2xx by default, and its body must match
+// response regexp if it is not empty. Ping checks that ping URL responds
+// without server error, or that URL host accepts connections if ping URL is
+// empty.
+type Options struct {
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Body           string            `yaml:"body"`
+	Username       string            `yaml:"username"`
+	Password       string            `yaml:"password"`
+	Timeout        string            `yaml:"timeout"`
+	StatusCodes    []int             `yaml:"status_codes"`
+	ResponseRegexp string            `yaml:"response_regexp"`
+	PingURL        string            `yaml:"ping_url"`
+}
+
+// TemplateData is available to templates. Person is zero if passage is opened
+// without person, e.g. manually without person or in face mode without it.
+// Values are not escaped, so person fields in URL must be passed through
+// urlquery and in JSON body through json.
+type TemplateData struct {
+	Passage struct {
+		ID        string
+		Direction entity.Direction
+	}
+	Person entity.Person
+	Time   time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	// json marshals value, e.g. {"name": {{json .Person.Name}}}.
+	"json": func(v interface{}) (string, error) {
+		j, err := json.Marshal(v)
+		return string(j), err
+	},
+}
+
+type header struct {
+	name  string
+	value *template.Template
+}
+
+// PassageOpener opens passage by sending HTTP request rendered from
+// templates.
+type PassageOpener struct {
+	passageID      string
+	direction      entity.Direction
+	method         *template.Template
+	url            *template.Template
+	headers        []header
+	body           *template.Template
+	username       string
+	password       string
+	statusCodes    map[int]bool
+	responseRegexp *regexp.Regexp
+	pingURL        string
+	timeout        time.Duration
+	http           *http.Client
+
+	lastOpen   time.Time
+	lastOpenMx sync.Mutex
+}
+
+func NewPassageOpener(passageID string, direction entity.Direction, urlTemplate string, o Options) (*PassageOpener, error) {
+	po := &PassageOpener{
+		passageID:   passageID,
+		direction:   direction,
+		username:    o.Username,
+		password:    o.Password,
+		statusCodes: map[int]bool{},
+		pingURL:     o.PingURL,
+		timeout:     defaultTimeout,
+	}
+
+	var err error
+
+	if o.Timeout != "" {
+		po.timeout, err = time.ParseDuration(o.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout parse: %w", err)
+		}
+	}
+
+	po.http = &http.Client{Timeout: po.timeout}
+
+	if o.Method == "" {
+		o.Method = defaultMethod
+	}
+
+	po.method, err = parseTemplate("method", o.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	po.url, err = parseTemplate("url", urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	po.body, err = parseTemplate("body", o.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range o.Headers {
+		h := header{name: name}
+
+		h.value, err = parseTemplate("header "+name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		po.headers = append(po.headers, h)
+	}
+
+	sort.Slice(po.headers, func(i, j int) bool {
+		return po.headers[i].name < po.headers[j].name
+	})
+
+	for _, sc := range o.StatusCodes {
+		if sc < 100 || sc > 599 {
+			return nil, fmt.Errorf("invalid status code %d", sc)
+		}
+		po.statusCodes[sc] = true
+	}
+
+	if o.ResponseRegexp != "" {
+		po.responseRegexp, err = regexp.Compile(o.ResponseRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("response_regexp compile: %w", err)
+		}
+	}
+
+	// URL of zero data is checked, so config errors are found at start.
+	_, err = po.newRequest(po.templateData(entity.Person{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return po, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s template parse: %w", name, err)
+	}
+	return t, nil
+}
+
+func execute(t *template.Template, data TemplateData) (string, error) {
+	var b strings.Builder
+
+	err := t.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("%s template execute: %w", t.Name(), err)
+	}
+
+	return b.String(), nil
+}
+
+func (po *PassageOpener) templateData(p entity.Person) TemplateData {
+	var data TemplateData
+
+	data.Passage.ID = po.passageID
+	data.Passage.Direction = po.direction
+	data.Person = p
+	data.Time = time.Now()
+
+	return data
+}
+
+func (po *PassageOpener) newRequest(data TemplateData) (*http.Request, error) {
+	method, err := execute(po.method, data)
+	if err != nil {
+		return nil, err
+	}
+
+	uri, err := execute(po.url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := execute(po.body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = bytes.NewReader([]byte(body))
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(strings.TrimSpace(method)), strings.TrimSpace(uri), bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("new HTTP request: %w", err)
+	}
+
+	for _, h := range po.headers {
+		value, err := execute(h.value, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(h.name, value)
+	}
+
+	if po.username != "" {
+		req.SetBasicAuth(po.username, po.password)
+	}
+
+	return req, nil
+}
+
+func (po *PassageOpener) OpenPassage() error {
+	return po.OpenPassageFor(entity.Person{})
+}
+
+// OpenPassageFor sends request rendered for person.
+func (po *PassageOpener) OpenPassageFor(p entity.Person) error {
+	req, err := po.newRequest(po.templateData(p))
+	if err != nil {
+		return err
+	}
+
+	res, err := po.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if len(po.statusCodes) == 0 {
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("expected 2xx status code but got %d", res.StatusCode)
+		}
+	} else if !po.statusCodes[res.StatusCode] {
+		return fmt.Errorf("unexpected %d status code", res.StatusCode)
+	}
+
+	if po.responseRegexp != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+		if err != nil {
+			return fmt.Errorf("read response body: %w", err)
+		}
+
+		if !po.responseRegexp.Match(body) {
+			return errors.New("response doesn't match response_regexp")
+		}
+	}
+
+	po.lastOpenMx.Lock()
+	po.lastOpen = time.Now()
+	po.lastOpenMx.Unlock()
+
+	return nil
+}
+
+func (po *PassageOpener) LastOpenTime() time.Time {
+	po.lastOpenMx.Lock()
+	defer po.lastOpenMx.Unlock()
+	return po.lastOpen
+}
+
+func (po *PassageOpener) Ping() error {
+	if po.pingURL != "" {
+		res, err := po.http.Get(po.pingURL)
+		if err != nil {
+			return fmt.Errorf("HTTP get: %w", err)
+		}
+
+		_ = res.Body.Close()
+
+		if res.StatusCode >= 500 {
+			return fmt.Errorf("unexpected %d status code", res.StatusCode)
+		}
+
+		return nil
+	}
+
+	req, err := po.newRequest(po.templateData(entity.Person{}))
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", hostPort(req.URL), po.timeout)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	return conn.Close()
+}
+
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
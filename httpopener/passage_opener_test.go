@@ -0,0 +1,304 @@
+package httpopener_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/httpopener"
+)
+
+// request is request received by test server.
+type request struct {
+	Method   string
+	Path     string
+	Query    url.Values
+	Header   http.Header
+	Body     string
+	Username string
+	Password string
+}
+
+type testServer struct {
+	*httptest.Server
+
+	status   int
+	body     string
+	requests []request
+	mx       sync.Mutex
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{status: http.StatusOK}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		req := request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Header: r.Header,
+			Body:   string(body),
+		}
+		req.Username, req.Password, _ = r.BasicAuth()
+
+		s.mx.Lock()
+		s.requests = append(s.requests, req)
+		status, body := s.status, []byte(s.body)
+		s.mx.Unlock()
+
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testServer) respond(status int, body string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.status = status
+	s.body = body
+}
+
+func (s *testServer) received() []request {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]request{}, s.requests...)
+}
+
+func newTestPassageOpener(t *testing.T, urlTemplate string, o httpopener.Options) *httpopener.PassageOpener {
+	t.Helper()
+
+	po, err := httpopener.NewPassageOpener("some_passage", entity.In, urlTemplate, o)
+	if err != nil {
+		t.Fatalf("create passage opener: %v", err)
+	}
+
+	return po
+}
+
+func TestPassageOpenerRequest(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s.URL+"/doors/{{.Passage.ID}}/open?name={{urlquery .Person.Name}}",
+		httpopener.Options{
+			Method: "{{if eq .Passage.Direction \"in\"}}put{{else}}delete{{end}}",
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"X-Direction":  "{{.Passage.Direction}}",
+			},
+			Body:     `{"person_id": {{.Person.ID}}, "person_name": {{json .Person.Name}}}`,
+			Username: "some_username",
+			Password: "some_password",
+		})
+
+	p := entity.Person{ID: 7, Name: `Some "Person" & Co`}
+
+	err := po.OpenPassageFor(p)
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	rs := s.received()
+	if len(rs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(rs))
+	}
+
+	r := rs[0]
+
+	if r.Method != http.MethodPut || r.Path != "/doors/some_passage/open" {
+		t.Errorf("got %s %s, want PUT /doors/some_passage/open", r.Method, r.Path)
+	}
+
+	if names := r.Query["name"]; len(names) != 1 || names[0] != p.Name || len(r.Query) != 1 {
+		t.Errorf("got query %v, want the only escaped person name", r.Query)
+	}
+
+	if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Direction") != "in" {
+		t.Errorf("got headers %v", r.Header)
+	}
+
+	if r.Username != "some_username" || r.Password != "some_password" {
+		t.Errorf("got basic auth %s:%s", r.Username, r.Password)
+	}
+
+	var body struct {
+		PersonID   int64  `json:"person_id"`
+		PersonName string `json:"person_name"`
+	}
+
+	err = json.Unmarshal([]byte(r.Body), &body)
+	if err != nil {
+		t.Fatalf("JSON unmarshal body %s: %v", r.Body, err)
+	}
+
+	if body.PersonID != p.ID || body.PersonName != p.Name {
+		t.Errorf("got body %s", r.Body)
+	}
+
+	if po.LastOpenTime().IsZero() {
+		t.Error("last open time is not set")
+	}
+
+	// Passage opened without person gets zero person.
+	err = po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage without person: %v", err)
+	}
+
+	if r := s.received()[1]; r.Query.Get("name") != "" || r.Body != `{"person_id": 0, "person_name": ""}` {
+		t.Errorf("got query %v and body %s without person", r.Query, r.Body)
+	}
+}
+
+func TestPassageOpenerStatusCodes(t *testing.T) {
+	s := newTestServer(t)
+
+	po := newTestPassageOpener(t, s.URL, httpopener.Options{})
+
+	for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+		s.respond(status, "")
+
+		err := po.OpenPassage()
+		if err != nil {
+			t.Errorf("open passage with %d response: %v", status, err)
+		}
+	}
+
+	for _, status := range []int{http.StatusNotFound, http.StatusInternalServerError} {
+		s.respond(status, "")
+
+		err := po.OpenPassage()
+		if err == nil {
+			t.Errorf("passage opened with %d response", status)
+		}
+	}
+
+	po = newTestPassageOpener(t, s.URL, httpopener.Options{
+		StatusCodes: []int{http.StatusAccepted, http.StatusNotFound},
+	})
+
+	s.respond(http.StatusOK, "")
+
+	err := po.OpenPassage()
+	if err == nil {
+		t.Error("passage opened with 200 response not in status codes")
+	}
+
+	if !po.LastOpenTime().IsZero() {
+		t.Error("last open time is set after failure")
+	}
+
+	for _, status := range []int{http.StatusAccepted, http.StatusNotFound} {
+		s.respond(status, "")
+
+		err := po.OpenPassage()
+		if err != nil {
+			t.Errorf("open passage with %d response in status codes: %v", status, err)
+		}
+	}
+}
+
+func TestPassageOpenerResponseRegexp(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s.URL, httpopener.Options{
+		ResponseRegexp: `"result":\s*"ok"`,
+	})
+
+	s.respond(http.StatusOK, `{"result": "fail"}`)
+
+	err := po.OpenPassage()
+	if err == nil {
+		t.Fatal("passage opened with not matching response")
+	}
+
+	s.respond(http.StatusOK, `{"result": "ok"}`)
+
+	err = po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage with matching response: %v", err)
+	}
+}
+
+func TestPassageOpenerPing(t *testing.T) {
+	s := newTestServer(t)
+
+	po := newTestPassageOpener(t, s.URL+"/open", httpopener.Options{
+		PingURL: s.URL + "/status",
+	})
+
+	s.respond(http.StatusNotFound, "")
+
+	err := po.Ping()
+	if err != nil {
+		t.Errorf("ping with 404 response: %v", err)
+	}
+
+	s.respond(http.StatusServiceUnavailable, "")
+
+	err = po.Ping()
+	if err == nil {
+		t.Error("ping succeeded with 503 response")
+	}
+
+	if rs := s.received(); len(rs) != 2 || rs[0].Method != http.MethodGet || rs[0].Path != "/status" {
+		t.Errorf("got ping requests %+v, want GET /status", rs)
+	}
+
+	// Without ping URL only connection to URL host is checked.
+	po = newTestPassageOpener(t, s.URL+"/open", httpopener.Options{})
+
+	err = po.Ping()
+	if err != nil {
+		t.Errorf("ping URL host: %v", err)
+	}
+
+	if len(s.received()) != 2 {
+		t.Error("ping without ping URL sent request")
+	}
+
+	s.Close()
+
+	err = po.Ping()
+	if err == nil {
+		t.Error("ping succeeded with URL host down")
+	}
+}
+
+func TestNewPassageOpenerInvalidOptions(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		urlTemplate string
+		options     httpopener.Options
+	}{
+		{name: "url template", urlTemplate: "http://some_host/{{.Passage.ID"},
+		{name: "url field", urlTemplate: "http://some_host/{{.Passage.Name}}"},
+		{name: "url", urlTemplate: "http://some host/"},
+		{name: "method template", options: httpopener.Options{Method: "{{if}}"}},
+		{name: "header template", options: httpopener.Options{Headers: map[string]string{"X-Some": "{{"}}},
+		{name: "body template", options: httpopener.Options{Body: "{{json}"}},
+		{name: "timeout", options: httpopener.Options{Timeout: "5"}},
+		{name: "status code", options: httpopener.Options{StatusCodes: []int{200, 600}}},
+		{name: "response regexp", options: httpopener.Options{ResponseRegexp: "("}},
+	} {
+		if c.urlTemplate == "" {
+			c.urlTemplate = "http://some_host/"
+		}
+
+		_, err := httpopener.NewPassageOpener("some_passage", entity.In, c.urlTemplate, c.options)
+		if err == nil {
+			t.Errorf("%s: passage opener created with invalid options", c.name)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Modbus TCP subset: coils, discrete inputs and holding registers.
+
+// Function codes.
+const (
+	ReadCoilsFunction            = 0x01
+	ReadDiscreteInputsFunction   = 0x02
+	ReadHoldingRegistersFunction = 0x03
+	WriteSingleCoilFunction      = 0x05
+	WriteSingleRegisterFunction  = 0x06
+)
+
+const (
+	// CoilOn and CoilOff are coil values of write single coil request.
+	CoilOn  = 0xFF00
+	CoilOff = 0x0000
+
+	exceptionFlag = 0x80
+
+	mbapHeaderSize = 7
+	maxPDUSize     = 253
+)
+
+// ExceptionError is exception response of device.
+type ExceptionError struct {
+	Function  byte
+	Exception byte
+}
+
+func (e ExceptionError) Error() string {
+	return fmt.Sprintf("function 0x%02x exception 0x%02x", e.Function, e.Exception)
+}
+
+// Client is Modbus TCP client of one unit. Connection is established on the
+// first request and reestablished after a failed one, requests are
+// serialized.
+type Client struct {
+	address string
+	unitID  byte
+	timeout time.Duration
+
+	conn          net.Conn
+	transactionID uint16
+	mx            sync.Mutex
+}
+
+func NewClient(address string, unitID byte, timeout time.Duration) *Client {
+	return &Client{
+		address: address,
+		unitID:  unitID,
+		timeout: timeout,
+	}
+}
+
+// Close closes connection.
+func (c *Client) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// do sends request PDU and returns response PDU data without function code.
+func (c *Client) do(function byte, data []byte) ([]byte, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	resp, err := c.exchange(function, data)
+	if err != nil {
+		var ee ExceptionError
+		if !errors.As(err, &ee) && c.conn != nil {
+			_ = c.conn.Close()
+			c.conn = nil
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (c *Client) exchange(function byte, data []byte) ([]byte, error) {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %w", err)
+		}
+		c.conn = conn
+	}
+
+	err := c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	c.transactionID++
+
+	req := make([]byte, mbapHeaderSize+1+len(data))
+	binary.BigEndian.PutUint16(req[0:], c.transactionID)
+	binary.BigEndian.PutUint16(req[2:], 0)
+	binary.BigEndian.PutUint16(req[4:], uint16(2+len(data)))
+	req[6] = c.unitID
+	req[7] = function
+	copy(req[8:], data)
+
+	_, err = c.conn.Write(req)
+	if err != nil {
+		return nil, fmt.Errorf("write request: %w", err)
+	}
+
+	for {
+		header, pdu, err := ReadFrame(c.conn)
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+
+		// Response to previous timed out request is skipped.
+		if header.TransactionID != c.transactionID {
+			continue
+		}
+
+		if header.UnitID != c.unitID {
+			return nil, fmt.Errorf("response of unit %d", header.UnitID)
+		}
+
+		switch pdu[0] {
+		case function:
+			return pdu[1:], nil
+		case function | exceptionFlag:
+			if len(pdu) != 2 {
+				return nil, errors.New("invalid exception response")
+			}
+			return nil, ExceptionError{Function: function, Exception: pdu[1]}
+		default:
+			return nil, fmt.Errorf("response of function 0x%02x", pdu[0])
+		}
+	}
+}
+
+// Header is MBAP header of Modbus TCP frame.
+type Header struct {
+	TransactionID uint16
+	ProtocolID    uint16
+	UnitID        byte
+}
+
+// ReadFrame reads Modbus TCP frame and returns its header and PDU, which
+// is at least function code long.
+func ReadFrame(r io.Reader) (Header, []byte, error) {
+	mbap := make([]byte, mbapHeaderSize)
+
+	_, err := io.ReadFull(r, mbap)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	h := Header{
+		TransactionID: binary.BigEndian.Uint16(mbap[0:]),
+		ProtocolID:    binary.BigEndian.Uint16(mbap[2:]),
+		UnitID:        mbap[6],
+	}
+
+	length := int(binary.BigEndian.Uint16(mbap[4:]))
+	if h.ProtocolID != 0 || length < 2 || length-1 > maxPDUSize {
+		return Header{}, nil, errors.New("invalid MBAP header")
+	}
+
+	pdu := make([]byte, length-1)
+
+	_, err = io.ReadFull(r, pdu)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	return h, pdu, nil
+}
+
+// WriteFrame writes Modbus TCP frame with PDU.
+func WriteFrame(w io.Writer, h Header, pdu []byte) error {
+	frame := make([]byte, mbapHeaderSize+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], h.TransactionID)
+	binary.BigEndian.PutUint16(frame[2:], h.ProtocolID)
+	binary.BigEndian.PutUint16(frame[4:], uint16(1+len(pdu)))
+	frame[6] = h.UnitID
+	copy(frame[7:], pdu)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+func addressCount(address, count uint16) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:], address)
+	binary.BigEndian.PutUint16(data[2:], count)
+	return data
+}
+
+func (c *Client) readBits(function byte, address, count uint16) ([]bool, error) {
+	resp, err := c.do(function, addressCount(address, count))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) < 1 || int(resp[0]) != len(resp)-1 || len(resp)-1 < (int(count)+7)/8 {
+		return nil, errors.New("invalid response length")
+	}
+
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = resp[1+i/8]&(1<<(uint(i)%8)) != 0
+	}
+
+	return bits, nil
+}
+
+func (c *Client) ReadCoils(address, count uint16) ([]bool, error) {
+	return c.readBits(ReadCoilsFunction, address, count)
+}
+
+func (c *Client) ReadDiscreteInputs(address, count uint16) ([]bool, error) {
+	return c.readBits(ReadDiscreteInputsFunction, address, count)
+}
+
+func (c *Client) ReadHoldingRegisters(address, count uint16) ([]uint16, error) {
+	resp, err := c.do(ReadHoldingRegistersFunction, addressCount(address, count))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) < 1 || int(resp[0]) != len(resp)-1 || len(resp)-1 != 2*int(count) {
+		return nil, errors.New("invalid response length")
+	}
+
+	values := make([]uint16, count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(resp[1+2*i:])
+	}
+
+	return values, nil
+}
+
+func (c *Client) writeSingle(function byte, address, value uint16) error {
+	data := addressCount(address, value)
+
+	resp, err := c.do(function, data)
+	if err != nil {
+		return err
+	}
+
+	if string(resp) != string(data) {
+		return errors.New("response doesn't echo request")
+	}
+
+	return nil
+}
+
+func (c *Client) WriteCoil(address uint16, on bool) error {
+	value := uint16(CoilOff)
+	if on {
+		value = CoilOn
+	}
+	return c.writeSingle(WriteSingleCoilFunction, address, value)
+}
+
+func (c *Client) WriteRegister(address, value uint16) error {
+	return c.writeSingle(WriteSingleRegisterFunction, address, value)
+}
@@ -0,0 +1,151 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/modbus"
+	"github.com/bennyharvey/soma/modbus/modbusfake"
+)
+
+const (
+	testUnitID  = 1
+	testTimeout = 200 * time.Millisecond
+)
+
+func newTestServer(t *testing.T) *modbusfake.Server {
+	t.Helper()
+
+	s, err := modbusfake.NewServer("127.0.0.1:0", testUnitID)
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func newTestClient(t *testing.T, s *modbusfake.Server, unitID byte) *modbus.Client {
+	t.Helper()
+
+	c := modbus.NewClient(s.Addr(), unitID, testTimeout)
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestClientReadWrite(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, testUnitID)
+
+	err := c.WriteCoil(9, true)
+	if err != nil {
+		t.Fatalf("write coil: %v", err)
+	}
+
+	coils, err := c.ReadCoils(8, 3)
+	if err != nil {
+		t.Fatalf("read coils: %v", err)
+	}
+
+	if len(coils) != 3 || coils[0] || !coils[1] || coils[2] {
+		t.Fatalf("got coils %v, want [false true false]", coils)
+	}
+
+	err = c.WriteRegister(5, 0xABCD)
+	if err != nil {
+		t.Fatalf("write register: %v", err)
+	}
+
+	registers, err := c.ReadHoldingRegisters(5, 2)
+	if err != nil {
+		t.Fatalf("read registers: %v", err)
+	}
+
+	if len(registers) != 2 || registers[0] != 0xABCD || registers[1] != 0 {
+		t.Fatalf("got registers %v, want [0xABCD 0]", registers)
+	}
+
+	s.SetDiscreteInput(10, true)
+
+	inputs, err := c.ReadDiscreteInputs(3, 8)
+	if err != nil {
+		t.Fatalf("read discrete inputs: %v", err)
+	}
+
+	for i, on := range inputs {
+		if on != (i == 7) {
+			t.Fatalf("got discrete inputs %v, want only the last one on", inputs)
+		}
+	}
+}
+
+func TestClientException(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, testUnitID)
+
+	_, err := c.ReadCoils(0, 0)
+
+	var ee modbus.ExceptionError
+	if !errors.As(err, &ee) || ee != (modbus.ExceptionError{Function: modbus.ReadCoilsFunction, Exception: 0x03}) {
+		t.Fatalf("got error %v, want illegal data value exception", err)
+	}
+
+	s.SetException(modbus.WriteSingleCoilFunction, 0x04)
+
+	err = c.WriteCoil(0, true)
+	if !errors.As(err, &ee) || ee != (modbus.ExceptionError{Function: modbus.WriteSingleCoilFunction, Exception: 0x04}) {
+		t.Fatalf("got error %v, want device failure exception", err)
+	}
+
+	s.SetException(modbus.WriteSingleCoilFunction, 0)
+
+	err = c.WriteCoil(0, true)
+	if err != nil {
+		t.Fatalf("write coil: %v", err)
+	}
+
+	if s.Connections() != 1 {
+		t.Fatalf("got %d connections, exceptions must not reconnect", s.Connections())
+	}
+}
+
+func TestClientUnitIDMismatch(t *testing.T) {
+	s := newTestServer(t)
+
+	_, err := newTestClient(t, s, testUnitID+1).ReadCoils(0, 1)
+	if err == nil {
+		t.Fatal("read succeeded without response of unit")
+	}
+
+	s.SetResponseUnitID(testUnitID + 1)
+
+	_, err = newTestClient(t, s, testUnitID).ReadCoils(0, 1)
+	if err == nil {
+		t.Fatal("read succeeded with response of other unit")
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, testUnitID)
+
+	err := c.WriteCoil(0, true)
+	if err != nil {
+		t.Fatalf("write coil: %v", err)
+	}
+
+	s.DropConnections()
+
+	// Request on dropped connection may fail, the next one reconnects.
+	_, err = c.ReadCoils(0, 1)
+	if err != nil {
+		_, err = c.ReadCoils(0, 1)
+	}
+
+	if err != nil {
+		t.Fatalf("read coils after reconnect: %v", err)
+	}
+}
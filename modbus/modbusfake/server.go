@@ -0,0 +1,317 @@
+// Package modbusfake implements fake Modbus TCP device with coils, discrete
+// inputs and holding registers. It is used to try modbus passage opener
+// without real relay module.
+package modbusfake
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bennyharvey/soma/modbus"
+)
+
+// Modbus exception codes.
+const (
+	illegalFunction    = 0x01
+	illegalDataAddress = 0x02
+	illegalDataValue   = 0x03
+)
+
+const registersCount = 1 << 16
+
+// Write is coil or register write received by device, Value is 1 or 0 for
+// coils.
+type Write struct {
+	Function byte
+	Address  uint16
+	Value    uint16
+	Time     time.Time
+}
+
+// Server is fake Modbus TCP device of one unit.
+type Server struct {
+	unitID byte
+
+	listener       net.Listener
+	coils          [registersCount]bool
+	discreteInputs [registersCount]bool
+	registers      [registersCount]uint16
+	links          map[uint16]uint16
+	writes         []Write
+	exceptions     map[byte]byte
+	responseUnitID *byte
+	conns          map[net.Conn]struct{}
+	closed         bool
+	mx             sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+// NewServer creates device listening on address, like 127.0.0.1:0, and
+// starts serving.
+func NewServer(address string, unitID byte) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := &Server{
+		unitID:     unitID,
+		listener:   l,
+		links:      map[uint16]uint16{},
+		exceptions: map[byte]byte{},
+		conns:      map[net.Conn]struct{}{},
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns address device listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops listening and closes connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mx.Lock()
+	s.closed = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+// DropConnections closes client connections.
+func (s *Server) DropConnections() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// Connections returns count of client connections.
+func (s *Server) Connections() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.conns)
+}
+
+// SetException makes device respond to requests of function with exception
+// code, zero code clears it.
+func (s *Server) SetException(function, code byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if code == 0 {
+		delete(s.exceptions, function)
+	} else {
+		s.exceptions[function] = code
+	}
+}
+
+// SetResponseUnitID makes device respond with unit ID other than its own,
+// like misconfigured gateway does.
+func (s *Server) SetResponseUnitID(unitID byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.responseUnitID = &unitID
+}
+
+// LinkInput makes discrete input follow coil, like door sensor follows lock
+// relay.
+func (s *Server) LinkInput(coil, input uint16) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.links[coil] = input
+	s.discreteInputs[input] = s.coils[coil]
+}
+
+func (s *Server) SetDiscreteInput(address uint16, on bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.discreteInputs[address] = on
+}
+
+func (s *Server) Coil(address uint16) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.coils[address]
+}
+
+func (s *Server) Register(address uint16) uint16 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.registers[address]
+}
+
+// Writes returns received writes.
+func (s *Server) Writes() []Write {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]Write{}, s.writes...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mx.Lock()
+		if s.closed {
+			s.mx.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mx.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, conn)
+		s.mx.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	for {
+		h, pdu, err := modbus.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+
+		// Requests to other units are not answered, like gateway does
+		// when unit doesn't respond.
+		if h.UnitID != s.unitID {
+			continue
+		}
+
+		s.mx.Lock()
+		if s.responseUnitID != nil {
+			h.UnitID = *s.responseUnitID
+		}
+		s.mx.Unlock()
+
+		err = modbus.WriteFrame(conn, h, s.process(pdu))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func exception(function, code byte) []byte {
+	return []byte{function | 0x80, code}
+}
+
+func (s *Server) process(pdu []byte) []byte {
+	function := pdu[0]
+
+	if len(pdu) != 5 {
+		switch function {
+		case modbus.ReadCoilsFunction, modbus.ReadDiscreteInputsFunction, modbus.ReadHoldingRegistersFunction,
+			modbus.WriteSingleCoilFunction, modbus.WriteSingleRegisterFunction:
+			return exception(function, illegalDataValue)
+		}
+		return exception(function, illegalFunction)
+	}
+
+	address := binary.BigEndian.Uint16(pdu[1:])
+	value := binary.BigEndian.Uint16(pdu[3:])
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if code, exists := s.exceptions[function]; exists {
+		return exception(function, code)
+	}
+
+	switch function {
+	case modbus.ReadCoilsFunction:
+		return s.readBits(function, s.coils[:], address, value)
+
+	case modbus.ReadDiscreteInputsFunction:
+		return s.readBits(function, s.discreteInputs[:], address, value)
+
+	case modbus.ReadHoldingRegistersFunction:
+		if value == 0 || value > 125 {
+			return exception(function, illegalDataValue)
+		}
+		if int(address)+int(value) > registersCount {
+			return exception(function, illegalDataAddress)
+		}
+		resp := make([]byte, 2+2*int(value))
+		resp[0], resp[1] = function, byte(2*value)
+		for i := 0; i < int(value); i++ {
+			binary.BigEndian.PutUint16(resp[2+2*i:], s.registers[int(address)+i])
+		}
+		return resp
+
+	case modbus.WriteSingleCoilFunction:
+		if value != modbus.CoilOn && value != modbus.CoilOff {
+			return exception(function, illegalDataValue)
+		}
+		on := value == modbus.CoilOn
+		s.coils[address] = on
+		if input, linked := s.links[address]; linked {
+			s.discreteInputs[input] = on
+		}
+		w := Write{Function: function, Address: address, Time: time.Now()}
+		if on {
+			w.Value = 1
+		}
+		s.writes = append(s.writes, w)
+		return pdu
+
+	case modbus.WriteSingleRegisterFunction:
+		s.registers[address] = value
+		s.writes = append(s.writes, Write{Function: function, Address: address, Value: value, Time: time.Now()})
+		return pdu
+	}
+
+	return exception(function, illegalFunction)
+}
+
+func (s *Server) readBits(function byte, bits []bool, address, count uint16) []byte {
+	if count == 0 || count > 2000 {
+		return exception(function, illegalDataValue)
+	}
+	if int(address)+int(count) > registersCount {
+		return exception(function, illegalDataAddress)
+	}
+
+	resp := make([]byte, 2+(int(count)+7)/8)
+	resp[0], resp[1] = function, byte(len(resp)-2)
+
+	for i := 0; i < int(count); i++ {
+		if bits[int(address)+i] {
+			resp[2+i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,353 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
+)
+
+func init() {
+	passage.Register(entity.Modbus, newPassageOpener)
+}
+
+// Target is what passage opener writes to open passage.
+type Target string
+
+const (
+	CoilTarget     Target = "coil"
+	RegisterTarget Target = "register"
+)
+
+const (
+	defaultUnitID         = 1
+	defaultTimeout        = 5 * time.Second
+	defaultPulse          = time.Second
+	defaultOnValue        = 1
+	defaultConfirmTimeout = 2 * time.Second
+
+	confirmPollPeriod = 100 * time.Millisecond
+)
+
+type options struct {
+	UnitID         *int   `yaml:"unit_id"`
+	Target         Target `yaml:"target"`
+	InAddress      *int   `yaml:"in_address"`
+	OutAddress     *int   `yaml:"out_address"`
+	OnValue        *int   `yaml:"on_value"`
+	OffValue       int    `yaml:"off_value"`
+	Pulse          string `yaml:"pulse"`
+	Timeout        string `yaml:"timeout"`
+	ConfirmInput   *int   `yaml:"confirm_input"`
+	ConfirmTimeout string `yaml:"confirm_timeout"`
+}
+
+func parseUint16(name string, v int) (uint16, error) {
+	if v < 0 || v > 0xFFFF {
+		return 0, fmt.Errorf("%s is out of range", name)
+	}
+	return uint16(v), nil
+}
+
+func parseDuration(name, s string, d time.Duration) (time.Duration, error) {
+	if s == "" {
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s parse: %w", name, err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", name)
+	}
+
+	return d, nil
+}
+
+func newPassageOpener(c passage.Config) (passage.Opener, error) {
+	var o options
+
+	err := c.DecodeOptions(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Settings{
+		Target:   o.Target,
+		OnValue:  defaultOnValue,
+		OffValue: uint16(o.OffValue),
+	}
+
+	if s.Target == "" {
+		s.Target = CoilTarget
+	}
+
+	if s.Target != CoilTarget && s.Target != RegisterTarget {
+		return nil, fmt.Errorf("unknown target %s", s.Target)
+	}
+
+	unitID := defaultUnitID
+
+	if o.UnitID != nil {
+		unitID = *o.UnitID
+	}
+
+	if unitID < 0 || unitID > 0xFF {
+		return nil, errors.New("unit_id is out of range")
+	}
+
+	address := o.InAddress
+	if c.Direction == entity.Out {
+		address = o.OutAddress
+	}
+
+	if address == nil {
+		return nil, fmt.Errorf("no %s_address", c.Direction)
+	}
+
+	s.Address, err = parseUint16(string(c.Direction)+"_address", *address)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.OnValue != nil {
+		s.OnValue, err = parseUint16("on_value", *o.OnValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = parseUint16("off_value", o.OffValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.ConfirmInput != nil {
+		confirmInput, err := parseUint16("confirm_input", *o.ConfirmInput)
+		if err != nil {
+			return nil, err
+		}
+		s.ConfirmInput = &confirmInput
+	}
+
+	s.Pulse, err = parseDuration("pulse", o.Pulse, defaultPulse)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ConfirmTimeout, err = parseDuration("confirm_timeout", o.ConfirmTimeout, defaultConfirmTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := parseDuration("timeout", o.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPassageOpener(NewClient(c.Address, byte(unitID), timeout), s), nil
+}
+
+// Settings of passage opener. Coil is switched on or register is set to on
+// value at address to open passage. After non zero pulse coil is switched off
+// or register is set to off value, zero pulse is for devices which pulse
+// relay themselves. If confirm input is set, opening succeeds only if the
+// discrete input turns on within confirm timeout.
+type Settings struct {
+	Target         Target
+	Address        uint16
+	OnValue        uint16
+	OffValue       uint16
+	Pulse          time.Duration
+	ConfirmInput   *uint16
+	ConfirmTimeout time.Duration
+}
+
+// PassageOpener opens passage with relay of Modbus TCP device. If relay
+// fails to switch off after pulse, switching off is retried every pulse and
+// Ping fails until it succeeds.
+type PassageOpener struct {
+	client   *Client
+	settings Settings
+
+	// Relay writes are ordered with pulse state updates by writeMx, so that
+	// pulse ending skips switching relay off once passage is opened again.
+	writeMx sync.Mutex
+
+	lastOpen   time.Time
+	pulses     uint64
+	pulseTimer *time.Timer
+	offPending bool
+	closed     bool
+	mx         sync.Mutex
+
+	log *logrus.Entry
+}
+
+func NewPassageOpener(c *Client, s Settings) *PassageOpener {
+	return &PassageOpener{
+		client:   c,
+		settings: s,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": "modbus_passage_opener",
+			"target":    s.Target,
+			"address":   s.Address,
+		}),
+	}
+}
+
+// Close switches relay off if pulse is in progress and closes connection.
+func (po *PassageOpener) Close() error {
+	po.mx.Lock()
+	po.closed = true
+	pulsing := po.pulseTimer != nil && po.pulseTimer.Stop()
+	pulse := po.pulses
+	po.mx.Unlock()
+
+	if pulsing {
+		po.endPulse(pulse)
+	}
+
+	return po.client.Close()
+}
+
+func (po *PassageOpener) write(on bool) error {
+	if po.settings.Target == CoilTarget {
+		return po.client.WriteCoil(po.settings.Address, on)
+	}
+
+	value := po.settings.OffValue
+	if on {
+		value = po.settings.OnValue
+	}
+
+	return po.client.WriteRegister(po.settings.Address, value)
+}
+
+// endPulse switches relay off after the pulse, unless passage is opened
+// again meanwhile. Failed switching is retried after pulse.
+func (po *PassageOpener) endPulse(pulse uint64) {
+	po.writeMx.Lock()
+	defer po.writeMx.Unlock()
+
+	po.mx.Lock()
+	if pulse != po.pulses {
+		po.mx.Unlock()
+		return
+	}
+	po.pulseTimer = nil
+	po.mx.Unlock()
+
+	err := po.write(false)
+
+	po.mx.Lock()
+	defer po.mx.Unlock()
+
+	po.offPending = err != nil
+
+	if err == nil {
+		return
+	}
+
+	po.log.WithError(err).Error("failed to end relay pulse")
+
+	if !po.closed {
+		po.pulseTimer = time.AfterFunc(po.settings.Pulse, func() { po.endPulse(pulse) })
+	}
+}
+
+// OpenPassage switches relay on and schedules switching it off. Opening
+// during pulse extends it.
+func (po *PassageOpener) OpenPassage() error {
+	po.writeMx.Lock()
+
+	err := po.write(true)
+	if err != nil {
+		po.writeMx.Unlock()
+		return err
+	}
+
+	if po.settings.Pulse > 0 {
+		po.mx.Lock()
+		po.offPending = false
+		po.pulses++
+		if po.pulseTimer != nil {
+			po.pulseTimer.Stop()
+		}
+		pulse := po.pulses
+		po.pulseTimer = time.AfterFunc(po.settings.Pulse, func() { po.endPulse(pulse) })
+		po.mx.Unlock()
+	}
+
+	po.writeMx.Unlock()
+
+	if po.settings.ConfirmInput != nil {
+		err = po.confirm(*po.settings.ConfirmInput)
+		if err != nil {
+			return err
+		}
+	}
+
+	po.mx.Lock()
+	po.lastOpen = time.Now()
+	po.mx.Unlock()
+
+	return nil
+}
+
+// confirm waits for discrete input to turn on.
+func (po *PassageOpener) confirm(input uint16) error {
+	deadline := time.Now().Add(po.settings.ConfirmTimeout)
+
+	for {
+		bits, err := po.client.ReadDiscreteInputs(input, 1)
+		if err != nil {
+			return fmt.Errorf("read confirm input: %w", err)
+		}
+
+		if bits[0] {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New("passage opening is not confirmed by input")
+		}
+
+		time.Sleep(confirmPollPeriod)
+	}
+}
+
+func (po *PassageOpener) LastOpenTime() time.Time {
+	po.mx.Lock()
+	defer po.mx.Unlock()
+	return po.lastOpen
+}
+
+// Ping fails while relay is not switched off after pulse, otherwise it reads
+// relay coil or register.
+func (po *PassageOpener) Ping() error {
+	po.mx.Lock()
+	offPending := po.offPending
+	po.mx.Unlock()
+
+	if offPending {
+		return errors.New("relay is not switched off after pulse")
+	}
+
+	var err error
+
+	if po.settings.Target == CoilTarget {
+		_, err = po.client.ReadCoils(po.settings.Address, 1)
+	} else {
+		_, err = po.client.ReadHoldingRegisters(po.settings.Address, 1)
+	}
+
+	return err
+}
@@ -0,0 +1,294 @@
+package modbus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/modbus"
+	"github.com/bennyharvey/soma/modbus/modbusfake"
+)
+
+const testPulse = 100 * time.Millisecond
+
+func newTestPassageOpener(t *testing.T, s *modbusfake.Server, settings modbus.Settings) *modbus.PassageOpener {
+	t.Helper()
+
+	po := modbus.NewPassageOpener(modbus.NewClient(s.Addr(), testUnitID, testTimeout), settings)
+	t.Cleanup(func() { po.Close() })
+
+	return po
+}
+
+// waitTimeout is how long waitFor waits. It is generous for slow and loaded
+// machines, passing tests don't wait for it.
+const waitTimeout = 10 * time.Second
+
+// waitFor calls f until it returns true or waitTimeout passes.
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func writeValues(ws []modbusfake.Write) []uint16 {
+	values := make([]uint16, 0, len(ws))
+	for _, w := range ws {
+		values = append(values, w.Value)
+	}
+	return values
+}
+
+func TestPassageOpenerCoilPulse(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target:  modbus.CoilTarget,
+		Address: 3,
+		Pulse:   testPulse,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	if !s.Coil(3) {
+		t.Fatal("coil is not switched on")
+	}
+
+	if po.LastOpenTime().IsZero() {
+		t.Error("last open time is not set")
+	}
+
+	waitFor(t, "coil off", func() bool { return !s.Coil(3) })
+
+	ws := s.Writes()
+	if len(ws) != 2 || ws[0].Function != modbus.WriteSingleCoilFunction || ws[0].Address != 3 ||
+		ws[0].Value != 1 || ws[1].Value != 0 {
+		t.Fatalf("got writes %+v, want coil 3 on and off", ws)
+	}
+
+	if pulse := ws[1].Time.Sub(ws[0].Time); pulse < testPulse {
+		t.Errorf("got pulse %s, want at least %s", pulse, testPulse)
+	}
+
+	err = po.Ping()
+	if err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestPassageOpenerRegisterPulse(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target:   modbus.RegisterTarget,
+		Address:  7,
+		OnValue:  5,
+		OffValue: 2,
+		Pulse:    testPulse,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	if s.Register(7) != 5 {
+		t.Fatalf("got register %d, want on value 5", s.Register(7))
+	}
+
+	waitFor(t, "off value", func() bool { return s.Register(7) == 2 })
+
+	values := writeValues(s.Writes())
+	if len(values) != 2 || values[0] != 5 || values[1] != 2 {
+		t.Fatalf("got written values %v, want [5 2]", values)
+	}
+}
+
+func TestPassageOpenerPulseExtension(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target: modbus.CoilTarget,
+		Pulse:  testPulse,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	time.Sleep(testPulse / 2)
+
+	err = po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage again: %v", err)
+	}
+
+	second := time.Now()
+
+	waitFor(t, "coil off", func() bool { return !s.Coil(0) })
+
+	if extended := time.Since(second); extended < testPulse*8/10 {
+		t.Errorf("pulse ended %s after second opening, want about %s", extended, testPulse)
+	}
+
+	time.Sleep(testPulse)
+
+	values := writeValues(s.Writes())
+	if len(values) != 3 || values[0] != 1 || values[1] != 1 || values[2] != 0 {
+		t.Fatalf("got written values %v, want [1 1 0]", values)
+	}
+}
+
+func TestPassageOpenerReopenAtPulseEnd(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target: modbus.CoilTarget,
+		Pulse:  testPulse,
+	})
+
+	// Opening again around the moment pulse ends must never be cut by
+	// switching relay off for the previous pulse.
+	for shift := -5 * time.Millisecond; shift <= 5*time.Millisecond; shift += time.Millisecond {
+		err := po.OpenPassage()
+		if err != nil {
+			t.Fatalf("open passage: %v", err)
+		}
+
+		time.Sleep(testPulse + shift)
+
+		err = po.OpenPassage()
+		if err != nil {
+			t.Fatalf("open passage again: %v", err)
+		}
+
+		time.Sleep(testPulse / 2)
+
+		if !s.Coil(0) {
+			t.Fatalf("coil is switched off right after opening again %s after pulse end", shift)
+		}
+
+		waitFor(t, "coil off", func() bool { return !s.Coil(0) })
+	}
+}
+
+func TestPassageOpenerZeroPulse(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target: modbus.CoilTarget,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	time.Sleep(testPulse)
+
+	if len(s.Writes()) != 1 || !s.Coil(0) {
+		t.Fatalf("got writes %+v, want the only on write", s.Writes())
+	}
+}
+
+func TestPassageOpenerConfirmInput(t *testing.T) {
+	s := newTestServer(t)
+	s.LinkInput(0, 4)
+
+	confirmInput := uint16(4)
+
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target:         modbus.CoilTarget,
+		Pulse:          testPulse,
+		ConfirmInput:   &confirmInput,
+		ConfirmTimeout: time.Second,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open confirmed passage: %v", err)
+	}
+
+	unlinkedInput := uint16(5)
+
+	po = newTestPassageOpener(t, s, modbus.Settings{
+		Target:         modbus.CoilTarget,
+		Address:        1,
+		Pulse:          testPulse,
+		ConfirmInput:   &unlinkedInput,
+		ConfirmTimeout: 150 * time.Millisecond,
+	})
+
+	started := time.Now()
+
+	err = po.OpenPassage()
+	if err == nil {
+		t.Fatal("passage opened without confirmation")
+	}
+
+	if waited := time.Since(started); waited < 150*time.Millisecond {
+		t.Errorf("confirmation waited %s, want at least confirm timeout", waited)
+	}
+
+	if !po.LastOpenTime().IsZero() {
+		t.Error("last open time is set without confirmation")
+	}
+}
+
+func TestPassageOpenerPulseEndRetry(t *testing.T) {
+	s := newTestServer(t)
+	po := newTestPassageOpener(t, s, modbus.Settings{
+		Target: modbus.CoilTarget,
+		Pulse:  testPulse,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	s.SetException(modbus.WriteSingleCoilFunction, 0x04)
+
+	waitFor(t, "ping failure", func() bool { return po.Ping() != nil })
+
+	if !s.Coil(0) {
+		t.Fatal("coil is switched off despite exception")
+	}
+
+	s.SetException(modbus.WriteSingleCoilFunction, 0)
+
+	waitFor(t, "coil off", func() bool { return !s.Coil(0) })
+
+	err = po.Ping()
+	if err != nil {
+		t.Fatalf("ping after coil is switched off: %v", err)
+	}
+}
+
+func TestPassageOpenerCloseEndsPulse(t *testing.T) {
+	s := newTestServer(t)
+
+	po := modbus.NewPassageOpener(modbus.NewClient(s.Addr(), testUnitID, testTimeout), modbus.Settings{
+		Target: modbus.CoilTarget,
+		Pulse:  time.Hour,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	err = po.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if s.Coil(0) {
+		t.Fatal("coil is not switched off on close")
+	}
+}
@@ -0,0 +1,351 @@
+package mqtt_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tiny MQTT 3.1.1 broker: it accepts any client, acknowledges publications
+// of every QoS and forwards them to exact topic subscribers with QoS 0.
+
+const (
+	connectPacket     = 1
+	connackPacket     = 2
+	publishPacket     = 3
+	pubackPacket      = 4
+	pubrecPacket      = 5
+	pubrelPacket      = 6
+	pubcompPacket     = 7
+	subscribePacket   = 8
+	subackPacket      = 9
+	unsubscribePacket = 10
+	unsubackPacket    = 11
+	pingreqPacket     = 12
+	pingrespPacket    = 13
+	disconnectPacket  = 14
+)
+
+// message is publication received by broker.
+type message struct {
+	Topic   string
+	QoS     byte
+	Payload string
+}
+
+type brokerConn struct {
+	net.Conn
+	topics  map[string]bool
+	writeMx sync.Mutex
+}
+
+func (c *brokerConn) writePacket(header byte, body []byte) error {
+	packet := []byte{header}
+
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+
+	_, err := c.Write(append(packet, body...))
+	return err
+}
+
+type broker struct {
+	listener net.Listener
+
+	// onPublish is called with every publication after it is acknowledged.
+	onPublish func(b *broker, m message)
+
+	messages []message
+	conns    map[*brokerConn]struct{}
+	mx       sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+func newBroker(t *testing.T, onPublish func(b *broker, m message)) *broker {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	b := &broker{
+		listener:  l,
+		onPublish: onPublish,
+		conns:     map[*brokerConn]struct{}{},
+	}
+
+	b.wg.Add(1)
+	go b.serve()
+
+	t.Cleanup(b.close)
+
+	return b
+}
+
+func (b *broker) uri() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// close stops listening and closes connections.
+func (b *broker) close() {
+	_ = b.listener.Close()
+
+	b.mx.Lock()
+	for c := range b.conns {
+		_ = c.Close()
+	}
+	b.mx.Unlock()
+
+	b.wg.Wait()
+}
+
+// received returns publications received by broker.
+func (b *broker) received() []message {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return append([]message{}, b.messages...)
+}
+
+func (b *broker) subscribed(topic string) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	for c := range b.conns {
+		if c.topics[topic] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// publish sends publication to topic subscribers.
+func (b *broker) publish(topic, payload string) {
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	for c := range b.conns {
+		if c.topics[topic] {
+			_ = c.writePacket(publishPacket<<4, body)
+		}
+	}
+}
+
+func (b *broker) serve() {
+	defer b.wg.Done()
+
+	for {
+		nc, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &brokerConn{Conn: nc, topics: map[string]bool{}}
+
+		b.mx.Lock()
+		b.conns[c] = struct{}{}
+		b.mx.Unlock()
+
+		b.wg.Add(1)
+		go b.handle(c)
+	}
+}
+
+func (b *broker) handle(c *brokerConn) {
+	defer b.wg.Done()
+
+	defer func() {
+		b.mx.Lock()
+		delete(b.conns, c)
+		b.mx.Unlock()
+
+		_ = c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		err = b.handlePacket(c, header, body)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *broker) handlePacket(c *brokerConn, header byte, body []byte) error {
+	switch header >> 4 {
+	case connectPacket:
+		return c.writePacket(connackPacket<<4, []byte{0, 0})
+
+	case publishPacket:
+		topic, rest, err := readString(body)
+		if err != nil {
+			return err
+		}
+
+		m := message{Topic: topic, QoS: header >> 1 & 0x3}
+
+		if m.QoS > 0 && len(rest) < 2 {
+			return errors.New("no packet ID")
+		}
+
+		switch m.QoS {
+		case 1:
+			err = c.writePacket(pubackPacket<<4, rest[:2])
+		case 2:
+			err = c.writePacket(pubrecPacket<<4, rest[:2])
+		}
+		if err != nil {
+			return err
+		}
+
+		if m.QoS > 0 {
+			rest = rest[2:]
+		}
+
+		m.Payload = string(rest)
+
+		b.mx.Lock()
+		b.messages = append(b.messages, m)
+		b.mx.Unlock()
+
+		if b.onPublish != nil {
+			b.onPublish(b, m)
+		}
+
+		return nil
+
+	case pubrelPacket:
+		return c.writePacket(pubcompPacket<<4, body[:2])
+
+	case subscribePacket:
+		granted := []byte{}
+
+		b.mx.Lock()
+		for rest := body[2:]; len(rest) > 0; rest = rest[1:] {
+			var (
+				topic string
+				err   error
+			)
+
+			topic, rest, err = readString(rest)
+			if err != nil || len(rest) == 0 {
+				b.mx.Unlock()
+				return errors.New("invalid subscribe packet")
+			}
+
+			c.topics[topic] = true
+			granted = append(granted, 0)
+		}
+		b.mx.Unlock()
+
+		return c.writePacket(subackPacket<<4, append(body[:2:2], granted...))
+
+	case unsubscribePacket:
+		return c.writePacket(unsubackPacket<<4, body[:2])
+
+	case pingreqPacket:
+		return c.writePacket(pingrespPacket<<4, nil)
+
+	case disconnectPacket:
+		return io.EOF
+	}
+
+	return errors.New("unexpected packet")
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("invalid string")
+	}
+
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("invalid string")
+	}
+
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// waitTimeout is how long waitFor waits. It is generous for slow and loaded
+// machines, passing tests don't wait for it.
+const waitTimeout = 10 * time.Second
+
+// waitFor calls f until it returns true or waitTimeout passes.
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,57 @@
+// Package mqtt implements passage opener publishing open commands to MQTT
+// broker and bridge publishing soma events to it.
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+var ErrNotConnected = errors.New("not connected to broker")
+
+// ClientConfig is broker connection config. Broker is URI like
+// tcp://host:1883 or ssl://host:8883.
+type ClientConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// NewClient connects to broker. Client reconnects when connection is lost,
+// onConnect is called on every connection, e.g. to subscribe.
+func NewClient(c ClientConfig, onConnect func(paho.Client)) (paho.Client, error) {
+	o := paho.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(c.ClientID).
+		SetUsername(c.Username).
+		SetPassword(c.Password).
+		SetConnectTimeout(c.Timeout).
+		SetWriteTimeout(c.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(onConnect)
+
+	client := paho.NewClient(o)
+
+	// With connect retry token completes on the first successful connection,
+	// so broker being down at start isn't fatal.
+	t := client.Connect()
+	if t.WaitTimeout(c.Timeout) && t.Error() != nil {
+		return nil, fmt.Errorf("connect: %w", t.Error())
+	}
+
+	return client, nil
+}
+
+// wait waits for token completion within timeout.
+func wait(t paho.Token, timeout time.Duration) error {
+	if !t.WaitTimeout(timeout) {
+		return errors.New("timeout")
+	}
+	return t.Error()
+}
@@ -0,0 +1,110 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// EventBridge publishes soma events of given types to MQTT broker. Event of
+// passage is published as JSON EventMessage to <topic prefix>/<passage
+// id>/<event type> topic. Publishing doesn't block event recording, events
+// are dropped while broker is not connected.
+type EventBridge struct {
+	client      paho.Client
+	topicPrefix string
+	qos         byte
+	eventTypes  map[entity.EventType]bool
+	timeout     time.Duration
+
+	log *logrus.Entry
+	wg  sync.WaitGroup
+}
+
+// EventMessage is published event. Its data has no face descriptor and it
+// has no ID, which isn't known before the event is stored.
+type EventMessage struct {
+	Time      time.Time        `json:"time"`
+	PassageID string           `json:"passageID"`
+	Type      entity.EventType `json:"type"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+func NewEventBridge(cc ClientConfig, topicPrefix string, qos byte, eventTypes []entity.EventType) (*EventBridge, error) {
+	client, err := NewClient(cc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	eb := &EventBridge{
+		client:      client,
+		topicPrefix: strings.TrimRight(topicPrefix, "/"),
+		qos:         qos,
+		eventTypes:  map[entity.EventType]bool{},
+		timeout:     cc.Timeout,
+		log:         logrus.WithField("subsystem", "mqtt_event_bridge"),
+	}
+
+	for _, et := range eventTypes {
+		eb.eventTypes[et] = true
+	}
+
+	return eb, nil
+}
+
+// Stop waits for pending publications and disconnects from broker.
+func (eb *EventBridge) Stop() {
+	eb.wg.Wait()
+	eb.client.Disconnect(uint(eb.timeout / time.Millisecond))
+}
+
+// Topic returns topic of passage events of type.
+func (eb *EventBridge) Topic(passageID string, et entity.EventType) string {
+	return eb.topicPrefix + "/" + passageID + "/" + string(et)
+}
+
+// Publish publishes event if its type is bridged.
+func (eb *EventBridge) Publish(e entity.Event) {
+	if !eb.eventTypes[e.Type] || e.PassageID == "" {
+		return
+	}
+
+	log := eb.log.WithFields(logrus.Fields{
+		"event_type": e.Type,
+		"passage_id": e.PassageID,
+	})
+
+	if !eb.client.IsConnectionOpen() {
+		log.Warn("not connected to broker, event dropped")
+		return
+	}
+
+	payload, err := json.Marshal(EventMessage{
+		Time:      e.Time,
+		PassageID: e.PassageID,
+		Type:      e.Type,
+		Data:      entity.WithoutFaceDescriptor(e.Data),
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal event")
+		return
+	}
+
+	t := eb.client.Publish(eb.Topic(e.PassageID, e.Type), eb.qos, false, payload)
+
+	eb.wg.Add(1)
+	go func() {
+		defer eb.wg.Done()
+
+		err := wait(t, eb.timeout)
+		if err != nil {
+			log.WithError(err).Error("failed to publish event")
+		}
+	}()
+}
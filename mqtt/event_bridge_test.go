@@ -0,0 +1,100 @@
+package mqtt_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/mqtt"
+)
+
+func TestEventBridgePublish(t *testing.T) {
+	b := newBroker(t, nil)
+
+	eb, err := mqtt.NewEventBridge(mqtt.ClientConfig{
+		Broker:   b.uri(),
+		ClientID: "soma-event-bridge",
+		Timeout:  testTimeout,
+	}, "soma/passages/", 1, []entity.EventType{entity.PassageOpen, entity.PersonRecognize})
+	if err != nil {
+		t.Fatalf("create event bridge: %v", err)
+	}
+
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	eb.Publish(entity.Event{
+		ID:        1,
+		Time:      tm,
+		PassageID: "p1",
+		Type:      entity.PersonRecognize,
+		Data:      json.RawMessage(`{"person_id":7,"face_descriptor":"AAAA"}`),
+	})
+	eb.Publish(entity.Event{Time: tm, PassageID: "p1", Type: entity.FaceRecognize, Data: json.RawMessage(`{}`)})
+	eb.Publish(entity.Event{Time: tm, Type: entity.PassageOpen, Data: json.RawMessage(`{}`)})
+	eb.Publish(entity.Event{Time: tm, PassageID: "p2", Type: entity.PassageOpen, Data: json.RawMessage(`{"person_id":7}`)})
+
+	eb.Stop()
+
+	ms := b.received()
+	if len(ms) != 2 {
+		t.Fatalf("got publications %+v, want person_recognize of p1 and passage_open of p2", ms)
+	}
+
+	for i, want := range []struct {
+		topic string
+		data  string
+	}{
+		{topic: "soma/passages/p1/person_recognize", data: `{"person_id":7}`},
+		{topic: "soma/passages/p2/passage_open", data: `{"person_id":7}`},
+	} {
+		if ms[i].Topic != want.topic || ms[i].QoS != 1 {
+			t.Fatalf("got publication to %s with qos %d, want %s with qos 1", ms[i].Topic, ms[i].QoS, want.topic)
+		}
+
+		var fields map[string]json.RawMessage
+
+		err = json.Unmarshal([]byte(ms[i].Payload), &fields)
+		if err != nil {
+			t.Fatalf("JSON unmarshal event: %v", err)
+		}
+
+		if _, exists := fields["id"]; exists {
+			t.Errorf("%s: event has ID", want.topic)
+		}
+
+		if string(fields["data"]) != want.data {
+			t.Errorf("%s: got data %s, want %s", want.topic, fields["data"], want.data)
+		}
+
+		var m mqtt.EventMessage
+
+		err = json.Unmarshal([]byte(ms[i].Payload), &m)
+		if err != nil {
+			t.Fatalf("JSON unmarshal event message: %v", err)
+		}
+
+		if !m.Time.Equal(tm) || m.PassageID == "" || m.Type == "" {
+			t.Errorf("%s: got event %+v", want.topic, m)
+		}
+	}
+}
+
+func TestEventBridgeTopic(t *testing.T) {
+	b := newBroker(t, nil)
+
+	eb, err := mqtt.NewEventBridge(mqtt.ClientConfig{
+		Broker:   b.uri(),
+		ClientID: "soma-event-bridge",
+		Timeout:  testTimeout,
+	}, "soma/", 0, nil)
+	if err != nil {
+		t.Fatalf("create event bridge: %v", err)
+	}
+
+	defer eb.Stop()
+
+	if topic := eb.Topic("p1", entity.PassageOpen); topic != "soma/p1/passage_open" {
+		t.Fatalf("got topic %s, want soma/p1/passage_open", topic)
+	}
+}
@@ -0,0 +1,273 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
+)
+
+func init() {
+	passage.Register(entity.MQTT, newPassageOpener)
+}
+
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultAckTimeout = 5 * time.Second
+	clientIDPrefix    = "soma-"
+)
+
+type options struct {
+	ClientID   string `yaml:"client_id"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	Timeout    string `yaml:"timeout"`
+	Topic      string `yaml:"topic"`
+	QoS        byte   `yaml:"qos"`
+	Payload    string `yaml:"payload"`
+	AckTopic   string `yaml:"ack_topic"`
+	AckPayload string `yaml:"ack_payload"`
+	AckTimeout string `yaml:"ack_timeout"`
+}
+
+func parseDuration(name, s string, d time.Duration) (time.Duration, error) {
+	if s == "" {
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s parse: %w", name, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("%s is invalid", name)
+	}
+
+	return d, nil
+}
+
+func newPassageOpener(c passage.Config) (passage.Opener, error) {
+	var o options
+
+	err := c.DecodeOptions(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Topic == "" {
+		return nil, errors.New("no topic")
+	}
+
+	if o.QoS > 2 {
+		return nil, errors.New("qos is invalid")
+	}
+
+	cc := ClientConfig{
+		Broker:   c.Address,
+		ClientID: o.ClientID,
+		Username: o.Username,
+		Password: o.Password,
+	}
+
+	if cc.ClientID == "" {
+		cc.ClientID = clientIDPrefix + c.PassageID
+	}
+
+	cc.Timeout, err = parseDuration("timeout", o.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Settings{
+		PassageID:  c.PassageID,
+		Direction:  c.Direction,
+		Topic:      o.Topic,
+		QoS:        o.QoS,
+		Payload:    o.Payload,
+		AckTopic:   o.AckTopic,
+		AckPayload: o.AckPayload,
+		Timeout:    cc.Timeout,
+	}
+
+	s.AckTimeout, err = parseDuration("ack_timeout", o.AckTimeout, defaultAckTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPassageOpener(cc, s)
+}
+
+// Settings of passage opener. Command is published to topic with QoS, its
+// payload is JSON OpenCommand unless payload is set. If ack topic is set,
+// opening succeeds only when message, with ack payload if it is set, is
+// received on ack topic within ack timeout after command publication.
+type Settings struct {
+	PassageID  string
+	Direction  entity.Direction
+	Topic      string
+	QoS        byte
+	Payload    string
+	AckTopic   string
+	AckPayload string
+	AckTimeout time.Duration
+	Timeout    time.Duration
+}
+
+// OpenCommand is default open command payload. Person is absent if passage
+// is opened without person.
+type OpenCommand struct {
+	PassageID  string           `json:"passage_id"`
+	Direction  entity.Direction `json:"direction"`
+	PersonID   int64            `json:"person_id,omitempty"`
+	PersonName string           `json:"person_name,omitempty"`
+	Time       time.Time        `json:"time"`
+}
+
+// PassageOpener opens passage by publishing command to MQTT broker.
+type PassageOpener struct {
+	client   paho.Client
+	settings Settings
+
+	acks  chan []byte
+	ackMx sync.Mutex
+
+	lastOpen   time.Time
+	lastOpenMx sync.Mutex
+
+	log *logrus.Entry
+}
+
+func NewPassageOpener(cc ClientConfig, s Settings) (*PassageOpener, error) {
+	po := &PassageOpener{
+		settings: s,
+		acks:     make(chan []byte, 1),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": "mqtt_passage_opener",
+			"topic":     s.Topic,
+		}),
+	}
+
+	var onConnect func(paho.Client)
+
+	if s.AckTopic != "" {
+		onConnect = func(c paho.Client) {
+			err := wait(c.Subscribe(s.AckTopic, s.QoS, po.handleAck), s.Timeout)
+			if err != nil {
+				po.log.WithError(err).WithField("ack_topic", s.AckTopic).Error("failed to subscribe")
+			}
+		}
+	}
+
+	var err error
+
+	po.client, err = NewClient(cc, onConnect)
+	if err != nil {
+		return nil, err
+	}
+
+	return po, nil
+}
+
+// Close disconnects from broker.
+func (po *PassageOpener) Close() error {
+	po.client.Disconnect(uint(po.settings.Timeout / time.Millisecond))
+	return nil
+}
+
+// handleAck keeps the latest acknowledgement, older ones are late for
+// current command anyway.
+func (po *PassageOpener) handleAck(_ paho.Client, m paho.Message) {
+	payload := m.Payload()
+
+	if po.settings.AckPayload != "" && string(payload) != po.settings.AckPayload {
+		return
+	}
+
+	select {
+	case <-po.acks:
+	default:
+	}
+
+	po.acks <- payload
+}
+
+func (po *PassageOpener) OpenPassage() error {
+	return po.OpenPassageFor(entity.Person{})
+}
+
+// OpenPassageFor publishes open command with person, commands are
+// serialized to match acknowledgements with them.
+func (po *PassageOpener) OpenPassageFor(p entity.Person) error {
+	if !po.client.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+
+	payload := []byte(po.settings.Payload)
+
+	if len(payload) == 0 {
+		var err error
+
+		payload, err = json.Marshal(OpenCommand{
+			PassageID:  po.settings.PassageID,
+			Direction:  po.settings.Direction,
+			PersonID:   p.ID,
+			PersonName: p.Name,
+			Time:       time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("JSON marshal open command: %w", err)
+		}
+	}
+
+	po.ackMx.Lock()
+	defer po.ackMx.Unlock()
+
+	select {
+	case <-po.acks:
+	default:
+	}
+
+	err := wait(po.client.Publish(po.settings.Topic, po.settings.QoS, false, payload), po.settings.Timeout)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	if po.settings.AckTopic != "" {
+		timer := time.NewTimer(po.settings.AckTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-po.acks:
+		case <-timer.C:
+			return errors.New("no acknowledgement")
+		}
+	}
+
+	po.lastOpenMx.Lock()
+	po.lastOpen = time.Now()
+	po.lastOpenMx.Unlock()
+
+	return nil
+}
+
+func (po *PassageOpener) LastOpenTime() time.Time {
+	po.lastOpenMx.Lock()
+	defer po.lastOpenMx.Unlock()
+	return po.lastOpen
+}
+
+// Ping checks broker connection.
+func (po *PassageOpener) Ping() error {
+	if !po.client.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package mqtt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/mqtt"
+)
+
+const (
+	testTimeout    = time.Second
+	testAckTimeout = 200 * time.Millisecond
+)
+
+func newTestPassageOpener(t *testing.T, b *broker, s mqtt.Settings) *mqtt.PassageOpener {
+	t.Helper()
+
+	s.PassageID = "some_passage"
+	s.Direction = entity.In
+	s.Timeout = testTimeout
+
+	po, err := mqtt.NewPassageOpener(mqtt.ClientConfig{
+		Broker:   b.uri(),
+		ClientID: "soma-" + t.Name(),
+		Timeout:  testTimeout,
+	}, s)
+	if err != nil {
+		t.Fatalf("create passage opener: %v", err)
+	}
+
+	t.Cleanup(func() { po.Close() })
+
+	if s.AckTopic != "" {
+		waitFor(t, "ack topic subscription", func() bool { return b.subscribed(s.AckTopic) })
+	}
+
+	return po
+}
+
+func TestPassageOpenerPublish(t *testing.T) {
+	for _, qos := range []byte{0, 1, 2} {
+		b := newBroker(t, nil)
+		po := newTestPassageOpener(t, b, mqtt.Settings{
+			Topic: "relay/set",
+			QoS:   qos,
+		})
+
+		err := po.OpenPassageFor(entity.Person{ID: 7, Name: "Some Person"})
+		if err != nil {
+			t.Fatalf("qos %d: open passage: %v", qos, err)
+		}
+
+		// QoS 0 publication isn't acknowledged, so it may arrive later.
+		waitFor(t, "publication", func() bool { return len(b.received()) == 1 })
+
+		m := b.received()[0]
+		if m.Topic != "relay/set" || m.QoS != qos {
+			t.Fatalf("qos %d: got publication to %s with qos %d", qos, m.Topic, m.QoS)
+		}
+
+		var c mqtt.OpenCommand
+
+		err = json.Unmarshal([]byte(m.Payload), &c)
+		if err != nil {
+			t.Fatalf("qos %d: JSON unmarshal open command: %v", qos, err)
+		}
+
+		if c.PassageID != "some_passage" || c.Direction != entity.In || c.PersonID != 7 ||
+			c.PersonName != "Some Person" || c.Time.IsZero() {
+			t.Fatalf("qos %d: got open command %+v", qos, c)
+		}
+
+		if po.LastOpenTime().IsZero() {
+			t.Errorf("qos %d: last open time is not set", qos)
+		}
+
+		err = po.Ping()
+		if err != nil {
+			t.Errorf("qos %d: ping: %v", qos, err)
+		}
+	}
+}
+
+func TestPassageOpenerPayload(t *testing.T) {
+	b := newBroker(t, nil)
+	po := newTestPassageOpener(t, b, mqtt.Settings{
+		Topic:   "relay/set",
+		QoS:     1,
+		Payload: "open",
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	ms := b.received()
+	if len(ms) != 1 || ms[0].Payload != "open" {
+		t.Fatalf("got publications %+v, want open payload", ms)
+	}
+}
+
+func TestPassageOpenerAck(t *testing.T) {
+	b := newBroker(t, func(b *broker, m message) {
+		if m.Topic == "relay/set" {
+			b.publish("relay/state", "closed")
+			b.publish("relay/state", "opened")
+		}
+	})
+
+	po := newTestPassageOpener(t, b, mqtt.Settings{
+		Topic:      "relay/set",
+		QoS:        1,
+		AckTopic:   "relay/state",
+		AckPayload: "opened",
+		AckTimeout: testAckTimeout,
+	})
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open acknowledged passage: %v", err)
+	}
+}
+
+func TestPassageOpenerAckTimeout(t *testing.T) {
+	b := newBroker(t, func(b *broker, m message) {
+		if m.Topic == "relay/set" {
+			b.publish("relay/state", "closed")
+		}
+	})
+
+	po := newTestPassageOpener(t, b, mqtt.Settings{
+		Topic:      "relay/set",
+		QoS:        1,
+		AckTopic:   "relay/state",
+		AckPayload: "opened",
+		AckTimeout: testAckTimeout,
+	})
+
+	started := time.Now()
+
+	err := po.OpenPassage()
+	if err == nil {
+		t.Fatal("passage opened with acknowledgement of other payload")
+	}
+
+	if waited := time.Since(started); waited < testAckTimeout {
+		t.Errorf("acknowledgement waited %s, want at least ack timeout", waited)
+	}
+
+	if !po.LastOpenTime().IsZero() {
+		t.Error("last open time is set without acknowledgement")
+	}
+
+	// Late acknowledgement of the previous command doesn't acknowledge the
+	// next one.
+	b.publish("relay/state", "opened")
+	time.Sleep(50 * time.Millisecond)
+
+	err = po.OpenPassage()
+	if err == nil {
+		t.Fatal("passage opened with late acknowledgement")
+	}
+}
+
+func TestPassageOpenerNotConnected(t *testing.T) {
+	b := newBroker(t, nil)
+	po := newTestPassageOpener(t, b, mqtt.Settings{
+		Topic: "relay/set",
+	})
+
+	b.close()
+
+	waitFor(t, "connection loss", func() bool { return errors.Is(po.Ping(), mqtt.ErrNotConnected) })
+
+	err := po.OpenPassage()
+	if !errors.Is(err, mqtt.ErrNotConnected) {
+		t.Fatalf("got error %v, want %v", err, mqtt.ErrNotConnected)
+	}
+}
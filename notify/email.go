@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Email sends alerts as plain text emails through SMTP server.
+type Email struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// NewEmail creates email notifier. Empty username disables authentication.
+func NewEmail(addr, username, password, from string, to []string) *Email {
+	e := &Email{
+		addr: addr,
+		from: from,
+		to:   to,
+	}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		e.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return e
+}
+
+func (e *Email) Notify(a entity.Alert) error {
+	var d entity.WatchlistHitData
+
+	if len(a.Data) > 0 {
+		err := json.Unmarshal(a.Data, &d)
+		if err != nil {
+			return fmt.Errorf("JSON unmarshal alert data: %w", err)
+		}
+	}
+
+	subject := fmt.Sprintf("SOMA alert #%d: %s", a.ID, a.Type)
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "Time: %s\r\n", a.Time.Format(time.RFC3339))
+	fmt.Fprintf(&msg, "Passage: %s\r\n", a.PassageID)
+	if d.PersonID != 0 {
+		fmt.Fprintf(&msg, "Person: %s (#%d), %s, %s\r\n", d.PersonName, d.PersonID,
+			d.PersonPosition, d.PersonUnit)
+		fmt.Fprintf(&msg, "Watchlist: %s\r\n", d.Watchlist)
+		fmt.Fprintf(&msg, "Photo: %s\r\n", d.PhotoID)
+	}
+
+	err := smtp.SendMail(e.addr, e.auth, e.from, e.to, msg.Bytes())
+	if err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Webhook posts alerts as JSON to URL. Face descriptor is stripped from
+// alert data.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *Webhook) Notify(a entity.Alert) error {
+	a.Data = entity.WithoutFaceDescriptor(a.Data)
+
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("JSON marshal alert: %w", err)
+	}
+
+	res, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post alert: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+
+	return nil
+}
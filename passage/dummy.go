@@ -0,0 +1,37 @@
+package passage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func init() {
+	Register(entity.Dummy, func(Config) (Opener, error) {
+		return &dummyOpener{}, nil
+	})
+}
+
+// dummyOpener opens nothing, it is used to try skuder without controllers.
+type dummyOpener struct {
+	lastOpenTime time.Time
+	mx           sync.Mutex
+}
+
+func (o *dummyOpener) OpenPassage() error {
+	o.mx.Lock()
+	o.lastOpenTime = time.Now()
+	o.mx.Unlock()
+	return nil
+}
+
+func (o *dummyOpener) LastOpenTime() time.Time {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	return o.lastOpenTime
+}
+
+func (o *dummyOpener) Ping() error {
+	return nil
+}
@@ -0,0 +1,183 @@
+package passage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Passage is passage opener which tracks controller health. Controller is
+// pinged every pingPeriod, passage openings update health as well.
+type Passage struct {
+	id        string
+	pType     entity.PassageType
+	direction entity.Direction
+	opener    Opener
+
+	status   entity.PassageStatus
+	statusMx sync.Mutex
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates passage opener of registered type and starts probing it.
+func New(passageID string, pt entity.PassageType, c Config, pingPeriod time.Duration) (*Passage, error) {
+	c.PassageID = passageID
+
+	o, err := NewOpener(pt, c)
+	if err != nil {
+		return nil, fmt.Errorf("new %s opener: %w", pt, err)
+	}
+
+	p := &Passage{
+		id:        passageID,
+		pType:     pt,
+		direction: c.Direction,
+		opener:    o,
+		status: entity.PassageStatus{
+			PassageID: passageID,
+			Type:      pt,
+			Direction: c.Direction,
+			Health:    entity.PassageHealthUnknown,
+		},
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":  "passage",
+			"passage_id": passageID,
+		}),
+		stop: make(chan struct{}),
+	}
+
+	if pingPeriod > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+
+			ticker := time.NewTicker(pingPeriod)
+			defer ticker.Stop()
+
+			for {
+				p.Ping()
+
+				select {
+				case <-p.stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	return p, nil
+}
+
+// Stop stops probing and closes opener if it is closable.
+func (p *Passage) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+
+	if c, ok := p.opener.(io.Closer); ok {
+		err := c.Close()
+		if err != nil {
+			p.log.WithError(err).Error("failed to close passage opener")
+		}
+	}
+}
+
+func (p *Passage) ID() string {
+	return p.id
+}
+
+func (p *Passage) Type() entity.PassageType {
+	return p.pType
+}
+
+func (p *Passage) Direction() entity.Direction {
+	return p.direction
+}
+
+func (p *Passage) OpenPassage() error {
+	err := p.opener.OpenPassage()
+	p.setHealth(err)
+	return err
+}
+
+// OpenPassageFor opens passage for person if opener depends on person,
+// otherwise it is OpenPassage.
+func (p *Passage) OpenPassageFor(person entity.Person) error {
+	po, ok := p.opener.(PersonOpener)
+	if !ok {
+		return p.OpenPassage()
+	}
+
+	err := po.OpenPassageFor(person)
+	p.setHealth(err)
+	return err
+}
+
+func (p *Passage) LastOpenTime() time.Time {
+	return p.opener.LastOpenTime()
+}
+
+// Ping pings controller and updates passage health.
+func (p *Passage) Ping() error {
+	err := p.opener.Ping()
+	if err != nil {
+		p.log.WithError(err).Warn("passage ping failed")
+	}
+	p.setHealth(err)
+	return err
+}
+
+func (p *Passage) setHealth(err error) {
+	p.statusMx.Lock()
+	defer p.statusMx.Unlock()
+
+	if err != nil {
+		p.status.Health = entity.PassageHealthFailed
+		p.status.Error = err.Error()
+	} else {
+		p.status.Health = entity.PassageHealthOK
+		p.status.Error = ""
+	}
+
+	p.status.CheckTime = time.Now()
+}
+
+func (p *Passage) Status() entity.PassageStatus {
+	p.statusMx.Lock()
+	s := p.status
+	p.statusMx.Unlock()
+
+	s.LastOpenTime = p.opener.LastOpenTime()
+
+	return s
+}
+
+// CredentialReads returns card reads and PIN entries reported by controller
+// or nil if opener doesn't report them.
+func (p *Passage) CredentialReads() <-chan entity.CredentialRead {
+	if cr, ok := p.opener.(interface {
+		CredentialReads() <-chan entity.CredentialRead
+	}); ok {
+		return cr.CredentialReads()
+	}
+	return nil
+}
+
+// ControllerEvents returns events reported by controller itself or nil if
+// opener doesn't report them.
+func (p *Passage) ControllerEvents() <-chan entity.ControllerEvent {
+	if cer, ok := p.opener.(interface {
+		ControllerEvents() <-chan entity.ControllerEvent
+	}); ok {
+		return cer.ControllerEvents()
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package passage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Opener opens passage of a controller. Ping checks that controller is
+// reachable without opening anything. Openers which hold connections also
+// implement io.Closer.
+type Opener interface {
+	OpenPassage() error
+	LastOpenTime() time.Time
+	Ping() error
+}
+
+// PersonOpener is implemented by openers which open passage depending on
+// person let in, like sending person details to controller.
+type PersonOpener interface {
+	OpenPassageFor(p entity.Person) error
+}
+
+// Config is passage opener config. Options are type specific settings, which
+// opener decodes with DecodeOptions. PassageID is set by New.
+type Config struct {
+	PassageID string
+	Address   string
+	Direction entity.Direction
+	Options   map[string]interface{}
+}
+
+// DecodeOptions decodes options to v as YAML.
+func (c Config) DecodeOptions(v interface{}) error {
+	optionsYAML, err := yaml.Marshal(c.Options)
+	if err != nil {
+		return fmt.Errorf("YAML marshal options: %w", err)
+	}
+
+	err = yaml.Unmarshal(optionsYAML, v)
+	if err != nil {
+		return fmt.Errorf("YAML unmarshal options: %w", err)
+	}
+
+	return nil
+}
+
+// Factory creates passage opener from config.
+type Factory func(Config) (Opener, error)
+
+// CredentialsReporter tells whether opener created from config reports card
+// reads and PIN entries of its controller, e.g. when they are enabled by
+// options.
+type CredentialsReporter func(Config) bool
+
+var (
+	factories            = map[entity.PassageType]Factory{}
+	credentialsReporters = map[entity.PassageType]CredentialsReporter{}
+	factoriesMx          sync.RWMutex
+)
+
+// Register makes passage opener factory available by passage type. It is
+// called from init functions of opener packages, so they are enabled by
+// importing them. Register panics if type is registered twice.
+func Register(pt entity.PassageType, f Factory) {
+	factoriesMx.Lock()
+	defer factoriesMx.Unlock()
+
+	if _, exists := factories[pt]; exists {
+		panic("passage: factory of type " + string(pt) + " is already registered")
+	}
+
+	factories[pt] = f
+}
+
+// RegisterCredentialsReporter makes passage type able to report credentials
+// depending on config. It panics if type is registered twice.
+func RegisterCredentialsReporter(pt entity.PassageType, r CredentialsReporter) {
+	factoriesMx.Lock()
+	defer factoriesMx.Unlock()
+
+	if _, exists := credentialsReporters[pt]; exists {
+		panic("passage: credentials reporter of type " + string(pt) + " is already registered")
+	}
+
+	credentialsReporters[pt] = r
+}
+
+// ReportsCredentials returns whether opener of passage type created from
+// config reports credentials.
+func ReportsCredentials(pt entity.PassageType, c Config) bool {
+	factoriesMx.RLock()
+	r, exists := credentialsReporters[pt]
+	factoriesMx.RUnlock()
+
+	return exists && r(c)
+}
+
+// Registered returns whether passage type is registered.
+func Registered(pt entity.PassageType) bool {
+	factoriesMx.RLock()
+	defer factoriesMx.RUnlock()
+
+	_, exists := factories[pt]
+
+	return exists
+}
+
+// Types returns sorted registered passage types.
+func Types() []entity.PassageType {
+	factoriesMx.RLock()
+	defer factoriesMx.RUnlock()
+
+	var pts []entity.PassageType
+
+	for pt := range factories {
+		pts = append(pts, pt)
+	}
+
+	sort.Slice(pts, func(i, j int) bool {
+		return pts[i] < pts[j]
+	})
+
+	return pts
+}
+
+// NewOpener creates passage opener of registered type.
+func NewOpener(pt entity.PassageType, c Config) (Opener, error) {
+	factoriesMx.RLock()
+	f, exists := factories[pt]
+	factoriesMx.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown passage type %s", pt)
+	}
+
+	return f(c)
+}
@@ -0,0 +1,162 @@
+package pg
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const selectAccessGroups = `
+	SELECT ag.*, COALESCE(array_agg(agp.person_id) FILTER (WHERE agp.person_id IS NOT NULL), '{}') AS person_ids
+	FROM access_group ag
+	LEFT JOIN access_group_person agp ON agp.access_group_id = ag.id
+`
+
+func (s *Storage) AccessGroup(accessGroupID int64) (ag entity.AccessGroup, err error) {
+	err = s.db.QueryRowx(selectAccessGroups+`
+		WHERE ag.id = $1
+		GROUP BY ag.id
+	`, accessGroupID).StructScan(&ag)
+	if err == sql.ErrNoRows {
+		err = entity.ErrAccessGroupNotFound
+	}
+	return
+}
+
+func (s *Storage) AccessGroups() (ags []entity.AccessGroup, err error) {
+	err = s.db.Select(&ags, selectAccessGroups+`
+		GROUP BY ag.id
+		ORDER BY ag.name
+	`)
+	return
+}
+
+// PersonAccessGroups returns access groups which person is member of.
+func (s *Storage) PersonAccessGroups(personID int64) (ags []entity.AccessGroup, err error) {
+	err = s.db.Select(&ags, selectAccessGroups+`
+		WHERE ag.id IN (SELECT access_group_id FROM access_group_person WHERE person_id = $1)
+		GROUP BY ag.id
+	`, personID)
+	return
+}
+
+func (s *Storage) AddAccessGroup(ag entity.AccessGroup) (entity.AccessGroup, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return ag, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO access_group (name, passage_ids, windows, holiday_windows)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, ag.Name, ag.PassageIDs, ag.Windows, ag.HolidayWindows).Scan(&ag.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return ag, fmt.Errorf("insert access group: %w", err)
+	}
+
+	err = setAccessGroupPersons(tx, ag)
+	if err != nil {
+		_ = tx.Rollback()
+		return ag, err
+	}
+
+	return ag, tx.Commit()
+}
+
+func (s *Storage) SetAccessGroup(ag entity.AccessGroup) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		UPDATE access_group SET name = $1, passage_ids = $2, windows = $3, holiday_windows = $4
+		WHERE id = $5
+	`, ag.Name, ag.PassageIDs, ag.Windows, ag.HolidayWindows, ag.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("update access group: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		_ = tx.Rollback()
+		return entity.ErrAccessGroupNotFound
+	}
+
+	_, err = tx.Exec(`DELETE FROM access_group_person WHERE access_group_id = $1`, ag.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("delete access group persons: %w", err)
+	}
+
+	err = setAccessGroupPersons(tx, ag)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setAccessGroupPersons(tx *sqlx.Tx, ag entity.AccessGroup) error {
+	if len(ag.PersonIDs) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO access_group_person (access_group_id, person_id)
+		SELECT $1, unnest($2::BIGINT[])
+		ON CONFLICT DO NOTHING
+	`, ag.ID, ag.PersonIDs)
+	if err != nil {
+		return fmt.Errorf("insert access group persons: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveAccessGroup(accessGroupID int64) (err error) {
+	_, err = s.db.Exec(`DELETE FROM access_group WHERE id = $1`, accessGroupID)
+	return
+}
+
+// Holiday returns holiday at date in YYYY-MM-DD format.
+func (s *Storage) Holiday(date string) (h entity.Holiday, err error) {
+	err = s.db.QueryRowx(`
+		SELECT to_char(date, 'YYYY-MM-DD') AS date, name FROM holiday WHERE date = $1
+	`, date).StructScan(&h)
+	if err == sql.ErrNoRows {
+		err = entity.ErrHolidayNotFound
+	}
+	return
+}
+
+func (s *Storage) Holidays() (hs []entity.Holiday, err error) {
+	err = s.db.Select(&hs, `
+		SELECT to_char(date, 'YYYY-MM-DD') AS date, name FROM holiday ORDER BY date
+	`)
+	return
+}
+
+func (s *Storage) SetHoliday(h entity.Holiday) (err error) {
+	_, err = s.db.Exec(`
+		INSERT INTO holiday (date, name) VALUES ($1, $2)
+		ON CONFLICT (date) DO UPDATE SET name = EXCLUDED.name
+	`, h.Date, h.Name)
+	return
+}
+
+func (s *Storage) RemoveHoliday(date string) (err error) {
+	_, err = s.db.Exec(`DELETE FROM holiday WHERE date = $1`, date)
+	return
+}
@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func (s *Storage) AddAlert(a entity.Alert) (entity.Alert, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO alert (time, type, status, passage_id, person_id, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, a.Time, a.Type, a.Status, a.PassageID, a.PersonID, a.Data).Scan(&a.ID)
+	return a, err
+}
+
+func (s *Storage) Alert(alertID int64) (a entity.Alert, err error) {
+	err = s.db.QueryRowx(`SELECT * FROM alert WHERE id = $1`, alertID).StructScan(&a)
+	if err == sql.ErrNoRows {
+		err = entity.ErrAlertNotFound
+	}
+	return
+}
+
+// Alerts returns alerts with given statuses or all alerts if no statuses
+// given, the latest first.
+func (s *Storage) Alerts(limit int, statuses ...entity.AlertStatus) (as []entity.Alert, err error) {
+	ss := make([]string, len(statuses))
+	for i, st := range statuses {
+		ss[i] = string(st)
+	}
+	err = s.db.Select(&as, `
+		SELECT * FROM alert
+		WHERE cardinality($1::TEXT[]) = 0 OR status = ANY($1)
+		ORDER BY time DESC, id DESC
+		LIMIT $2
+	`, pq.Array(ss), limit)
+	return
+}
+
+// AcknowledgeAlert marks active alert as being handled by user with login.
+func (s *Storage) AcknowledgeAlert(alertID int64, login string) (entity.Alert, error) {
+	return s.updateAlert(alertID, `
+		UPDATE alert SET status = $2, acknowledged_by = $3, acknowledged_at = now()
+		WHERE id = $1 AND status = $4
+		RETURNING *
+	`, entity.AlertAcknowledged, login, entity.AlertActive)
+}
+
+// ResolveAlert marks active or acknowledged alert as resolved by user with
+// login. Active alert is acknowledged by the same user at the same time.
+func (s *Storage) ResolveAlert(alertID int64, login string, comment string) (entity.Alert, error) {
+	return s.updateAlert(alertID, `
+		UPDATE alert SET status = $2, resolved_by = $3, resolved_at = now(), comment = $4,
+			acknowledged_by = COALESCE(acknowledged_by, $3),
+			acknowledged_at = COALESCE(acknowledged_at, now())
+		WHERE id = $1 AND status <> $2
+		RETURNING *
+	`, entity.AlertResolved, login, comment)
+}
+
+func (s *Storage) updateAlert(alertID int64, query string, args ...interface{}) (a entity.Alert, err error) {
+	err = s.db.QueryRowx(query, append([]interface{}{alertID}, args...)...).StructScan(&a)
+	if err == sql.ErrNoRows {
+		_, err = s.Alert(alertID)
+		if err == nil {
+			err = entity.ErrAlertStatusConflict
+		}
+	}
+	return
+}
@@ -0,0 +1,38 @@
+package pg
+
+import (
+	"database/sql"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func (s *Storage) Credential(ct entity.CredentialType, value string) (c entity.Credential, err error) {
+	err = s.db.QueryRowx(`
+		SELECT * FROM credential WHERE type = $1 AND value = $2
+	`, ct, value).StructScan(&c)
+	if err == sql.ErrNoRows {
+		err = entity.ErrCredentialNotFound
+	}
+	return
+}
+
+func (s *Storage) PersonCredentials(personID int64) (cs []entity.Credential, err error) {
+	err = s.db.Select(&cs, `
+		SELECT * FROM credential WHERE person_id = $1 ORDER BY type, value
+	`, personID)
+	return
+}
+
+func (s *Storage) AddCredential(c entity.Credential) (entity.Credential, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO credential (person_id, type, value)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, c.PersonID, c.Type, c.Value).Scan(&c.ID)
+	return c, err
+}
+
+func (s *Storage) RemoveCredential(credentialID int64) (err error) {
+	_, err = s.db.Exec(`DELETE FROM credential WHERE id = $1`, credentialID)
+	return
+}
@@ -0,0 +1,143 @@
+package pg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const deleteEventsBatchSize = 10000
+
+// EnsureEventPartitions creates monthly event partitions from the current
+// month up to ahead months forward.
+func (s *Storage) EnsureEventPartitions(ahead int) error {
+	// Months are added to the first day, so that they don't overflow.
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= ahead; i++ {
+		_, err := s.db.Exec(`SELECT create_event_partition($1)`, month.AddDate(0, i, 0))
+		if err != nil {
+			return fmt.Errorf("create event partition: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// EventPartitions returns monthly event partitions sorted by time. Default
+// partition is not included.
+func (s *Storage) EventPartitions() ([]entity.EventPartition, error) {
+	var names []string
+
+	err := s.db.Select(&names, `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'event'
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("select event partitions: %w", err)
+	}
+
+	var eps []entity.EventPartition
+
+	for _, name := range names {
+		var year, month int
+
+		_, err := fmt.Sscanf(name, "event_y%4dm%2d", &year, &month)
+		if err != nil {
+			continue
+		}
+
+		from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+		eps = append(eps, entity.EventPartition{
+			Name: name,
+			From: from,
+			To:   from.AddDate(0, 1, 0),
+		})
+	}
+
+	return eps, nil
+}
+
+func (s *Storage) DropEventPartition(name string) error {
+	_, err := s.db.Exec(`DROP TABLE ` + pq.QuoteIdentifier(name))
+	return err
+}
+
+// ArchiveEventPartition detaches event partition and renames it to
+// event_archive_*, so its events leave event table but are kept in database
+// to be dumped or moved elsewhere.
+func (s *Storage) ArchiveEventPartition(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	_, err = tx.Exec(`ALTER TABLE event DETACH PARTITION ` + pq.QuoteIdentifier(name))
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("detach partition: %w", err)
+	}
+
+	_, err = tx.Exec(`ALTER TABLE ` + pq.QuoteIdentifier(name) + ` RENAME TO ` +
+		pq.QuoteIdentifier("event_archive_"+name[len("event_"):]))
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("rename partition: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteEvents deletes events of the type older than before and returns
+// number of deleted events.
+func (s *Storage) DeleteEvents(et entity.EventType, before time.Time) (int64, error) {
+	return s.deleteEvents(`type = $1 AND time < $2`, et, before)
+}
+
+// DeleteOtherEvents deletes events of any type but given ones older than
+// before and returns number of deleted events.
+func (s *Storage) DeleteOtherEvents(ets []entity.EventType, before time.Time) (int64, error) {
+	types := make([]string, len(ets))
+	for i, et := range ets {
+		types[i] = string(et)
+	}
+	return s.deleteEvents(`type <> ALL($1) AND time < $2`, pq.Array(types), before)
+}
+
+// deleteEvents deletes events matching where condition in batches, so that
+// retention doesn't hold long locks on event table.
+func (s *Storage) deleteEvents(where string, args ...interface{}) (int64, error) {
+	var total int64
+
+	args = append(args, deleteEventsBatchSize)
+
+	for {
+		res, err := s.db.Exec(fmt.Sprintf(`
+			DELETE FROM event WHERE (id, time) IN (
+				SELECT id, time FROM event WHERE %s LIMIT $%d
+			)
+		`, where, len(args)), args...)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < deleteEventsBatchSize {
+			return total, nil
+		}
+	}
+}
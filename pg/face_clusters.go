@@ -0,0 +1,198 @@
+package pg
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// faceClusterPhotos is count of representative photos of face cluster.
+const faceClusterPhotos = 5
+
+var selectFaceClusters = fmt.Sprintf(`
+	SELECT fc.*, ARRAY(
+		SELECT photo_id FROM face_cluster_face
+		WHERE cluster_id = fc.id
+		ORDER BY distance
+		LIMIT %d
+	) AS photo_ids
+	FROM face_cluster fc
+`, faceClusterPhotos)
+
+func (s *Storage) FaceCluster(faceClusterID int64) (fc entity.FaceCluster, err error) {
+	err = s.db.QueryRowx(selectFaceClusters+`
+		WHERE fc.id = $1
+	`, faceClusterID).StructScan(&fc)
+	if err == sql.ErrNoRows {
+		err = entity.ErrFaceClusterNotFound
+	}
+	return
+}
+
+// FaceClusters returns face clusters of at least minCount faces, the largest
+// ones first.
+func (s *Storage) FaceClusters(minCount int) (fcs []entity.FaceCluster, err error) {
+	err = s.db.Select(&fcs, selectFaceClusters+`
+		WHERE fc.count >= $1
+		ORDER BY fc.count DESC, fc.last_seen DESC
+	`, minCount)
+	return
+}
+
+func (s *Storage) FaceClusterFaces(faceClusterID int64) (fcfs []entity.FaceClusterFace, err error) {
+	err = s.db.Select(&fcfs, `
+		SELECT * FROM face_cluster_face WHERE cluster_id = $1 ORDER BY distance
+	`, faceClusterID)
+	return
+}
+
+// FaceClusteringCursor returns the last clustered event cursor, which is zero
+// if nothing is clustered yet.
+func (s *Storage) FaceClusteringCursor() (c entity.EventsCursor, err error) {
+	err = s.db.QueryRow(`
+		SELECT event_time, event_id FROM face_clustering_cursor
+	`).Scan(&c.Time, &c.ID)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+// AddFaceClusterFace saves cluster with face added to it and moves
+// clustering cursor to the face event. Cluster is created if it is new, if it
+// is enrolled or removed meanwhile entity.ErrFaceClusterNotFound is returned.
+func (s *Storage) AddFaceClusterFace(fc entity.FaceCluster, fcf entity.FaceClusterFace) (entity.FaceCluster, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fc, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if fc.ID != 0 {
+		res, err := tx.Exec(`
+			UPDATE face_cluster SET descriptor = $1, count = $2, first_seen = $3, last_seen = $4
+			WHERE id = $5
+		`, fc.Descriptor, fc.Count, fc.FirstSeen, fc.LastSeen, fc.ID)
+		if err != nil {
+			_ = tx.Rollback()
+			return fc, fmt.Errorf("update face cluster: %w", err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return fc, fmt.Errorf("get rows affected: %w", err)
+		}
+
+		if n == 0 {
+			_ = tx.Rollback()
+			return fc, entity.ErrFaceClusterNotFound
+		}
+	} else {
+		err = tx.QueryRow(`
+			INSERT INTO face_cluster (descriptor, count, first_seen, last_seen)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, fc.Descriptor, fc.Count, fc.FirstSeen, fc.LastSeen).Scan(&fc.ID)
+		if err != nil {
+			_ = tx.Rollback()
+			return fc, fmt.Errorf("insert face cluster: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO face_cluster_face (cluster_id, event_id, time, descriptor, photo_id, distance)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, fc.ID, fcf.EventID, fcf.Time, fcf.Descriptor, fcf.PhotoID, fcf.Distance)
+	if err != nil {
+		_ = tx.Rollback()
+		return fc, fmt.Errorf("insert face cluster face: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO face_clustering_cursor (event_time, event_id) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET event_time = EXCLUDED.event_time, event_id = EXCLUDED.event_id
+	`, fcf.Time, fcf.EventID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fc, fmt.Errorf("upsert face clustering cursor: %w", err)
+	}
+
+	return fc, tx.Commit()
+}
+
+// SetFaceClusteringCursor moves clustering cursor past events which are not
+// clustered.
+func (s *Storage) SetFaceClusteringCursor(c entity.EventsCursor) (err error) {
+	_, err = s.db.Exec(`
+		INSERT INTO face_clustering_cursor (event_time, event_id) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET event_time = EXCLUDED.event_time, event_id = EXCLUDED.event_id
+	`, c.Time, c.ID)
+	return
+}
+
+func (s *Storage) RemoveFaceCluster(faceClusterID int64) (err error) {
+	_, err = s.db.Exec(`DELETE FROM face_cluster WHERE id = $1`, faceClusterID)
+	return
+}
+
+// AddFaceClusterPerson adds person with all faces of the cluster enrolled
+// and removes the cluster.
+func (s *Storage) AddFaceClusterPerson(faceClusterID int64, p entity.Person) (entity.Person, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return p, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	var id int64
+
+	err = tx.QueryRow(`
+		SELECT id FROM face_cluster WHERE id = $1 FOR UPDATE
+	`, faceClusterID).Scan(&id)
+	if err != nil {
+		_ = tx.Rollback()
+		if err == sql.ErrNoRows {
+			return p, entity.ErrFaceClusterNotFound
+		}
+		return p, fmt.Errorf("select face cluster: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO person (name, position, unit, watchlist)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, p.Name, p.Position, p.Unit, p.Watchlist).Scan(&p.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return p, fmt.Errorf("insert person: %w", err)
+	}
+
+	var pfs []entity.PersonFace
+
+	err = tx.Select(&pfs, `
+		INSERT INTO person_face (person_id, descriptor, photo_id)
+		SELECT $1, descriptor, photo_id FROM face_cluster_face WHERE cluster_id = $2
+		RETURNING *
+	`, p.ID, faceClusterID)
+	if err != nil {
+		_ = tx.Rollback()
+		return p, fmt.Errorf("insert person faces: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM face_cluster WHERE id = $1`, faceClusterID)
+	if err != nil {
+		_ = tx.Rollback()
+		return p, fmt.Errorf("delete face cluster: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return p, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	for _, pf := range pfs {
+		s.addGalleryPersonFace(pf)
+	}
+
+	return p, nil
+}
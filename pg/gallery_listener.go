@@ -0,0 +1,135 @@
+package pg
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const (
+	galleryChangeChannel = "gallery_change"
+
+	galleryListenerPingPeriod = 90 * time.Second
+)
+
+type galleryChange struct {
+	Table string `json:"table"`
+	Op    string `json:"op"`
+	ID    int64  `json:"id"`
+}
+
+// ListenGalleryChanges subscribes to person and person face changes made by
+// any process sharing the database, loads person faces and keeps applying the
+// changes to in-memory gallery. Notifications sent while listener is
+// disconnected are lost, so gallery is fully reloaded on every reconnect.
+func (s *Storage) ListenGalleryChanges() error {
+	l := pq.NewListener(s.uri, time.Second, time.Minute, func(et pq.ListenerEventType, err error) {
+		switch et {
+		case pq.ListenerEventDisconnected:
+			s.log.WithError(err).Warn("gallery listener disconnected")
+		case pq.ListenerEventReconnected:
+			s.log.Info("gallery listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			s.log.WithError(err).Error("gallery listener failed to connect")
+		}
+	})
+
+	err := l.Listen(galleryChangeChannel)
+	if err != nil {
+		_ = l.Close()
+		return fmt.Errorf("listen %s: %w", galleryChangeChannel, err)
+	}
+
+	// Changes made during the load are queued by listener and applied after.
+	err = s.LoadPersonFaces()
+	if err != nil {
+		_ = l.Close()
+		return fmt.Errorf("load person faces: %w", err)
+	}
+
+	s.galleryListener = l
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(galleryListenerPingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+
+			case n := <-l.Notify:
+				if n == nil {
+					err := s.LoadPersonFaces()
+					if err != nil {
+						s.log.WithError(err).Error("failed to reload person faces")
+					} else {
+						s.log.Info("person faces reloaded")
+					}
+					continue
+				}
+
+				err := s.applyGalleryChange(n.Extra)
+				if err != nil {
+					s.log.WithError(err).WithField("payload", n.Extra).
+						Error("failed to apply gallery change")
+				}
+
+			case <-ticker.C:
+				go func() {
+					err := l.Ping()
+					if err != nil {
+						s.log.WithError(err).Warn("failed to ping gallery listener")
+					}
+				}()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *Storage) applyGalleryChange(payload string) error {
+	var gc galleryChange
+
+	err := json.Unmarshal([]byte(payload), &gc)
+	if err != nil {
+		return fmt.Errorf("JSON unmarshal: %w", err)
+	}
+
+	switch gc.Table {
+	case "person":
+		if gc.Op == "DELETE" {
+			s.removePersonFaces(gc.ID)
+		}
+
+	case "person_face":
+		if gc.Op == "DELETE" {
+			s.removeGalleryPersonFace(gc.ID)
+			return nil
+		}
+
+		var pf entity.PersonFace
+
+		err = s.db.QueryRowx(`SELECT * FROM person_face WHERE id = $1`, gc.ID).StructScan(&pf)
+		if err == sql.ErrNoRows {
+			s.removeGalleryPersonFace(gc.ID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("select person face: %w", err)
+		}
+
+		s.addGalleryPersonFace(pf)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+CREATE TABLE visitor_pass (
+    person_id BIGINT PRIMARY KEY REFERENCES person (id) ON DELETE CASCADE,
+    host_person_id BIGINT REFERENCES person (id) ON DELETE SET NULL,
+    purpose TEXT NOT NULL,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE NOT NULL,
+    passage_ids TEXT[] NOT NULL,
+    max_entries INTEGER NOT NULL,
+    entries INTEGER NOT NULL DEFAULT 0,
+    faces_removed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX visitor_pass_valid_to_idx ON visitor_pass (valid_to) WHERE NOT faces_removed;
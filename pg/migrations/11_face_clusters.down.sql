@@ -0,0 +1,5 @@
+DROP TABLE face_clustering_cursor;
+
+DROP TABLE face_cluster_face;
+
+DROP TABLE face_cluster;
@@ -0,0 +1,25 @@
+CREATE TABLE face_cluster (
+    id BIGSERIAL PRIMARY KEY,
+    descriptor REAL[128] NOT NULL,
+    count INTEGER NOT NULL,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE face_cluster_face (
+    id BIGSERIAL PRIMARY KEY,
+    cluster_id BIGINT NOT NULL REFERENCES face_cluster (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    descriptor REAL[128] NOT NULL,
+    photo_id TEXT NOT NULL,
+    distance DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX face_cluster_face_cluster_id_idx ON face_cluster_face (cluster_id, distance);
+
+CREATE TABLE face_clustering_cursor (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    event_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    event_id BIGINT NOT NULL
+);
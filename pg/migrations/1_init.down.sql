@@ -1,4 +1,4 @@
 DROP TABLE event;
 DROP TABLE person_face;
 DROP TABLE person;
-DROP TABLE skuder_user;
\ No newline at end of file
+DROP TABLE skuder_user;
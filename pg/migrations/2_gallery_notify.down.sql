@@ -0,0 +1,3 @@
+DROP TRIGGER person_gallery_change ON person;
+DROP TRIGGER person_face_gallery_change ON person_face;
+DROP FUNCTION notify_gallery_change();
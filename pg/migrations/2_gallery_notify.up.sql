@@ -0,0 +1,27 @@
+CREATE FUNCTION notify_gallery_change() RETURNS TRIGGER AS $$
+DECLARE
+    row RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row := OLD;
+    ELSE
+        row := NEW;
+    END IF;
+
+    PERFORM pg_notify('gallery_change', json_build_object(
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'id', row.id
+    )::TEXT);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER person_face_gallery_change
+    AFTER INSERT OR UPDATE OR DELETE ON person_face
+    FOR EACH ROW EXECUTE PROCEDURE notify_gallery_change();
+
+CREATE TRIGGER person_gallery_change
+    AFTER DELETE ON person
+    FOR EACH ROW EXECUTE PROCEDURE notify_gallery_change();
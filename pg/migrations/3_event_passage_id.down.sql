@@ -0,0 +1,3 @@
+DROP INDEX event_passage_id_time_idx;
+
+ALTER TABLE event DROP COLUMN passage_id;
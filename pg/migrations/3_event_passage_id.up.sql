@@ -0,0 +1,10 @@
+ALTER TABLE event ADD COLUMN IF NOT EXISTS passage_id TEXT;
+
+UPDATE event SET passage_id = COALESCE(data->>'passage_id', '')
+WHERE passage_id IS NULL OR passage_id = '';
+
+ALTER TABLE event
+    ALTER COLUMN passage_id SET DEFAULT '',
+    ALTER COLUMN passage_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS event_passage_id_time_idx ON event (passage_id, time);
@@ -0,0 +1,3 @@
+DROP INDEX event_person_id_time_idx;
+DROP INDEX event_type_time_id_idx;
+DROP INDEX event_time_id_idx;
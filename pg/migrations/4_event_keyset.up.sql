@@ -0,0 +1,5 @@
+CREATE INDEX event_time_id_idx ON event (time, id);
+
+CREATE INDEX event_type_time_id_idx ON event (type, time, id);
+
+CREATE INDEX event_person_id_time_idx ON event (((data->>'person_id')::BIGINT), time);
@@ -0,0 +1,28 @@
+ALTER TABLE event RENAME TO event_partitioned;
+
+DROP INDEX event_passage_id_time_idx;
+DROP INDEX event_time_id_idx;
+DROP INDEX event_type_time_id_idx;
+DROP INDEX event_person_id_time_idx;
+
+CREATE TABLE event (
+    id BIGINT PRIMARY KEY DEFAULT nextval('event_id_seq'),
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    type TEXT NOT NULL,
+    passage_id TEXT NOT NULL DEFAULT '',
+    data JSONB
+);
+
+INSERT INTO event (id, time, type, passage_id, data)
+SELECT id, time, type, passage_id, data FROM event_partitioned;
+
+ALTER SEQUENCE event_id_seq OWNED BY event.id;
+
+DROP TABLE event_partitioned;
+
+DROP FUNCTION create_event_partition(TIMESTAMP WITH TIME ZONE);
+
+CREATE INDEX event_passage_id_time_idx ON event (passage_id, time);
+CREATE INDEX event_time_id_idx ON event (time, id);
+CREATE INDEX event_type_time_id_idx ON event (type, time, id);
+CREATE INDEX event_person_id_time_idx ON event (((data->>'person_id')::BIGINT), time);
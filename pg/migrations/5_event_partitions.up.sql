@@ -0,0 +1,51 @@
+ALTER TABLE event RENAME TO event_unpartitioned;
+ALTER TABLE event_unpartitioned RENAME CONSTRAINT event_pkey TO event_unpartitioned_pkey;
+
+DROP INDEX event_passage_id_time_idx;
+DROP INDEX event_time_id_idx;
+DROP INDEX event_type_time_id_idx;
+DROP INDEX event_person_id_time_idx;
+
+CREATE TABLE event (
+    id BIGINT NOT NULL DEFAULT nextval('event_id_seq'),
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    type TEXT NOT NULL,
+    passage_id TEXT NOT NULL DEFAULT '',
+    data JSONB,
+    PRIMARY KEY (id, time)
+) PARTITION BY RANGE (time);
+
+-- Catches events out of created partitions range, normally stays empty.
+CREATE TABLE event_default PARTITION OF event DEFAULT;
+
+-- Creates monthly (in UTC) event partition containing given time if it does
+-- not exist and returns its name.
+CREATE FUNCTION create_event_partition(at_time TIMESTAMP WITH TIME ZONE) RETURNS TEXT AS $$
+DECLARE
+    from_time TIMESTAMP WITH TIME ZONE := date_trunc('month', at_time AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    to_time TIMESTAMP WITH TIME ZONE := (date_trunc('month', at_time AT TIME ZONE 'UTC') + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    name TEXT := 'event_' || to_char(from_time AT TIME ZONE 'UTC', '"y"YYYY"m"MM');
+BEGIN
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF event FOR VALUES FROM (%L) TO (%L)',
+        name, from_time, to_time);
+    RETURN name;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_event_partition(month AT TIME ZONE 'UTC') FROM generate_series(
+    date_trunc('month', COALESCE((SELECT min(time) FROM event_unpartitioned), now()) AT TIME ZONE 'UTC'),
+    now() AT TIME ZONE 'UTC' + INTERVAL '2 months',
+    INTERVAL '1 month'
+) AS month;
+
+INSERT INTO event (id, time, type, passage_id, data)
+SELECT id, time, type, passage_id, data FROM event_unpartitioned;
+
+ALTER SEQUENCE event_id_seq OWNED BY event.id;
+
+DROP TABLE event_unpartitioned;
+
+CREATE INDEX event_passage_id_time_idx ON event (passage_id, time);
+CREATE INDEX event_time_id_idx ON event (time, id);
+CREATE INDEX event_type_time_id_idx ON event (type, time, id);
+CREATE INDEX event_person_id_time_idx ON event (((data->>'person_id')::BIGINT), time);
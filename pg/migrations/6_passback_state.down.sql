@@ -0,0 +1 @@
+DROP TABLE passback_state;
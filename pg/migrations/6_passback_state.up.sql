@@ -0,0 +1,8 @@
+CREATE TABLE passback_state (
+    person_id BIGINT NOT NULL REFERENCES person (id) ON DELETE CASCADE,
+    zone TEXT NOT NULL,
+    direction TEXT NOT NULL,
+    passage_id TEXT NOT NULL,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (person_id, zone)
+);
@@ -0,0 +1,3 @@
+DROP TABLE holiday;
+DROP TABLE access_group_person;
+DROP TABLE access_group;
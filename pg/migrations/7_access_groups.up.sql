@@ -0,0 +1,20 @@
+CREATE TABLE access_group (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    passage_ids TEXT[] NOT NULL DEFAULT '{}',
+    windows JSONB NOT NULL DEFAULT '[]',
+    holiday_windows JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE TABLE access_group_person (
+    access_group_id BIGINT NOT NULL REFERENCES access_group (id) ON DELETE CASCADE,
+    person_id BIGINT NOT NULL REFERENCES person (id) ON DELETE CASCADE,
+    PRIMARY KEY (access_group_id, person_id)
+);
+
+CREATE INDEX access_group_person_person_id_idx ON access_group_person (person_id);
+
+CREATE TABLE holiday (
+    date DATE PRIMARY KEY,
+    name TEXT NOT NULL
+);
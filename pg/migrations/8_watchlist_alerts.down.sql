@@ -0,0 +1,3 @@
+DROP TABLE alert;
+
+ALTER TABLE person DROP COLUMN watchlist;
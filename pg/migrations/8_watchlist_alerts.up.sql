@@ -0,0 +1,18 @@
+ALTER TABLE person ADD COLUMN watchlist TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE alert (
+    id BIGSERIAL PRIMARY KEY,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    passage_id TEXT NOT NULL,
+    person_id BIGINT,
+    data JSONB,
+    acknowledged_by TEXT,
+    acknowledged_at TIMESTAMP WITH TIME ZONE,
+    resolved_by TEXT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    comment TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX alert_status_time_idx ON alert (status, time);
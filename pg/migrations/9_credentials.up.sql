@@ -0,0 +1,9 @@
+CREATE TABLE credential (
+    id BIGSERIAL PRIMARY KEY,
+    person_id BIGINT NOT NULL REFERENCES person (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (type, value)
+);
+
+CREATE INDEX credential_person_id_idx ON credential (person_id);
@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"database/sql"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func (s *Storage) PassbackState(personID int64, zone string) (ps entity.PassbackState, err error) {
+	err = s.db.QueryRowx(`
+		SELECT * FROM passback_state WHERE person_id = $1 AND zone = $2
+	`, personID, zone).StructScan(&ps)
+	if err == sql.ErrNoRows {
+		err = entity.ErrPassbackStateNotFound
+	}
+	return
+}
+
+func (s *Storage) SetPassbackState(ps entity.PassbackState) (err error) {
+	_, err = s.db.Exec(`
+		INSERT INTO passback_state (person_id, zone, direction, passage_id, time)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (person_id, zone) DO UPDATE
+		SET direction = EXCLUDED.direction, passage_id = EXCLUDED.passage_id, time = EXCLUDED.time
+	`, ps.PersonID, ps.Zone, ps.Direction, ps.PassageID, ps.Time)
+	return
+}
+
+func (s *Storage) PassbackStates(personID int64) (pss []entity.PassbackState, err error) {
+	err = s.db.Select(&pss, `
+		SELECT * FROM passback_state WHERE person_id = $1 ORDER BY zone
+	`, personID)
+	return
+}
+
+// ResetPassbackStates forgets person's passages in the zone or in all zones
+// if zone is empty, so that person is allowed to pass in any direction.
+func (s *Storage) ResetPassbackStates(personID int64, zone string) (err error) {
+	if zone == "" {
+		_, err = s.db.Exec(`DELETE FROM passback_state WHERE person_id = $1`, personID)
+	} else {
+		_, err = s.db.Exec(`DELETE FROM passback_state WHERE person_id = $1 AND zone = $2`,
+			personID, zone)
+	}
+	return
+}
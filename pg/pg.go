@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,22 +16,65 @@ import (
 	"github.com/Boostport/migration/driver/postgres"
 	"github.com/gobuffalo/packr"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 
 	"github.com/bennyharvey/soma/entity"
 )
 
+// FaceIndex is an in-memory nearest neighbour index over person faces. It
+// needn't be safe for concurrent modification, Storage guards it with
+// personFacesMx. Stale reports whether index degraded after removals and
+// should be rebuilt.
+type FaceIndex interface {
+	Reset([]entity.PersonFace)
+	Add(entity.PersonFace)
+	Remove(personFaceID int64)
+	Search(fd entity.FaceDescriptor, k int) []entity.PersonFaceMatch
+	Len() int
+	Stale() bool
+}
+
 type Storage struct {
 	db  *sqlx.DB
 	uri string
 
-	personFaces   []entity.PersonFace
-	personFacesMx sync.RWMutex
+	personFaces       []entity.PersonFace
+	personFaceIndexes map[int64]int
+	newFaceIndex      func() FaceIndex
+	faceIndex         FaceIndex
+	exactScanBelow    int
+	personFacesMx     sync.RWMutex
+
+	// Face index is built without personFacesMx held, gallery changes made
+	// meanwhile are recorded and replayed before the new index is swapped in.
+	// Stale face index is rebuilt in background, repeated triggers are merged.
+	rebuildingFaceIndex bool
+	faceIndexChanges    []func()
+	faceIndexRebuildMx  sync.Mutex
+	faceIndexStale      chan struct{}
+
+	galleryListener *pq.Listener
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type StorageOption func(s *Storage)
 
-	log *logrus.Entry
+// WithFaceIndex makes storage search closest person faces in the index
+// created by newFaceIndex instead of scanning all of them. Galleries with less
+// than exactScanBelow faces are still scanned exactly.
+func WithFaceIndex(newFaceIndex func() FaceIndex, exactScanBelow int) StorageOption {
+	return func(s *Storage) {
+		s.newFaceIndex = newFaceIndex
+		s.faceIndex = newFaceIndex()
+		s.exactScanBelow = exactScanBelow
+	}
 }
 
-func NewStorage(uri string) (
+func NewStorage(uri string, opts ...StorageOption) (
 	*Storage, error) {
 	db, err := sqlx.Open("postgres", uri)
 	if err != nil {
@@ -43,44 +89,310 @@ func NewStorage(uri string) (
 		return nil, errors.New("ping DB: " + err.Error())
 	}
 
-	return &Storage{
-		db:  db,
-		uri: uri,
-		log: logrus.WithField("subsystem", "postgres_storage"),
-	}, nil
+	s := &Storage{
+		db:                db,
+		uri:               uri,
+		personFaceIndexes: map[int64]int{},
+		faceIndexStale:    make(chan struct{}, 1),
+		log:               logrus.WithField("subsystem", "postgres_storage"),
+		stop:              make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.newFaceIndex != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			for {
+				select {
+				case <-s.stop:
+					return
+				case <-s.faceIndexStale:
+					s.rebuildStaleFaceIndex()
+				}
+			}
+		}()
+	}
+
+	return s, nil
 }
 
 func (s *Storage) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+
+	if s.galleryListener != nil {
+		err := s.galleryListener.Close()
+		if err != nil {
+			s.log.WithError(err).Error("failed to close gallery listener")
+		}
+	}
+
 	return s.db.Close()
 }
 
 //go:generate packr
 
-const migrationsPath = "./migrations"
+const (
+	migrationsPath = "./migrations"
 
-func (s *Storage) Migrate() error {
-	packrSource := &migration.PackrMigrationSource{
+	undefinedTableErrCode = "42P01"
+)
+
+var migrationFileRegexp = regexp.MustCompile(`^((\d+)_.*)\.(up|down)\.sql$`)
+
+type MigrationStatus struct {
+	ID      string
+	Version int64
+	Applied bool
+}
+
+func (s *Storage) migrationSource() migration.Source {
+	return &migration.PackrMigrationSource{
 		Box: packr.NewBox(migrationsPath),
 	}
+}
+
+// Migrate applies all not applied migrations.
+func (s *Storage) Migrate() error {
+	_, err := s.MigrateUp(0)
+	return err
+}
+
+// MigrateUp applies at most max not applied migrations, all of them if max
+// is zero, and returns number of applied migrations.
+func (s *Storage) MigrateUp(max int) (int, error) {
+	return s.migrate(migration.Up, max)
+}
+
+// MigrateDown rolls back at most max last applied migrations, all of them
+// if max is zero, and returns number of rolled back migrations.
+func (s *Storage) MigrateDown(max int) (int, error) {
+	return s.migrate(migration.Down, max)
+}
 
+// MigrateTo applies or rolls back migrations so that the last applied
+// migration has the given version. Zero version rolls back everything.
+func (s *Storage) MigrateTo(version int64) (int, error) {
+	mss, err := s.MigrationStatuses()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		known    bool
+		up, down int
+	)
+
+	for _, ms := range mss {
+		if ms.Version == version {
+			known = true
+		}
+		if ms.Version <= version && !ms.Applied {
+			up++
+		}
+		if ms.Version > version && ms.Applied {
+			down++
+		}
+	}
+
+	if !known && version != 0 {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	switch {
+	case up > 0 && down > 0:
+		return 0, errors.New("both not applied and applied migrations around target version, " +
+			"migrate up or down first")
+	case up > 0:
+		return s.migrate(migration.Up, up)
+	case down > 0:
+		return s.migrate(migration.Down, down)
+	}
+
+	return 0, nil
+}
+
+func (s *Storage) migrate(direction migration.Direction, max int) (int, error) {
 	d, err := postgres.New(s.uri)
 	if err != nil {
-		return errors.New("create migration driver: " + err.Error())
+		return 0, errors.New("create migration driver: " + err.Error())
 	}
 
-	_, err = migration.Migrate(d, packrSource, migration.Up, 0)
+	n, err := migration.Migrate(d, s.migrationSource(), direction, max)
 	if err != nil {
-		return errors.New("migrate: " + err.Error())
+		return n, errors.New("migrate: " + err.Error())
 	}
 
-	return nil
+	return n, nil
+}
+
+// MigrationStatuses returns all known migrations sorted by version with
+// flag whether they are applied.
+func (s *Storage) MigrationStatuses() ([]MigrationStatus, error) {
+	files, err := s.migrationSource().ListMigrationFiles()
+	if err != nil {
+		return nil, fmt.Errorf("list migration files: %w", err)
+	}
+
+	var applied []string
+
+	err = s.db.Select(&applied, `SELECT version FROM schema_migration`)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); !ok || pqErr.Code != undefinedTableErrCode {
+			return nil, fmt.Errorf("select applied migrations: %w", err)
+		}
+	}
+
+	appliedMap := map[string]bool{}
+	for _, id := range applied {
+		appliedMap[id] = true
+	}
+
+	var (
+		mss  []MigrationStatus
+		seen = map[string]bool{}
+	)
+
+	for _, f := range files {
+		m := migrationFileRegexp.FindStringSubmatch(f)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+
+		version, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse migration %s version: %w", m[1], err)
+		}
+
+		mss = append(mss, MigrationStatus{
+			ID:      m[1],
+			Version: version,
+			Applied: appliedMap[m[1]],
+		})
+	}
+
+	sort.Slice(mss, func(i, j int) bool { return mss[i].Version < mss[j].Version })
+
+	return mss, nil
 }
 
 func (s *Storage) LoadPersonFaces() error {
+	s.faceIndexRebuildMx.Lock()
+	defer s.faceIndexRebuildMx.Unlock()
+
+	s.personFacesMx.Lock()
+	s.rebuildingFaceIndex = true
+	s.personFacesMx.Unlock()
+
+	var pfs []entity.PersonFace
+
+	err := s.db.Select(&pfs, `select * from person_face`)
+	if err != nil {
+		s.personFacesMx.Lock()
+		s.rebuildingFaceIndex = false
+		s.faceIndexChanges = nil
+		s.personFacesMx.Unlock()
+		return err
+	}
+
+	s.swapGallery(pfs)
+
+	return nil
+}
+
+// rebuildStaleFaceIndex rebuilds face index from current gallery if it is
+// stale.
+func (s *Storage) rebuildStaleFaceIndex() {
+	s.faceIndexRebuildMx.Lock()
+	defer s.faceIndexRebuildMx.Unlock()
+
+	s.personFacesMx.Lock()
+
+	if s.faceIndex == nil || !s.faceIndex.Stale() {
+		s.personFacesMx.Unlock()
+		return
+	}
+
+	pfs := append([]entity.PersonFace(nil), s.personFaces...)
+	s.rebuildingFaceIndex = true
+
+	s.personFacesMx.Unlock()
+
+	s.swapGallery(pfs)
+}
+
+// swapGallery builds face index over person faces, then replaces gallery with
+// them and replays changes recorded since rebuilding started. Caller must
+// hold faceIndexRebuildMx and set rebuildingFaceIndex.
+func (s *Storage) swapGallery(pfs []entity.PersonFace) {
+	var fi FaceIndex
+
+	if s.newFaceIndex != nil {
+		fi = s.newFaceIndex()
+		fi.Reset(pfs)
+	}
+
 	s.personFacesMx.Lock()
 	defer s.personFacesMx.Unlock()
 
-	return s.db.Select(&s.personFaces, `select * from person_face`)
+	s.personFaces = pfs
+	s.faceIndex = fi
+
+	s.personFaceIndexes = make(map[int64]int, len(pfs))
+	for i, pf := range pfs {
+		s.personFaceIndexes[pf.ID] = i
+	}
+
+	// Replayed changes are idempotent for the gallery, so ones already
+	// present in pfs are harmless.
+	for _, change := range s.faceIndexChanges {
+		change()
+	}
+
+	s.rebuildingFaceIndex = false
+	s.faceIndexChanges = nil
+
+	// Replayed removals may leave the new index stale already.
+	if fi != nil && fi.Stale() {
+		s.triggerFaceIndexRebuild()
+	}
+}
+
+// changeGallery applies change to in-memory gallery and triggers face index
+// rebuilding if the change made it stale.
+func (s *Storage) changeGallery(change func()) {
+	s.personFacesMx.Lock()
+	defer s.personFacesMx.Unlock()
+
+	change()
+
+	if s.rebuildingFaceIndex {
+		s.faceIndexChanges = append(s.faceIndexChanges, change)
+		return
+	}
+
+	if s.faceIndex != nil && s.faceIndex.Stale() {
+		s.triggerFaceIndexRebuild()
+	}
+}
+
+// triggerFaceIndexRebuild makes background rebuilding rebuild stale face
+// index, unless it is already triggered.
+func (s *Storage) triggerFaceIndexRebuild() {
+	select {
+	case s.faceIndexStale <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Storage) useFaceIndex() bool {
+	return s.faceIndex != nil && len(s.personFaces) >= s.exactScanBelow
 }
 
 func (s *Storage) FindClosestPersonFace(fd entity.FaceDescriptor) (entity.PersonFace, float64, bool) {
@@ -91,6 +403,14 @@ func (s *Storage) FindClosestPersonFace(fd entity.FaceDescriptor) (entity.Person
 		return entity.PersonFace{}, 0, false
 	}
 
+	if s.useFaceIndex() {
+		ms := s.faceIndex.Search(fd, 1)
+		if len(ms) == 0 {
+			return entity.PersonFace{}, 0, false
+		}
+		return ms[0].PersonFace, ms[0].Distance, true
+	}
+
 	closestPF := s.personFaces[0]
 	closestDistance := entity.FaceDescriptorDistance(closestPF.Descriptor, fd)
 
@@ -105,6 +425,74 @@ func (s *Storage) FindClosestPersonFace(fd entity.FaceDescriptor) (entity.Person
 	return closestPF, closestDistance, true
 }
 
+// FindClosestPersons returns persons owning k closest to descriptor person
+// faces sorted by distance. If all of them are owned by one person, the
+// closest face of the runner-up person is added, so callers can always tell
+// how ambiguous the match is.
+func (s *Storage) FindClosestPersons(fd entity.FaceDescriptor, k int) []entity.PersonMatch {
+	if k <= 0 {
+		return nil
+	}
+
+	s.personFacesMx.RLock()
+	defer s.personFacesMx.RUnlock()
+
+	if s.useFaceIndex() {
+		for {
+			pms := entity.GroupPersonFaceMatches(s.faceIndex.Search(fd, k))
+			if len(pms) != 1 || k >= s.faceIndex.Len() {
+				return pms
+			}
+			k *= 2
+		}
+	}
+
+	var closest []entity.PersonFaceMatch
+
+	for _, pf := range s.personFaces {
+		distance := entity.FaceDescriptorDistance(pf.Descriptor, fd)
+		if len(closest) == k && distance >= closest[k-1].Distance {
+			continue
+		}
+
+		i := sort.Search(len(closest), func(i int) bool {
+			return closest[i].Distance > distance
+		})
+
+		if len(closest) < k {
+			closest = append(closest, entity.PersonFaceMatch{})
+		}
+		copy(closest[i+1:], closest[i:])
+		closest[i] = entity.PersonFaceMatch{PersonFace: pf, Distance: distance}
+	}
+
+	pms := entity.GroupPersonFaceMatches(closest)
+
+	if len(pms) == 1 {
+		var runnerUp *entity.PersonFaceMatch
+
+		for _, pf := range s.personFaces {
+			if pf.PersonID == pms[0].PersonID {
+				continue
+			}
+			distance := entity.FaceDescriptorDistance(pf.Descriptor, fd)
+			if runnerUp == nil || distance < runnerUp.Distance {
+				runnerUp = &entity.PersonFaceMatch{PersonFace: pf, Distance: distance}
+			}
+		}
+
+		if runnerUp != nil {
+			pms = append(pms, entity.PersonMatch{
+				PersonID: runnerUp.PersonID,
+				Distance: runnerUp.Distance,
+				Faces:    []entity.PersonFaceMatch{*runnerUp},
+			})
+		}
+	}
+
+	return pms
+}
+
 func (s *Storage) AddEvent(e entity.Event) error {
 	_, err := s.db.Exec(`insert into event (time, type, passage_id , data) values ($1, $2, $3, $4)`, e.Time, e.Type, e.PassageID, e.Data)
 	return err
@@ -153,45 +541,47 @@ func (s *Storage) Users() (us []entity.User, err error) {
 
 func (s *Storage) Person(personID int64) (p entity.Person, err error) {
 	err = s.db.QueryRowx(`SELECT * FROM person WHERE id = $1`, personID).StructScan(&p)
+	if err == sql.ErrNoRows {
+		err = entity.ErrPersonNotFound
+	}
 	return
 }
 
 func (s *Storage) AddPerson(p entity.Person) (entity.Person, error) {
 	err := s.db.QueryRow(`
-		INSERT INTO person (name, position, unit)
-		VALUES ($1, $2, $3)
+		INSERT INTO person (name, position, unit, watchlist)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id
-	`, p.Name, p.Position, p.Unit).Scan(&p.ID)
+	`, p.Name, p.Position, p.Unit, p.Watchlist).Scan(&p.ID)
 	return p, err
 }
 
 func (s *Storage) SetPerson(p entity.Person) (err error) {
 	_, err = s.db.Exec(`
-		UPDATE person SET name = $1, position = $2, unit = $3
-		WHERE id = $4
-	`, p.Name, p.Position, p.Unit, p.ID)
+		UPDATE person SET name = $1, position = $2, unit = $3, watchlist = $4
+		WHERE id = $5
+	`, p.Name, p.Position, p.Unit, p.Watchlist, p.ID)
 	return
 }
 
 func (s *Storage) removePersonFaces(personID int64) {
-	s.personFacesMx.Lock()
-	defer s.personFacesMx.Unlock()
+	s.changeGallery(func() {
+		s.removeGalleryPersonFaces(personID)
+	})
+}
 
-	var toRemove int
+func (s *Storage) removeGalleryPersonFaces(personID int64) {
+	var ids []int64
 
-	for i := len(s.personFaces) - 1; i >= 0; i-- {
-		if s.personFaces[i].PersonID == personID {
-			if i == len(s.personFaces)-1-toRemove {
-				toRemove++
-				continue
-			}
-			s.personFaces[i], s.personFaces[len(s.personFaces)-toRemove-1] =
-				s.personFaces[len(s.personFaces)-toRemove-1], s.personFaces[i]
-			toRemove++
+	for _, pf := range s.personFaces {
+		if pf.PersonID == personID {
+			ids = append(ids, pf.ID)
 		}
 	}
 
-	s.personFaces = s.personFaces[:len(s.personFaces)-toRemove]
+	for _, id := range ids {
+		s.deleteGalleryPersonFace(id)
+	}
 }
 
 func (s *Storage) RemovePerson(personID int64) error {
@@ -210,8 +600,13 @@ func (s *Storage) Persons() (ps []entity.Person, err error) {
 	return
 }
 
+// AddPersonFace adds person face. Visitor enrolled again after faces removal
+// gets faces removed by cleanup again.
 func (s *Storage) AddPersonFace(pf entity.PersonFace) (entity.PersonFace, error) {
 	err := s.db.QueryRow(`
+		WITH enrolled AS (
+			UPDATE visitor_pass SET faces_removed = FALSE WHERE person_id = $1
+		)
 		INSERT INTO person_face(person_id, descriptor, photo_id)
 		VALUES ($1, $2, $3)
 		RETURNING id
@@ -220,13 +615,40 @@ func (s *Storage) AddPersonFace(pf entity.PersonFace) (entity.PersonFace, error)
 		return entity.PersonFace{}, err
 	}
 
-	s.personFacesMx.Lock()
-	s.personFaces = append(s.personFaces, pf)
-	s.personFacesMx.Unlock()
+	s.addGalleryPersonFace(pf)
 
 	return pf, nil
 }
 
+// addGalleryPersonFace adds person face to in-memory gallery or replaces the
+// one with the same ID.
+func (s *Storage) addGalleryPersonFace(pf entity.PersonFace) {
+	s.changeGallery(func() {
+		s.putGalleryPersonFace(pf)
+	})
+}
+
+// putGalleryPersonFace skips person face identical to the stored one, since
+// changes made by this process come back with their own notifications.
+func (s *Storage) putGalleryPersonFace(pf entity.PersonFace) {
+	i, exists := s.personFaceIndexes[pf.ID]
+	if exists && s.personFaces[i] == pf {
+		return
+	}
+
+	if s.faceIndex != nil {
+		s.faceIndex.Add(pf)
+	}
+
+	if exists {
+		s.personFaces[i] = pf
+		return
+	}
+
+	s.personFaceIndexes[pf.ID] = len(s.personFaces)
+	s.personFaces = append(s.personFaces, pf)
+}
+
 func (s *Storage) RemovePersonFace(id int64) error {
 	_, err := s.db.Exec(`
 		DELETE FROM person_face WHERE id = $1
@@ -235,26 +657,36 @@ func (s *Storage) RemovePersonFace(id int64) error {
 		return err
 	}
 
-	var removeI = -1
+	s.removeGalleryPersonFace(id)
 
-	s.personFacesMx.Lock()
+	return nil
+}
 
-	for i, pf := range s.personFaces {
-		if pf.ID == id {
-			removeI = i
-			break
-		}
+func (s *Storage) removeGalleryPersonFace(id int64) {
+	s.changeGallery(func() {
+		s.deleteGalleryPersonFace(id)
+	})
+}
+
+func (s *Storage) deleteGalleryPersonFace(id int64) {
+	i, exists := s.personFaceIndexes[id]
+	if !exists {
+		return
 	}
 
-	if removeI >= 0 {
-		s.personFaces[removeI] =
-			s.personFaces[len(s.personFaces)-1]
-		s.personFaces = s.personFaces[:len(s.personFaces)-1]
+	if s.faceIndex != nil {
+		s.faceIndex.Remove(id)
 	}
 
-	s.personFacesMx.Unlock()
+	last := len(s.personFaces) - 1
 
-	return nil
+	if i != last {
+		s.personFaces[i] = s.personFaces[last]
+		s.personFaceIndexes[s.personFaces[i].ID] = i
+	}
+
+	s.personFaces = s.personFaces[:last]
+	delete(s.personFaceIndexes, id)
 }
 
 func (s *Storage) PersonFaces(personID int64) (pfs []entity.PersonFace,
@@ -268,12 +700,111 @@ func (s *Storage) PersonFaces(personID int64) (pfs []entity.PersonFace,
 func (s *Storage) Events(fs ...entity.EventsFilter) (
 	es []entity.Event, err error) {
 
+	q, err := buildEventsQuery(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Select(&es, q.query, q.args...)
+
+	return
+}
+
+// EventsPage returns events with cursor pointing to the next page, which is
+// set only if events are ordered by time and the page is full, and total
+// count of filtered events if requested.
+func (s *Storage) EventsPage(fs ...entity.EventsFilter) (
+	ep entity.EventsPage, err error) {
+
+	q, err := buildEventsQuery(fs)
+	if err != nil {
+		return entity.EventsPage{}, err
+	}
+
+	err = s.db.Select(&ep.Events, q.query, q.args...)
+	if err != nil {
+		return entity.EventsPage{}, fmt.Errorf("select events: %w", err)
+	}
+
+	if q.byTime && q.filters.Set.Limit && len(ep.Events) == q.filters.Limit {
+		last := ep.Events[len(ep.Events)-1]
+		ep.NextCursor = entity.EventsCursor{Time: last.Time, ID: last.ID}.String()
+	}
+
+	if q.filters.WithTotal {
+		var total int64
+
+		err = s.db.Get(&total, q.countQuery, q.countArgs...)
+		if err != nil {
+			return entity.EventsPage{}, fmt.Errorf("count events: %w", err)
+		}
+
+		ep.Total = &total
+	}
+
+	return ep, nil
+}
+
+// EachEvent calls fn for every filtered event reading them one by one, so
+// that any number of events can be processed without loading all of them.
+func (s *Storage) EachEvent(fn func(entity.Event) error, fs ...entity.EventsFilter) error {
+	q, err := buildEventsQuery(fs)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Queryx(q.query, q.args...)
+	if err != nil {
+		return fmt.Errorf("query events: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			s.log.WithError(err).Error("failed to close events rows")
+		}
+	}()
+
+	for rows.Next() {
+		var e entity.Event
+
+		err = rows.StructScan(&e)
+		if err != nil {
+			return fmt.Errorf("scan event: %w", err)
+		}
+
+		err = fn(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type eventsQuery struct {
+	filters entity.EventsFilters
+
+	query string
+	args  []interface{}
+
+	countQuery string
+	countArgs  []interface{}
+
+	// byTime is set when events are ordered by time and id, so they can be
+	// paginated with cursor.
+	byTime bool
+}
+
+func buildEventsQuery(fs []entity.EventsFilter) (q eventsQuery, err error) {
 	var filters entity.EventsFilters
 
 	for _, f := range fs {
 		f(&filters)
 	}
 
+	q.filters = filters
+
 	var (
 		args   []interface{}
 		wheres []string
@@ -296,7 +827,7 @@ func (s *Storage) Events(fs ...entity.EventsFilter) (
 
 	if filters.Set.PassageID {
 		args = append(args, filters.PassageID)
-		wheres = append(wheres, fmt.Sprintf("data->>'passage_id' = $%d", len(args)))
+		wheres = append(wheres, fmt.Sprintf("passage_id = $%d", len(args)))
 	}
 
 	if filters.Set.PersonID {
@@ -319,43 +850,74 @@ func (s *Storage) Events(fs ...entity.EventsFilter) (
 		wheres = append(wheres, fmt.Sprintf("data->>'person_unit' ILIKE '%%' || $%d || '%%'", len(args)))
 	}
 
-	where := strings.Join(wheres, " and ")
-	if where != "" {
-		where = "where " + where
+	q.countArgs = append([]interface{}{}, args...)
+	q.countQuery = `select count(*) from event ` + joinWheres(wheres)
+
+	direction := "asc"
+
+	if filters.Set.OrderDirection {
+		switch filters.OrderDirection {
+		case "asc", "desc":
+			direction = filters.OrderDirection
+		default:
+			return eventsQuery{}, entity.InvalidParamErr{Param: "order_direction"}
+		}
 	}
 
 	var order string
 
 	if filters.Set.OrderBy {
 		switch filters.OrderBy {
-		case "id", "time":
-			order = filters.OrderBy
-		case "passage_id", "person_name", "person_position", "person_unit":
-			order = fmt.Sprintf("data->>'%s'", filters.OrderBy)
+		case "time":
+			order = "time " + direction + ", id " + direction
+			q.byTime = true
+		case "id", "passage_id":
+			order = filters.OrderBy + " " + direction
+		case "person_name", "person_position", "person_unit":
+			order = fmt.Sprintf("data->>'%s' %s", filters.OrderBy, direction)
 		case "person_id":
-			order = "(data->>'person_id')::bigint"
+			order = "(data->>'person_id')::bigint " + direction
 		default:
-			return nil, entity.InvalidParamErr{Param: "order_by"}
+			return eventsQuery{}, entity.InvalidParamErr{Param: "order_by"}
 		}
 	}
 
+	// Pages are the newest events first unless ordered otherwise, so that
+	// they can be continued with cursor.
+	if !filters.Set.OrderBy && (filters.Set.After || filters.Set.Limit) {
+		if !filters.Set.OrderDirection {
+			direction = "desc"
+		}
+		order = "time " + direction + ", id " + direction
+		q.byTime = true
+	}
+
+	if filters.Set.After {
+		if !q.byTime {
+			return eventsQuery{}, entity.InvalidParamErr{Param: "cursor"}
+		}
+		if filters.Set.OffSet {
+			return eventsQuery{}, entity.InvalidParamErr{Param: "offset"}
+		}
+
+		cmp := ">"
+		if direction == "desc" {
+			cmp = "<"
+		}
+
+		args = append(args, filters.After.Time, filters.After.ID)
+		wheres = append(wheres, fmt.Sprintf("(time, id) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+	}
+
 	if order != "" {
 		order = "ORDER BY " + order
-		if filters.Set.OrderDirection {
-			switch filters.OrderDirection {
-			case "asc", "desc":
-				order += " " + filters.OrderDirection
-			default:
-				return nil, entity.InvalidParamErr{Param: "order_direction"}
-			}
-		}
 	}
 
 	var limit string
 
 	if filters.Set.Limit {
 		if filters.Limit <= 0 {
-			return nil, entity.InvalidParamErr{Param: "limit"}
+			return eventsQuery{}, entity.InvalidParamErr{Param: "limit"}
 		}
 		limit = fmt.Sprintf("limit %d", filters.Limit)
 	}
@@ -364,12 +926,20 @@ func (s *Storage) Events(fs ...entity.EventsFilter) (
 
 	if filters.Set.OffSet {
 		if filters.Offset < 0 {
-			return nil, entity.InvalidParamErr{Param: "offset"}
+			return eventsQuery{}, entity.InvalidParamErr{Param: "offset"}
 		}
 		offset = fmt.Sprintf("offset %d", filters.Offset)
 	}
 
-	err = s.db.Select(&es, `select * from event `+where+` `+order+` `+limit+` `+offset, args...)
+	q.query = `select * from event ` + joinWheres(wheres) + ` ` + order + ` ` + limit + ` ` + offset
+	q.args = args
 
-	return
+	return q, nil
+}
+
+func joinWheres(wheres []string) string {
+	if len(wheres) == 0 {
+		return ""
+	}
+	return "where " + strings.Join(wheres, " and ")
 }
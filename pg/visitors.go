@@ -0,0 +1,138 @@
+package pg
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const selectVisitors = `
+	SELECT vp.*, p.name
+	FROM visitor_pass vp
+	JOIN person p ON p.id = vp.person_id
+`
+
+func (s *Storage) Visitor(personID int64) (v entity.Visitor, err error) {
+	err = s.db.QueryRowx(selectVisitors+`
+		WHERE vp.person_id = $1
+	`, personID).StructScan(&v)
+	if err == sql.ErrNoRows {
+		err = entity.ErrVisitorNotFound
+	}
+	return
+}
+
+func (s *Storage) Visitors() (vs []entity.Visitor, err error) {
+	err = s.db.Select(&vs, selectVisitors+`
+		ORDER BY vp.valid_from DESC
+	`)
+	return
+}
+
+// AddVisitor adds person of visitor and the pass.
+func (s *Storage) AddVisitor(v entity.Visitor) (entity.Visitor, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return v, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO person (name, position, unit, watchlist)
+		VALUES ($1, '', '', '')
+		RETURNING id
+	`, v.Name).Scan(&v.PersonID)
+	if err != nil {
+		_ = tx.Rollback()
+		return v, fmt.Errorf("insert person: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO visitor_pass (person_id, host_person_id, purpose, valid_from, valid_to, passage_ids,
+			max_entries)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, v.PersonID, v.HostPersonID, v.Purpose, v.ValidFrom, v.ValidTo, v.PassageIDs, v.MaxEntries)
+	if err != nil {
+		_ = tx.Rollback()
+		return v, fmt.Errorf("insert visitor pass: %w", err)
+	}
+
+	v.Entries = 0
+	v.FacesRemoved = false
+
+	return v, tx.Commit()
+}
+
+// SetVisitor updates visitor name and pass. Entries are kept. Faces removed
+// by cleanup are not restored, faces_removed stays set until visitor is
+// enrolled again.
+func (s *Storage) SetVisitor(v entity.Visitor) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		UPDATE visitor_pass SET host_person_id = $1, purpose = $2, valid_from = $3, valid_to = $4,
+			passage_ids = $5, max_entries = $6
+		WHERE person_id = $7
+	`, v.HostPersonID, v.Purpose, v.ValidFrom, v.ValidTo, v.PassageIDs, v.MaxEntries, v.PersonID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("update visitor pass: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		_ = tx.Rollback()
+		return entity.ErrVisitorNotFound
+	}
+
+	_, err = tx.Exec(`UPDATE person SET name = $1 WHERE id = $2`, v.Name, v.PersonID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("update person: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AddVisitorEntry counts entry of visitor, it does nothing if person is not a
+// visitor.
+func (s *Storage) AddVisitorEntry(personID int64) (err error) {
+	_, err = s.db.Exec(`
+		UPDATE visitor_pass SET entries = entries + 1 WHERE person_id = $1
+	`, personID)
+	return
+}
+
+// RemoveExpiredVisitorFaces removes faces of visitors with passes expired
+// before given time and returns count of removed faces. Visitors themselves
+// are kept for history.
+func (s *Storage) RemoveExpiredVisitorFaces(before time.Time) (int64, error) {
+	var ids []int64
+
+	err := s.db.Select(&ids, `
+		WITH expired AS (
+			UPDATE visitor_pass SET faces_removed = TRUE
+			WHERE valid_to < $1 AND NOT faces_removed
+			RETURNING person_id
+		)
+		DELETE FROM person_face WHERE person_id IN (SELECT person_id FROM expired)
+		RETURNING id
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		s.removeGalleryPersonFace(id)
+	}
+
+	return int64(len(ids)), nil
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	// Reports are built for arbitrary time zones, don't depend on system
+	// time zone database.
+	_ "time/tzdata"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const dateLayout = "2006-01-02"
+
+// AttendanceDay is person's attendance during a day. Time on site is summed
+// over in-out intervals within the day, so entries left without exit by the
+// end of the day are not counted.
+type AttendanceDay struct {
+	Date           string     `json:"date"`
+	PersonID       int64      `json:"person_id"`
+	PersonName     string     `json:"person_name"`
+	PersonPosition string     `json:"person_position"`
+	PersonUnit     string     `json:"person_unit"`
+	FirstEntry     *time.Time `json:"first_entry"`
+	LastExit       *time.Time `json:"last_exit"`
+	TimeOnSite     int64      `json:"time_on_site"`
+	Late           bool       `json:"late"`
+	LateBy         int64      `json:"late_by"`
+
+	insideSince *time.Time
+	timeOnSite  time.Duration
+}
+
+type attendanceKey struct {
+	date     string
+	personID int64
+}
+
+// AttendanceBuilder builds attendance days from passage open events which
+// must be added in time order. Passage direction defines whether the event
+// is an entry or an exit, events of passages with unknown direction are
+// ignored.
+type AttendanceBuilder struct {
+	loc        *time.Location
+	workStart  time.Duration
+	directions map[string]entity.Direction
+
+	days map[attendanceKey]*AttendanceDay
+}
+
+// NewAttendanceBuilder creates builder which splits days in loc and treats
+// first entries later than workStart after local midnight as late.
+func NewAttendanceBuilder(loc *time.Location, workStart time.Duration,
+	directions map[string]entity.Direction) *AttendanceBuilder {
+
+	return &AttendanceBuilder{
+		loc:        loc,
+		workStart:  workStart,
+		directions: directions,
+		days:       map[attendanceKey]*AttendanceDay{},
+	}
+}
+
+func (ab *AttendanceBuilder) Add(e entity.Event) error {
+	if e.Type != entity.PassageOpen {
+		return nil
+	}
+
+	var d entity.PassageOpenData
+
+	err := json.Unmarshal(e.Data, &d)
+	if err != nil {
+		return fmt.Errorf("JSON unmarshal passage open data: %w", err)
+	}
+
+	passageID := e.PassageID
+	if passageID == "" {
+		passageID = d.PassageID
+	}
+
+	direction, known := ab.directions[passageID]
+	if !known {
+		return nil
+	}
+
+	// Passage opened manually without person isn't anyone's attendance.
+	if d.PersonID == 0 {
+		return nil
+	}
+
+	t := e.Time.In(ab.loc)
+
+	key := attendanceKey{date: t.Format(dateLayout), personID: d.PersonID}
+
+	day, exists := ab.days[key]
+	if !exists {
+		day = &AttendanceDay{
+			Date:     key.date,
+			PersonID: d.PersonID,
+		}
+		ab.days[key] = day
+	}
+
+	// Person data is taken from the latest event.
+	day.PersonName = d.PersonName
+	day.PersonPosition = d.PersonPosition
+	day.PersonUnit = d.PersonUnit
+
+	switch direction {
+	case entity.In:
+		if day.FirstEntry == nil {
+			day.FirstEntry = &t
+
+			y, m, dd := t.Date()
+			start := time.Date(y, m, dd, 0, 0, 0, 0, ab.loc).Add(ab.workStart)
+			if t.After(start) {
+				day.Late = true
+				day.LateBy = int64(t.Sub(start).Seconds())
+			}
+		}
+		if day.insideSince == nil {
+			day.insideSince = &t
+		}
+
+	case entity.Out:
+		day.LastExit = &t
+		if day.insideSince != nil {
+			day.timeOnSite += t.Sub(*day.insideSince)
+			day.insideSince = nil
+		}
+	}
+
+	return nil
+}
+
+// Days returns attendance days sorted by date and person name.
+func (ab *AttendanceBuilder) Days() []AttendanceDay {
+	days := make([]AttendanceDay, 0, len(ab.days))
+
+	for _, d := range ab.days {
+		d.TimeOnSite = int64(d.timeOnSite.Seconds())
+		days = append(days, *d)
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].Date != days[j].Date {
+			return days[i].Date < days[j].Date
+		}
+		if days[i].PersonName != days[j].PersonName {
+			return days[i].PersonName < days[j].PersonName
+		}
+		return days[i].PersonID < days[j].PersonID
+	})
+
+	return days
+}
+
+// ParseDateRange parses inclusive from and to dates in loc into time range.
+func ParseDateRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	fromTime, err := time.ParseInLocation(dateLayout, from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse from: %w", err)
+	}
+
+	toTime, err := time.ParseInLocation(dateLayout, to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse to: %w", err)
+	}
+
+	if toTime.Before(fromTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to is before from")
+	}
+
+	return fromTime, toTime.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,535 @@
+package sigur
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// Sigur OIF protocol subset. Commands and responses are lines, response to
+// command is either OK, ERROR with code and text, or command specific data
+// line. Subscribed events are sent by server at any time as EVENT_CE lines.
+
+const (
+	protocolVersion = "1.8"
+
+	okResponse     = "OK"
+	errorResponse  = "ERROR"
+	apInfoResponse = "APINFO"
+	eventPrefix    = "EVENT_CE"
+
+	// EventTimeLayout is layout of event date and time fields.
+	EventTimeLayout = "2006-01-02 15:04:05"
+)
+
+// Event types of EVENT_CE lines.
+const (
+	PassEvent = 1
+	KeyEvent  = 2
+)
+
+// Event directions of EVENT_CE lines.
+const (
+	unknownDirection = 0
+	inDirection      = 1
+	outDirection     = 2
+)
+
+const eventsBufferSize = 16
+
+// Reconnect periods are variables to be shortened by tests.
+var (
+	minReconnectPeriod = time.Second
+	maxReconnectPeriod = 30 * time.Second
+)
+
+var ErrNotConnected = errors.New("not connected")
+
+// ResponseError is ERROR response to command.
+type ResponseError struct {
+	Code int
+	Text string
+}
+
+func (e ResponseError) Error() string {
+	return fmt.Sprintf("error %d: %s", e.Code, e.Text)
+}
+
+// Event is controller event of subscription. Direction is empty if it is
+// unknown, Key is hex card number of key events.
+type Event struct {
+	Time          time.Time
+	Type          int
+	AccessPointID int
+	ObjectID      int
+	Direction     entity.Direction
+	Key           string
+}
+
+// APInfo is access point information, State is like ONLINE_NORMAL or
+// OFFLINE.
+type APInfo struct {
+	ID    int
+	Name  string
+	State string
+}
+
+func (i APInfo) Online() bool {
+	return strings.HasPrefix(i.State, "ONLINE")
+}
+
+// Client keeps one authenticated session with Sigur server and reconnects
+// with growing period when it is lost. Commands fail with ErrNotConnected
+// while there is no session. Command timeout closes the session, so late
+// response can't be taken for response to the next command.
+type Client struct {
+	address   string
+	username  string
+	password  string
+	timeout   time.Duration
+	subscribe bool
+
+	conn   net.Conn
+	connMx sync.Mutex
+
+	commandMx sync.Mutex
+	responses chan string
+	events    chan Event
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewClient creates client and starts connecting. With subscribe client
+// subscribes to controller events, which are sent to Events channel.
+func NewClient(address, username, password string, timeout time.Duration, subscribe bool) *Client {
+	c := &Client{
+		address:   address,
+		username:  username,
+		password:  password,
+		timeout:   timeout,
+		subscribe: subscribe,
+		responses: make(chan string, 1),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": "sigur_client",
+			"address":   address,
+		}),
+		stop: make(chan struct{}),
+	}
+
+	if subscribe {
+		c.events = make(chan Event, eventsBufferSize)
+	}
+
+	c.wg.Add(1)
+	go c.run()
+
+	return c
+}
+
+// Close closes session and stops reconnecting. Events channel is closed.
+func (c *Client) Close() error {
+	close(c.stop)
+
+	c.connMx.Lock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	c.connMx.Unlock()
+
+	c.wg.Wait()
+
+	return nil
+}
+
+// Events returns subscribed controller events, it is nil without
+// subscription.
+func (c *Client) Events() <-chan Event {
+	if c.events == nil {
+		return nil
+	}
+	return c.events
+}
+
+func (c *Client) run() {
+	defer c.wg.Done()
+
+	if c.events != nil {
+		defer close(c.events)
+	}
+
+	reconnectPeriod := minReconnectPeriod
+
+	for {
+		loggedIn, err := c.session()
+
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		if loggedIn {
+			reconnectPeriod = minReconnectPeriod
+		}
+
+		c.log.WithError(err).WithField("reconnect_period", reconnectPeriod).Warn("session lost")
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(reconnectPeriod):
+		}
+
+		reconnectPeriod *= 2
+		if reconnectPeriod > maxReconnectPeriod {
+			reconnectPeriod = maxReconnectPeriod
+		}
+	}
+}
+
+// session connects, logs in, subscribes and reads server lines until
+// connection fails. It returns whether login succeeded.
+func (c *Client) session() (bool, error) {
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return false, fmt.Errorf("dial: %w", err)
+	}
+
+	defer func() {
+		c.connMx.Lock()
+		c.conn = nil
+		c.connMx.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+
+	err = expectOK(c.exchange(conn, r, fmt.Sprintf("LOGIN %s %s %s", protocolVersion, c.username, c.password)))
+	if err != nil {
+		return false, fmt.Errorf("login: %w", err)
+	}
+
+	if c.subscribe {
+		err = expectOK(c.exchange(conn, r, "SUBSCRIBE CE"))
+		if err != nil {
+			return true, fmt.Errorf("subscribe: %w", err)
+		}
+	}
+
+	c.connMx.Lock()
+	select {
+	case <-c.stop:
+		c.connMx.Unlock()
+		return true, errors.New("client is closed")
+	default:
+	}
+	c.conn = conn
+	c.connMx.Unlock()
+
+	c.log.Info("session started")
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return true, fmt.Errorf("reset deadline: %w", err)
+	}
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return true, fmt.Errorf("read: %w", err)
+		}
+
+		if strings.HasPrefix(line, eventPrefix+" ") {
+			c.handleEvent(line)
+			continue
+		}
+
+		select {
+		case c.responses <- line:
+		default:
+			c.log.WithField("line", line).Warn("unexpected response, skipping")
+		}
+	}
+}
+
+// exchange sends command and reads its response before session is started.
+func (c *Client) exchange(conn net.Conn, r *bufio.Reader, cmd string) (string, error) {
+	err := conn.SetDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return "", fmt.Errorf("set deadline: %w", err)
+	}
+
+	_, err = conn.Write([]byte(cmd + "\r\n"))
+	if err != nil {
+		return "", fmt.Errorf("write: %w", err)
+	}
+
+	line, err := readLine(r)
+	if err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	return parseResponse(line)
+}
+
+// handleEvent sends event without blocking, so responses to commands are
+// not held up by unread events.
+func (c *Client) handleEvent(line string) {
+	e, err := parseEvent(line)
+	if err != nil {
+		c.log.WithError(err).WithField("line", line).Warn("failed to parse event, skipping")
+		return
+	}
+
+	if c.events == nil {
+		return
+	}
+
+	select {
+	case c.events <- e:
+	default:
+		c.log.WithField("line", line).Warn("events buffer is full, event dropped")
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseResponse returns data line of response, which is empty for OK, or
+// error for ERROR response.
+func parseResponse(line string) (string, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) == 0 {
+		return "", errors.New("empty response")
+	}
+
+	switch fields[0] {
+	case okResponse:
+		if len(fields) != 1 {
+			return "", fmt.Errorf("unexpected response: %s", line)
+		}
+		return "", nil
+
+	case errorResponse:
+		if len(fields) < 2 {
+			return "", fmt.Errorf("unexpected response: %s", line)
+		}
+		code, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "", fmt.Errorf("unexpected response: %s", line)
+		}
+		return "", ResponseError{Code: code, Text: strings.Join(fields[2:], " ")}
+	}
+
+	return line, nil
+}
+
+// expectOK returns error unless response is OK.
+func expectOK(data string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if data != "" {
+		return fmt.Errorf("unexpected response: %s", data)
+	}
+
+	return nil
+}
+
+// parseEvent parses EVENT_CE <date> <time> <type> <ap id> <object id>
+// <direction> [<key>] line.
+func parseEvent(line string) (Event, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) < 7 || len(fields) > 8 || fields[0] != eventPrefix {
+		return Event{}, errors.New("unexpected fields count")
+	}
+
+	var (
+		e   Event
+		err error
+	)
+
+	e.Time, err = time.ParseInLocation(EventTimeLayout, fields[1]+" "+fields[2], time.Local)
+	if err != nil {
+		return Event{}, fmt.Errorf("parse time: %w", err)
+	}
+
+	ints := make([]int, 4)
+
+	for i := range ints {
+		ints[i], err = strconv.Atoi(fields[3+i])
+		if err != nil {
+			return Event{}, fmt.Errorf("parse field %d: %w", 3+i, err)
+		}
+	}
+
+	e.Type, e.AccessPointID, e.ObjectID = ints[0], ints[1], ints[2]
+
+	switch ints[3] {
+	case unknownDirection:
+	case inDirection:
+		e.Direction = entity.In
+	case outDirection:
+		e.Direction = entity.Out
+	default:
+		return Event{}, fmt.Errorf("unknown direction %d", ints[3])
+	}
+
+	if len(fields) == 8 {
+		e.Key = strings.ToUpper(fields[7])
+	}
+
+	return e, nil
+}
+
+// command sends command within the session and returns its response data
+// line.
+func (c *Client) command(cmd string) (string, error) {
+	c.commandMx.Lock()
+	defer c.commandMx.Unlock()
+
+	c.connMx.Lock()
+	conn := c.conn
+	c.connMx.Unlock()
+
+	if conn == nil {
+		return "", ErrNotConnected
+	}
+
+	// Drop response which came after the previous command timed out.
+	select {
+	case <-c.responses:
+	default:
+	}
+
+	err := conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return "", fmt.Errorf("set write deadline: %w", err)
+	}
+
+	_, err = conn.Write([]byte(cmd + "\r\n"))
+	if err != nil {
+		_ = conn.Close()
+		return "", fmt.Errorf("write: %w", err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case line := <-c.responses:
+		return parseResponse(line)
+	case <-timer.C:
+		_ = conn.Close()
+		return "", errors.New("response timeout")
+	}
+}
+
+// AllowPass opens access point in direction for anonymous person.
+func (c *Client) AllowPass(accessPointID int, d entity.Direction) error {
+	return expectOK(c.command(fmt.Sprintf("ALLOWPASS %d ANONYMOUS %s", accessPointID,
+		strings.ToUpper(string(d)))))
+}
+
+func (c *Client) APInfo(accessPointID int) (APInfo, error) {
+	line, err := c.command(fmt.Sprintf("GETAPINFO %d", accessPointID))
+	if err != nil {
+		return APInfo{}, err
+	}
+
+	return parseAPInfo(line)
+}
+
+// parseAPInfo parses APINFO ID <id> NAME "<name>" ... STATE <state> ... line,
+// unknown tokens are skipped.
+func parseAPInfo(line string) (APInfo, error) {
+	tokens, err := splitTokens(line)
+	if err != nil {
+		return APInfo{}, err
+	}
+
+	if len(tokens) == 0 || tokens[0] != apInfoResponse {
+		return APInfo{}, fmt.Errorf("unexpected response: %s", line)
+	}
+
+	var info APInfo
+
+	for i := 1; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "ID", "NAME", "STATE":
+		default:
+			continue
+		}
+
+		if i+1 == len(tokens) {
+			return APInfo{}, fmt.Errorf("no %s value", tokens[i])
+		}
+
+		value := tokens[i+1]
+
+		switch tokens[i] {
+		case "ID":
+			info.ID, err = strconv.Atoi(value)
+			if err != nil {
+				return APInfo{}, fmt.Errorf("parse ID: %w", err)
+			}
+		case "NAME":
+			info.Name = value
+		case "STATE":
+			info.State = value
+		}
+
+		i++
+	}
+
+	return info, nil
+}
+
+// splitTokens splits line by spaces, double quoted tokens may contain spaces.
+func splitTokens(line string) ([]string, error) {
+	var tokens []string
+
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			return tokens, nil
+		}
+
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted token")
+			}
+			tokens = append(tokens, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+		tokens = append(tokens, line[:end])
+		line = line[end:]
+	}
+}
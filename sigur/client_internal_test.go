@@ -0,0 +1,166 @@
+package sigur
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantData string
+		wantErr  error
+		fails    bool
+	}{
+		{line: "OK"},
+		{line: "OK ", wantData: ""},
+		{line: "APINFO ID 1", wantData: "APINFO ID 1"},
+		{line: "ERROR 3 Unknown access point", wantErr: ResponseError{Code: 3, Text: "Unknown access point"}},
+		{line: "ERROR 5", wantErr: ResponseError{Code: 5}},
+		{line: "", fails: true},
+		{line: "OK extra", fails: true},
+		{line: "ERROR", fails: true},
+		{line: "ERROR x Text", fails: true},
+	}
+
+	for _, tt := range tests {
+		data, err := parseResponse(tt.line)
+
+		switch {
+		case tt.wantErr != nil:
+			var re ResponseError
+			if !errors.As(err, &re) || re != tt.wantErr {
+				t.Errorf("%q: got error %v, want %v", tt.line, err, tt.wantErr)
+			}
+		case tt.fails:
+			if err == nil {
+				t.Errorf("%q: parsed as %q", tt.line, data)
+			}
+			if errors.As(err, &ResponseError{}) {
+				t.Errorf("%q: got response error %v", tt.line, err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.line, err)
+		case data != tt.wantData:
+			t.Errorf("%q: got data %q, want %q", tt.line, data, tt.wantData)
+		}
+	}
+}
+
+func TestExpectOK(t *testing.T) {
+	if err := expectOK("", nil); err != nil {
+		t.Errorf("OK response: %v", err)
+	}
+
+	if err := expectOK("APINFO ID 1", nil); err == nil {
+		t.Error("data response is taken for OK")
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 0, time.Local)
+
+	tests := []struct {
+		line  string
+		want  Event
+		fails bool
+	}{
+		{
+			line: "EVENT_CE 2021-03-04 05:06:07 2 1 10 1 00ab12",
+			want: Event{Time: tm, Type: KeyEvent, AccessPointID: 1, ObjectID: 10, Direction: entity.In, Key: "00AB12"},
+		},
+		{
+			line: "EVENT_CE 2021-03-04 05:06:07 1 3 0 2",
+			want: Event{Time: tm, Type: PassEvent, AccessPointID: 3, Direction: entity.Out},
+		},
+		{
+			line: "EVENT_CE 2021-03-04 05:06:07 1 3 0 0",
+			want: Event{Time: tm, Type: PassEvent, AccessPointID: 3},
+		},
+		{line: "EVENT_CE 2021-03-04 05:06:07 1 3 0 3", fails: true},
+		{line: "EVENT_CE 2021-03-04 05:06:07 1 3 0", fails: true},
+		{line: "EVENT_CE 2021-03-04 05:06:07 2 1 10 1 00ab12 extra", fails: true},
+		{line: "EVENT_XX 2021-03-04 05:06:07 1 3 0 0", fails: true},
+		{line: "EVENT_CE 2021-13-04 05:06:07 1 3 0 0", fails: true},
+		{line: "EVENT_CE 2021-03-04 05:06:07 1 x 0 0", fails: true},
+	}
+
+	for _, tt := range tests {
+		e, err := parseEvent(tt.line)
+
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%q: parsed as %+v", tt.line, e)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+
+		if !e.Time.Equal(tt.want.Time) {
+			t.Errorf("%q: got time %s, want %s", tt.line, e.Time, tt.want.Time)
+		}
+
+		e.Time = tt.want.Time
+
+		if e != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.line, e, tt.want)
+		}
+	}
+}
+
+func TestParseAPInfo(t *testing.T) {
+	tests := []struct {
+		line  string
+		want  APInfo
+		fails bool
+	}{
+		{
+			line: `APINFO ID 1 NAME "Main entrance" ZONEA 0 ZONEB 0 STATE ONLINE_NORMAL CLOSED`,
+			want: APInfo{ID: 1, Name: "Main entrance", State: "ONLINE_NORMAL"},
+		},
+		{
+			line: `APINFO STATE OFFLINE ID 2`,
+			want: APInfo{ID: 2, State: "OFFLINE"},
+		},
+		{
+			line: `APINFO ID 3 NAME ""`,
+			want: APInfo{ID: 3},
+		},
+		{line: `APINFO ID`, fails: true},
+		{line: `APINFO ID x`, fails: true},
+		{line: `APINFO ID 1 NAME "Main`, fails: true},
+		{line: `APOINFO ID 1`, fails: true},
+		{line: ``, fails: true},
+	}
+
+	for _, tt := range tests {
+		info, err := parseAPInfo(tt.line)
+
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%q: parsed as %+v", tt.line, info)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+
+		if info != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.line, info, tt.want)
+		}
+	}
+
+	if !(APInfo{State: "ONLINE_LOCKED"}).Online() || (APInfo{State: "OFFLINE"}).Online() {
+		t.Error("online state is misdetected")
+	}
+}
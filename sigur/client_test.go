@@ -0,0 +1,276 @@
+package sigur_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/sigur"
+	"github.com/bennyharvey/soma/sigur/sigurfake"
+)
+
+const (
+	testAccessPointID = 1
+	testTimeout       = 200 * time.Millisecond
+
+	testMinReconnectPeriod = 20 * time.Millisecond
+	testMaxReconnectPeriod = 80 * time.Millisecond
+)
+
+func TestMain(m *testing.M) {
+	sigur.SetReconnectPeriods(testMinReconnectPeriod, testMaxReconnectPeriod)
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *sigurfake.Server {
+	t.Helper()
+
+	s, err := sigurfake.NewServer("127.0.0.1:0", "user", "pass")
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	s.AddAccessPoint(testAccessPointID, "Main entrance")
+
+	return s
+}
+
+// waitTimeout is how long waitFor waits. It is generous for slow and loaded
+// machines, passing tests don't wait for it.
+const waitTimeout = 10 * time.Second
+
+// waitFor calls f until it returns true or waitTimeout passes.
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func allowPassSucceeds(c *sigur.Client) func() bool {
+	return func() bool {
+		return c.AllowPass(testAccessPointID, entity.In) == nil
+	}
+}
+
+func TestClientLoginFailureBackoff(t *testing.T) {
+	s := newTestServer(t)
+
+	c := sigur.NewClient(s.Addr(), "user", "wrong", testTimeout, false)
+	defer c.Close()
+
+	waitFor(t, "login attempts", func() bool { return len(s.Logins()) >= 5 })
+
+	err := c.AllowPass(testAccessPointID, entity.In)
+	if !errors.Is(err, sigur.ErrNotConnected) {
+		t.Fatalf("got error %v without session, want %v", err, sigur.ErrNotConnected)
+	}
+
+	logins := s.Logins()
+	period := testMinReconnectPeriod
+
+	for i := 1; i < 5; i++ {
+		gap := logins[i].Sub(logins[i-1])
+		if gap < period {
+			t.Errorf("got login attempt %d after %s, want at least %s", i, gap, period)
+		}
+
+		period *= 2
+		if period > testMaxReconnectPeriod {
+			period = testMaxReconnectPeriod
+		}
+	}
+
+	s.SetCredentials("user", "wrong")
+
+	waitFor(t, "session", allowPassSucceeds(c))
+}
+
+func TestClientReconnect(t *testing.T) {
+	s := newTestServer(t)
+
+	c := sigur.NewClient(s.Addr(), "user", "pass", testTimeout, false)
+	defer c.Close()
+
+	waitFor(t, "session", allowPassSucceeds(c))
+
+	s.DropConnections()
+
+	waitFor(t, "new session", func() bool { return len(s.Logins()) == 2 })
+	waitFor(t, "command in new session", allowPassSucceeds(c))
+
+	passes := s.Passes()
+	if len(passes) != 2 || passes[1] != (sigurfake.Pass{AccessPointID: testAccessPointID, Direction: entity.In}) {
+		t.Fatalf("got passes %v", passes)
+	}
+}
+
+func TestClientResponses(t *testing.T) {
+	s := newTestServer(t)
+
+	c := sigur.NewClient(s.Addr(), "user", "pass", testTimeout, false)
+	defer c.Close()
+
+	waitFor(t, "session", allowPassSucceeds(c))
+
+	err := c.AllowPass(testAccessPointID+1, entity.Out)
+
+	var re sigur.ResponseError
+	if !errors.As(err, &re) || re.Code != 3 || re.Text != "Unknown access point" {
+		t.Fatalf("got error %v, want unknown access point response error", err)
+	}
+
+	info, err := c.APInfo(testAccessPointID)
+	if err != nil {
+		t.Fatalf("get access point info: %v", err)
+	}
+
+	if info != (sigur.APInfo{ID: testAccessPointID, Name: "Main entrance", State: "ONLINE_NORMAL"}) {
+		t.Fatalf("got access point info %+v", info)
+	}
+
+	s.SetResponse("ALLOWPASS", "GARBAGE")
+
+	err = c.AllowPass(testAccessPointID, entity.In)
+	if err == nil || errors.As(err, &re) {
+		t.Fatalf("got error %v of garbage response", err)
+	}
+
+	s.SetResponse("GETAPINFO", "OK")
+
+	_, err = c.APInfo(testAccessPointID)
+	if err == nil {
+		t.Fatal("OK response is taken for access point info")
+	}
+}
+
+func TestClientCommandTimeout(t *testing.T) {
+	s := newTestServer(t)
+
+	c := sigur.NewClient(s.Addr(), "user", "pass", testTimeout, false)
+	defer c.Close()
+
+	waitFor(t, "session", allowPassSucceeds(c))
+
+	s.SetSilent(true)
+
+	err := c.AllowPass(testAccessPointID, entity.In)
+	if err == nil {
+		t.Fatal("command succeeded without response")
+	}
+
+	waitFor(t, "session close", func() bool { return len(s.Logins()) == 2 })
+
+	s.SetSilent(false)
+
+	waitFor(t, "command in new session", allowPassSucceeds(c))
+}
+
+func TestPassageOpenerPing(t *testing.T) {
+	s := newTestServer(t)
+
+	po := sigur.NewPassageOpener(sigur.NewClient(s.Addr(), "user", "pass", testTimeout, false),
+		testAccessPointID, entity.In)
+	defer po.Close()
+
+	waitFor(t, "ping", func() bool { return po.Ping() == nil })
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	if po.LastOpenTime().IsZero() {
+		t.Error("last open time is not set")
+	}
+
+	if po.CredentialReads() != nil || po.ControllerEvents() != nil {
+		t.Error("events are reported without subscription")
+	}
+
+	s.SetAccessPointState(testAccessPointID, "OFFLINE")
+
+	if po.Ping() == nil {
+		t.Fatal("offline access point is pinged")
+	}
+}
+
+func TestPassageOpenerEvents(t *testing.T) {
+	s := newTestServer(t)
+
+	po := sigur.NewPassageOpener(sigur.NewClient(s.Addr(), "user", "pass", testTimeout, true),
+		testAccessPointID, entity.In)
+	defer po.Close()
+
+	waitFor(t, "subscription", func() bool { return s.Subscribers() == 1 })
+
+	tm := time.Now().Truncate(time.Second)
+
+	s.Emit(sigur.Event{Time: tm, Type: sigur.KeyEvent, AccessPointID: testAccessPointID + 1,
+		Direction: entity.In, Key: "11"})
+	s.Emit(sigur.Event{Time: tm, Type: sigur.KeyEvent, AccessPointID: testAccessPointID,
+		Direction: entity.Out, Key: "22"})
+	s.Emit(sigur.Event{Time: tm, Type: sigur.KeyEvent, AccessPointID: testAccessPointID,
+		Direction: entity.In, Key: "00ab"})
+	s.Emit(sigur.Event{Time: tm, Type: sigur.PassEvent, AccessPointID: testAccessPointID})
+
+	for _, want := range []entity.ControllerEvent{
+		{Kind: entity.ControllerCardPresent, Direction: entity.In, Card: "00AB", Time: tm},
+		{Kind: entity.ControllerDoorOpen, Direction: entity.In, Time: tm},
+	} {
+		select {
+		case ce := <-po.ControllerEvents():
+			if ce.Kind != want.Kind || ce.Direction != want.Direction || ce.Card != want.Card ||
+				!ce.Time.Equal(want.Time) {
+				t.Fatalf("got controller event %+v, want %+v", ce, want)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("no controller event %s", want.Kind)
+		}
+
+		if want.Kind != entity.ControllerCardPresent {
+			continue
+		}
+
+		select {
+		case r := <-po.CredentialReads():
+			if r.Type != entity.Card || r.Value != want.Card || !r.Time.Equal(tm) {
+				t.Fatalf("got credential read %+v, want card %s", r, want.Card)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatal("no credential read")
+		}
+	}
+}
+
+func TestClientDropsUnreadEvents(t *testing.T) {
+	s := newTestServer(t)
+
+	c := sigur.NewClient(s.Addr(), "user", "pass", testTimeout, true)
+	defer c.Close()
+
+	waitFor(t, "subscription", func() bool { return s.Subscribers() == 1 })
+
+	for i := 0; i < 100; i++ {
+		s.Emit(sigur.Event{Time: time.Now(), Type: sigur.PassEvent, AccessPointID: testAccessPointID})
+	}
+
+	err := c.AllowPass(testAccessPointID, entity.In)
+	if err != nil {
+		t.Fatalf("command with unread events: %v", err)
+	}
+
+	if len(s.Logins()) != 1 {
+		t.Fatal("session is restarted")
+	}
+}
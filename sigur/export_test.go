@@ -0,0 +1,7 @@
+package sigur
+
+import "time"
+
+func SetReconnectPeriods(min, max time.Duration) {
+	minReconnectPeriod, maxReconnectPeriod = min, max
+}
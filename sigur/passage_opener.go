@@ -1,97 +1,223 @@
 package sigur
 
 import (
+	"errors"
 	"fmt"
-	"net"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
 )
 
-type PassageOpener struct {
-	Address   string
-	Direction entity.Direction
-	lastOpen  time.Time
-}
-
-func NewPassageOpener(address string, direction entity.Direction) *PassageOpener {
-	return &PassageOpener{
-		Address:   address,
-		Direction: direction,
-	}
+func init() {
+	passage.Register(entity.Sigur, newPassageOpener)
+	passage.RegisterCredentialsReporter(entity.Sigur, reportsCredentials)
 }
 
 const (
-	loginMsg   = "LOGIN 1.8 Administrator password\n"
-	openMsgFmt = "ALLOWPASS 1 ANONYMOUS %s\n"
-	exitMsg    = "EXIT\n"
+	defaultTimeout = 5 * time.Second
+
+	readsBufferSize = 16
 )
 
-func (po *PassageOpener) OpenPassage() error {
-	conn, err := net.Dial("tcp", po.Address)
-	if err != nil {
-		return fmt.Errorf("dial sigur controller: %w", err)
-	}
+type options struct {
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	AccessPointID   int    `yaml:"access_point_id"`
+	Timeout         string `yaml:"timeout"`
+	SubscribeEvents bool   `yaml:"subscribe_events"`
+}
 
-	defer func() {
-		err = conn.Close()
-		if err != nil {
-			logrus.WithField("subsystem", "sigur_passage_opener").
-				WithError(err).Error("failed to close connection")
-		}
-	}()
+// reportsCredentials returns whether presented keys are received.
+func reportsCredentials(c passage.Config) bool {
+	var o options
+	return c.DecodeOptions(&o) == nil && o.SubscribeEvents
+}
 
-	buf := make([]byte, 1024)
+func newPassageOpener(c passage.Config) (passage.Opener, error) {
+	var o options
 
-	err = conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	err := c.DecodeOptions(&o)
 	if err != nil {
-		return fmt.Errorf("set connection deadline: %w", err)
+		return nil, err
 	}
 
-	_, err = conn.Write([]byte(loginMsg))
-	if err != nil {
-		return fmt.Errorf("write login message: %w", err)
+	if o.Username == "" {
+		return nil, errors.New("no username")
 	}
 
-	n, err := conn.Read(buf)
-	if err != nil {
-		return fmt.Errorf("read login response: %w", err)
+	if o.AccessPointID <= 0 {
+		return nil, errors.New("access_point_id must be positive")
 	}
 
-	if n != 4 && string(buf[:4]) != "OK\r\n" {
-		return fmt.Errorf("unexpected login reponse: %s",
-			string(buf[:n]))
-	}
+	timeout := defaultTimeout
 
-	_, err = conn.Write([]byte(fmt.Sprintf(openMsgFmt,
-		strings.ToUpper(string(po.Direction)))))
-	if err != nil {
-		return fmt.Errorf("write open message: %w", err)
+	if o.Timeout != "" {
+		timeout, err = time.ParseDuration(o.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout parse: %w", err)
+		}
 	}
 
-	n, err = conn.Read(buf)
-	if err != nil {
-		return fmt.Errorf("read open response: %w", err)
+	return NewPassageOpener(NewClient(c.Address, o.Username, o.Password, timeout, o.SubscribeEvents),
+		o.AccessPointID, c.Direction), nil
+}
+
+// PassageOpener opens Sigur access point in its direction. If client is
+// subscribed to events, events of the access point in opener direction are
+// reported as controller events and keys presented as card reads.
+type PassageOpener struct {
+	client        *Client
+	accessPointID int
+	direction     entity.Direction
+
+	lastOpen   time.Time
+	lastOpenMx sync.Mutex
+
+	credentialReads  chan entity.CredentialRead
+	controllerEvents chan entity.ControllerEvent
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewPassageOpener(c *Client, accessPointID int, direction entity.Direction) *PassageOpener {
+	po := &PassageOpener{
+		client:        c,
+		accessPointID: accessPointID,
+		direction:     direction,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":       "sigur_passage_opener",
+			"access_point_id": accessPointID,
+		}),
+		stop: make(chan struct{}),
 	}
 
-	if n != 4 && string(buf[:4]) != "OK\r\n" {
-		return fmt.Errorf("unexpected open reponse: %s",
-			string(buf[:n]))
+	if c.Events() != nil {
+		po.credentialReads = make(chan entity.CredentialRead, readsBufferSize)
+		po.controllerEvents = make(chan entity.ControllerEvent, readsBufferSize)
+
+		po.wg.Add(1)
+		go po.readEvents()
 	}
 
-	_, err = conn.Write([]byte(exitMsg))
+	return po
+}
+
+// Close closes client session and stops events reading.
+func (po *PassageOpener) Close() error {
+	close(po.stop)
+	err := po.client.Close()
+	po.wg.Wait()
+	return err
+}
+
+func (po *PassageOpener) OpenPassage() error {
+	err := po.client.AllowPass(po.accessPointID, po.direction)
 	if err != nil {
-		return fmt.Errorf("write exit message: %w", err)
+		return err
 	}
 
+	po.lastOpenMx.Lock()
 	po.lastOpen = time.Now()
+	po.lastOpenMx.Unlock()
 
 	return nil
 }
 
 func (po *PassageOpener) LastOpenTime() time.Time {
+	po.lastOpenMx.Lock()
+	defer po.lastOpenMx.Unlock()
 	return po.lastOpen
 }
+
+// Ping checks that session is alive and access point is online.
+func (po *PassageOpener) Ping() error {
+	info, err := po.client.APInfo(po.accessPointID)
+	if err != nil {
+		return err
+	}
+
+	if !info.Online() {
+		return fmt.Errorf("access point state is %s", info.State)
+	}
+
+	return nil
+}
+
+// CredentialReads returns presented keys, it is nil without events
+// subscription.
+func (po *PassageOpener) CredentialReads() <-chan entity.CredentialRead {
+	if po.credentialReads == nil {
+		return nil
+	}
+	return po.credentialReads
+}
+
+// ControllerEvents returns door openings and presented keys, it is nil
+// without events subscription.
+func (po *PassageOpener) ControllerEvents() <-chan entity.ControllerEvent {
+	if po.controllerEvents == nil {
+		return nil
+	}
+	return po.controllerEvents
+}
+
+func (po *PassageOpener) readEvents() {
+	defer po.wg.Done()
+	defer close(po.credentialReads)
+	defer close(po.controllerEvents)
+
+	for e := range po.client.Events() {
+		if e.AccessPointID != po.accessPointID {
+			continue
+		}
+
+		if e.Direction != "" && e.Direction != po.direction {
+			continue
+		}
+
+		ce := entity.ControllerEvent{
+			Direction: po.direction,
+			Time:      e.Time,
+		}
+
+		switch e.Type {
+		case PassEvent:
+			ce.Kind = entity.ControllerDoorOpen
+		case KeyEvent:
+			if e.Key == "" {
+				po.log.Warn("key event without key, skipping")
+				continue
+			}
+			ce.Kind = entity.ControllerCardPresent
+			ce.Card = e.Key
+		default:
+			continue
+		}
+
+		select {
+		case po.controllerEvents <- ce:
+		case <-po.stop:
+			return
+		}
+
+		if ce.Kind != entity.ControllerCardPresent {
+			continue
+		}
+
+		select {
+		case po.credentialReads <- entity.CredentialRead{
+			Type:  entity.Card,
+			Value: ce.Card,
+			Time:  ce.Time,
+		}:
+		case <-po.stop:
+			return
+		}
+	}
+}
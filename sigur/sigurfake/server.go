@@ -0,0 +1,369 @@
+// Package sigurfake implements fake Sigur server speaking OIF protocol subset
+// of sigur package. It is used to try sigur client without real server.
+package sigurfake
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/sigur"
+)
+
+// Pass is ALLOWPASS command received by server.
+type Pass struct {
+	AccessPointID int
+	Direction     entity.Direction
+}
+
+type conn struct {
+	net.Conn
+	subscribed bool
+	writeMx    sync.Mutex
+}
+
+func (c *conn) writeLine(line string) error {
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+
+	_, err := c.Write([]byte(line + "\r\n"))
+	return err
+}
+
+type accessPoint struct {
+	name  string
+	state string
+}
+
+// Server is fake Sigur server. Access points not added with AddAccessPoint
+// are unknown.
+type Server struct {
+	username string
+	password string
+
+	listener     net.Listener
+	accessPoints map[int]accessPoint
+	passes       []Pass
+	logins       []time.Time
+	responses    map[string]string
+	silent       bool
+	conns        map[*conn]struct{}
+	closed       bool
+	mx           sync.Mutex
+
+	log *logrus.Entry
+	wg  sync.WaitGroup
+}
+
+// NewServer creates server listening on address, like 127.0.0.1:0, and starts
+// serving.
+func NewServer(address, username, password string) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := &Server{
+		username:     username,
+		password:     password,
+		listener:     l,
+		accessPoints: map[int]accessPoint{},
+		responses:    map[string]string{},
+		conns:        map[*conn]struct{}{},
+		log:          logrus.WithField("subsystem", "sigur_fake_server"),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns address server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops listening and closes connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mx.Lock()
+	s.closed = true
+	s.mx.Unlock()
+
+	s.DropConnections()
+	s.wg.Wait()
+
+	return err
+}
+
+// DropConnections closes client connections, clients are expected to
+// reconnect.
+func (s *Server) DropConnections() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// AddAccessPoint adds online access point.
+func (s *Server) AddAccessPoint(accessPointID int, name string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.accessPoints[accessPointID] = accessPoint{name: name, state: "ONLINE_NORMAL"}
+}
+
+// SetAccessPointState sets state of added access point, like OFFLINE.
+func (s *Server) SetAccessPointState(accessPointID int, state string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	ap := s.accessPoints[accessPointID]
+	ap.state = state
+	s.accessPoints[accessPointID] = ap
+}
+
+// SetCredentials changes credentials accepted by LOGIN command.
+func (s *Server) SetCredentials(username, password string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.username, s.password = username, password
+}
+
+// SetResponse makes server respond to command with line, whatever its
+// arguments are.
+func (s *Server) SetResponse(command, line string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.responses[command] = line
+}
+
+// SetSilent makes server not respond to commands after login.
+func (s *Server) SetSilent(silent bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.silent = silent
+}
+
+// Logins returns times of received LOGIN commands.
+func (s *Server) Logins() []time.Time {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]time.Time{}, s.logins...)
+}
+
+// Connections returns count of client connections.
+func (s *Server) Connections() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.conns)
+}
+
+// Passes returns received ALLOWPASS commands.
+func (s *Server) Passes() []Pass {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]Pass{}, s.passes...)
+}
+
+// Subscribers returns count of connections subscribed to events.
+func (s *Server) Subscribers() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	n := 0
+	for c := range s.conns {
+		if c.subscribed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Emit sends event to subscribed connections.
+func (s *Server) Emit(e sigur.Event) {
+	direction := 0
+	switch e.Direction {
+	case entity.In:
+		direction = 1
+	case entity.Out:
+		direction = 2
+	}
+
+	line := fmt.Sprintf("EVENT_CE %s %d %d %d %d", e.Time.Format(sigur.EventTimeLayout), e.Type,
+		e.AccessPointID, e.ObjectID, direction)
+	if e.Key != "" {
+		line += " " + e.Key
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for c := range s.conns {
+		if !c.subscribed {
+			continue
+		}
+		err := c.writeLine(line)
+		if err != nil {
+			s.log.WithError(err).Warn("failed to write event")
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &conn{Conn: nc}
+
+		s.mx.Lock()
+		if s.closed {
+			s.mx.Unlock()
+			_ = nc.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mx.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *conn) {
+	defer s.wg.Done()
+
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, c)
+		s.mx.Unlock()
+
+		_ = c.Close()
+	}()
+
+	r := bufio.NewScanner(c)
+	loggedIn := false
+
+	for r.Scan() {
+		fields := strings.Fields(r.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var resp string
+
+		switch {
+		case fields[0] == "EXIT":
+			return
+
+		case fields[0] == "LOGIN":
+			loggedIn = s.login(fields)
+			if !loggedIn {
+				resp = "ERROR 6 Invalid credentials"
+				break
+			}
+			resp = "OK"
+
+		case !loggedIn:
+			resp = "ERROR 5 Not logged in"
+
+		default:
+			var ok bool
+			resp, ok = s.command(c, fields)
+			if !ok {
+				continue
+			}
+		}
+
+		err := c.writeLine(resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) login(fields []string) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.logins = append(s.logins, time.Now())
+
+	return len(fields) == 4 && fields[2] == s.username && fields[3] == s.password
+}
+
+// command returns response to command and whether server responds.
+func (s *Server) command(c *conn, fields []string) (string, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.silent {
+		return "", false
+	}
+
+	if resp, exists := s.responses[fields[0]]; exists {
+		return resp, true
+	}
+
+	return s.execute(c, fields), true
+}
+
+func (s *Server) execute(c *conn, fields []string) string {
+	switch fields[0] {
+	case "SUBSCRIBE":
+		if len(fields) != 2 || fields[1] != "CE" {
+			return "ERROR 2 Invalid subscription"
+		}
+		c.subscribed = true
+		return "OK"
+
+	case "ALLOWPASS":
+		if len(fields) != 4 || fields[2] != "ANONYMOUS" {
+			return "ERROR 2 Invalid arguments"
+		}
+		apID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "ERROR 2 Invalid access point ID"
+		}
+		if _, exists := s.accessPoints[apID]; !exists {
+			return "ERROR 3 Unknown access point"
+		}
+		d := entity.Direction(strings.ToLower(fields[3]))
+		if d != entity.In && d != entity.Out {
+			return "ERROR 2 Invalid direction"
+		}
+		s.passes = append(s.passes, Pass{AccessPointID: apID, Direction: d})
+		return "OK"
+
+	case "GETAPINFO":
+		if len(fields) != 2 {
+			return "ERROR 2 Invalid arguments"
+		}
+		apID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "ERROR 2 Invalid access point ID"
+		}
+		ap, exists := s.accessPoints[apID]
+		if !exists {
+			return "ERROR 3 Unknown access point"
+		}
+		return fmt.Sprintf("APINFO ID %d NAME %q ZONEA 0 ZONEB 0 STATE %s CLOSED", apID, ap.name, ap.state)
+	}
+
+	return "ERROR 1 Unknown command"
+}
@@ -0,0 +1,86 @@
+package skuder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// checkAccess checks person's access groups against passage and local time
+// and records access denied event on failure if record is set. It returns
+// false if passage must not be opened.
+func (rfh *RecognizedFaceHandler) checkAccess(log *logrus.Entry, p entity.Person, photoID string,
+	record bool) bool {
+
+	if !rfh.accessControl {
+		return true
+	}
+
+	now := time.Now()
+
+	ags, err := rfh.dbStorage.PersonAccessGroups(p.ID)
+	if err != nil {
+		log.WithError(err).Error("failed to get person access groups from DB storage")
+		return false
+	}
+
+	var holiday string
+
+	h, err := rfh.dbStorage.Holiday(now.Format("2006-01-02"))
+	if err == nil {
+		holiday = h.Name
+	} else if err != entity.ErrHolidayNotFound {
+		log.WithError(err).Error("failed to get holiday from DB storage")
+		return false
+	}
+
+	reason, agIDs := entity.CheckAccess(ags, rfh.passageID, now, err == nil)
+	if reason == "" {
+		return true
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"person_id":        p.ID,
+		"access_reason":    reason,
+		"access_group_ids": agIDs,
+		"holiday":          holiday,
+	})
+
+	if !record {
+		log.Debug("access denied, passage not opened")
+		return false
+	}
+
+	data, err := json.Marshal(entity.AccessDeniedData{
+		PhotoID:        photoID,
+		PersonID:       p.ID,
+		PersonName:     p.Name,
+		PersonPosition: p.Position,
+		PersonUnit:     p.Unit,
+		PassageID:      rfh.passageID,
+		Reason:         reason,
+		AccessGroupIDs: agIDs,
+		Holiday:        holiday,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal access denied data")
+		return false
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      now,
+		PassageID: rfh.passageID,
+		Type:      entity.AccessDenied,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add access denied event to DB storage")
+	}
+
+	log.Warn("access denied, passage not opened")
+
+	return false
+}
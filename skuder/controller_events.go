@@ -0,0 +1,79 @@
+package skuder
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// ControllerEventReader is implemented by passage openers which report events
+// of their controllers. Channel is closed when reader is closed, nil channel
+// means controller doesn't report events.
+type ControllerEventReader interface {
+	ControllerEvents() <-chan entity.ControllerEvent
+}
+
+func (rfh *RecognizedFaceHandler) readControllerEvents(cer ControllerEventReader) {
+	defer rfh.wg.Done()
+
+	for {
+		select {
+		case <-rfh.stop:
+			return
+		case e, ok := <-cer.ControllerEvents():
+			if !ok {
+				return
+			}
+			rfh.HandleControllerEvent(e)
+		}
+	}
+}
+
+// HandleControllerEvent records controller event. Person is resolved by
+// presented card if it is person's credential.
+func (rfh *RecognizedFaceHandler) HandleControllerEvent(e entity.ControllerEvent) {
+	log := rfh.log.WithFields(logrus.Fields{
+		"controller_event": e.Kind,
+		"event_time":       e.Time,
+	})
+
+	cd := entity.ControllerData{
+		PassageID: rfh.passageID,
+		Kind:      e.Kind,
+		Direction: e.Direction,
+		Card:      e.Card,
+	}
+
+	if e.Card != "" {
+		c, err := rfh.dbStorage.Credential(entity.Card, e.Card)
+		switch {
+		case err == nil:
+			p, err := rfh.dbStorage.Person(c.PersonID)
+			if err != nil {
+				log.WithError(err).WithField("person_id", c.PersonID).Error("failed to get person from DB storage")
+			} else {
+				cd.PersonID, cd.PersonName = p.ID, p.Name
+			}
+		case err != entity.ErrCredentialNotFound:
+			log.WithError(err).Error("failed to get credential from DB storage")
+		}
+	}
+
+	data, err := json.Marshal(cd)
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal controller data")
+		return
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      e.Time,
+		PassageID: rfh.passageID,
+		Type:      entity.Controller,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add controller event to DB storage")
+	}
+}
@@ -0,0 +1,151 @@
+package skuder
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// CredentialReader is implemented by passage openers which report card reads
+// and PIN entries of their controllers. Channel is closed when reader is
+// closed, nil channel means controller doesn't report credentials.
+type CredentialReader interface {
+	CredentialReads() <-chan entity.CredentialRead
+}
+
+// pendingFactor is face match or credential read of person waiting for the
+// other factor in face_and_card mode. Face match remembers whether person is
+// a visitor.
+type pendingFactor struct {
+	time       time.Time
+	credential entity.CredentialType
+	visitor    bool
+}
+
+func (rfh *RecognizedFaceHandler) readCredentials(cr CredentialReader) {
+	defer rfh.wg.Done()
+
+	for {
+		select {
+		case <-rfh.stop:
+			return
+		case r, ok := <-cr.CredentialReads():
+			if !ok {
+				return
+			}
+			rfh.HandleCredentialRead(r)
+		}
+	}
+}
+
+// pairFace returns credential of person read within second factor window and
+// forgets it. Otherwise face match is remembered waiting for credential.
+func (rfh *RecognizedFaceHandler) pairFace(personID int64, visitor bool, t time.Time) (entity.CredentialType, bool) {
+	rfh.pendingMx.Lock()
+	defer rfh.pendingMx.Unlock()
+
+	rfh.expirePending(t)
+
+	if pc, exists := rfh.pendingCredentials[personID]; exists {
+		delete(rfh.pendingCredentials, personID)
+		return pc.credential, true
+	}
+
+	rfh.pendingFaces[personID] = pendingFactor{time: t, visitor: visitor}
+
+	return "", false
+}
+
+// pairCredential is pairFace counterpart for credential reads, it returns
+// pending face match.
+func (rfh *RecognizedFaceHandler) pairCredential(personID int64, ct entity.CredentialType,
+	t time.Time) (pendingFactor, bool) {
+
+	rfh.pendingMx.Lock()
+	defer rfh.pendingMx.Unlock()
+
+	rfh.expirePending(t)
+
+	if pf, exists := rfh.pendingFaces[personID]; exists {
+		delete(rfh.pendingFaces, personID)
+		return pf, true
+	}
+
+	rfh.pendingCredentials[personID] = pendingFactor{time: t, credential: ct}
+
+	return pendingFactor{}, false
+}
+
+func (rfh *RecognizedFaceHandler) expirePending(t time.Time) {
+	since := t.Add(-rfh.secondFactorWindow)
+
+	for personID, pf := range rfh.pendingFaces {
+		if pf.time.Before(since) {
+			delete(rfh.pendingFaces, personID)
+		}
+	}
+
+	for personID, pc := range rfh.pendingCredentials {
+		if pc.time.Before(since) {
+			delete(rfh.pendingCredentials, personID)
+		}
+	}
+}
+
+// HandleCredentialRead handles card read or PIN entry at the passage. In
+// face_or_card mode credential alone opens passage, in face_and_card mode it
+// must be paired with face match of the same person. Credentials are ignored
+// in face mode.
+func (rfh *RecognizedFaceHandler) HandleCredentialRead(r entity.CredentialRead) {
+	if rfh.mode != entity.FaceOrCardMode && rfh.mode != entity.FaceAndCardMode {
+		return
+	}
+
+	log := rfh.log.WithFields(logrus.Fields{
+		"credential_type": r.Type,
+		"read_time":       r.Time,
+	})
+
+	c, err := rfh.dbStorage.Credential(r.Type, r.Value)
+	if err != nil {
+		if err == entity.ErrCredentialNotFound {
+			log.Info("unknown credential, skipping")
+		} else {
+			log.WithError(err).Error("failed to get credential from DB storage")
+		}
+		return
+	}
+
+	log = log.WithField("person_id", c.PersonID)
+
+	p, err := rfh.dbStorage.Person(c.PersonID)
+	if err != nil {
+		log.WithError(err).Error("failed to get person from DB storage")
+		return
+	}
+
+	if p.Watchlist != "" {
+		log.WithField("watchlist", p.Watchlist).Warn("credential of watchlisted person, passage not opened")
+		return
+	}
+
+	now := time.Now()
+
+	if rfh.mode == entity.FaceAndCardMode {
+		face, paired := rfh.pairCredential(p.ID, r.Type, now)
+		if !paired {
+			log.Info("credential read, waiting for face match")
+			return
+		}
+		if rfh.openPassage(log, p, r.Type, face.visitor) {
+			rfh.setVisitOpened(p.ID)
+		}
+		return
+	}
+
+	if visitor, allowed := rfh.mayPass(log, p, "", true); allowed {
+		rfh.openPassage(log, p, r.Type, visitor)
+	}
+}
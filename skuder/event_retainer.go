@@ -0,0 +1,207 @@
+package skuder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+type EventPartitionsStorage interface {
+	EnsureEventPartitions(ahead int) error
+}
+
+// EventPartitioner creates monthly event partitions in advance, so that events
+// don't pile up in default partition. It works whether retention is enabled
+// or not.
+type EventPartitioner struct {
+	ahead   int
+	storage EventPartitionsStorage
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewEventPartitioner(ahead int, checkPeriod time.Duration, s EventPartitionsStorage) *EventPartitioner {
+	ep := &EventPartitioner{
+		ahead:   ahead,
+		storage: s,
+		log:     logrus.WithField("subsystem", "skuder_event_partitioner"),
+		stop:    make(chan struct{}),
+	}
+
+	ep.wg.Add(1)
+	go func() {
+		defer ep.wg.Done()
+
+		ticker := time.NewTicker(checkPeriod)
+		defer ticker.Stop()
+
+		for {
+			err := ep.storage.EnsureEventPartitions(ep.ahead)
+			if err != nil {
+				ep.log.WithError(err).Error("failed to ensure event partitions")
+			}
+
+			select {
+			case <-ep.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return ep
+}
+
+func (ep *EventPartitioner) Stop() {
+	close(ep.stop)
+	ep.wg.Wait()
+}
+
+type EventsRetentionStorage interface {
+	EventPartitions() ([]entity.EventPartition, error)
+	DropEventPartition(name string) error
+	ArchiveEventPartition(name string) error
+	DeleteEvents(et entity.EventType, before time.Time) (int64, error)
+	DeleteOtherEvents(ets []entity.EventType, before time.Time) (int64, error)
+}
+
+// RetentionPolicy defines how long events are kept. MaxAges are per event
+// type, DefaultMaxAge is for types not listed there, zero DefaultMaxAge keeps
+// them forever. Monthly partitions are dropped (or archived) once all of
+// their events are expired, shorter living events are deleted row by row.
+type RetentionPolicy struct {
+	MaxAges       map[entity.EventType]time.Duration
+	DefaultMaxAge time.Duration
+	Archive       bool
+	CheckPeriod   time.Duration
+}
+
+type EventRetainer struct {
+	policy  RetentionPolicy
+	storage EventsRetentionStorage
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewEventRetainer(p RetentionPolicy, s EventsRetentionStorage) *EventRetainer {
+	er := &EventRetainer{
+		policy:  p,
+		storage: s,
+		log:     logrus.WithField("subsystem", "skuder_event_retainer"),
+		stop:    make(chan struct{}),
+	}
+
+	er.wg.Add(1)
+	go func() {
+		defer er.wg.Done()
+
+		ticker := time.NewTicker(p.CheckPeriod)
+		defer ticker.Stop()
+
+		for {
+			er.retain()
+
+			select {
+			case <-er.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return er
+}
+
+func (er *EventRetainer) Stop() {
+	close(er.stop)
+	er.wg.Wait()
+}
+
+// partitionMaxAge returns age after which whole partitions are expired or
+// zero if they never are.
+func (er *EventRetainer) partitionMaxAge() time.Duration {
+	if er.policy.DefaultMaxAge == 0 {
+		return 0
+	}
+
+	maxAge := er.policy.DefaultMaxAge
+	for _, age := range er.policy.MaxAges {
+		if age > maxAge {
+			maxAge = age
+		}
+	}
+
+	return maxAge
+}
+
+func (er *EventRetainer) retain() {
+	now := time.Now()
+
+	partitionMaxAge := er.partitionMaxAge()
+
+	if partitionMaxAge > 0 {
+		eps, err := er.storage.EventPartitions()
+		if err != nil {
+			er.log.WithError(err).Error("failed to get event partitions")
+		}
+
+		for _, ep := range eps {
+			if ep.To.After(now.Add(-partitionMaxAge)) {
+				continue
+			}
+
+			log := er.log.WithField("partition", ep.Name)
+
+			if er.policy.Archive {
+				err = er.storage.ArchiveEventPartition(ep.Name)
+			} else {
+				err = er.storage.DropEventPartition(ep.Name)
+			}
+			if err != nil {
+				log.WithError(err).Error("failed to retain event partition")
+				continue
+			}
+
+			log.WithField("archived", er.policy.Archive).Info("event partition retained")
+		}
+	}
+
+	var types []entity.EventType
+
+	for et, age := range er.policy.MaxAges {
+		types = append(types, et)
+
+		if age == partitionMaxAge {
+			continue
+		}
+
+		n, err := er.storage.DeleteEvents(et, now.Add(-age))
+		if err != nil {
+			er.log.WithError(err).WithField("event_type", et).Error("failed to delete expired events")
+			continue
+		}
+
+		if n > 0 {
+			er.log.WithFields(logrus.Fields{
+				"event_type": et,
+				"deleted":    n,
+			}).Info("expired events deleted")
+		}
+	}
+
+	if er.policy.DefaultMaxAge > 0 && er.policy.DefaultMaxAge < partitionMaxAge {
+		n, err := er.storage.DeleteOtherEvents(types, now.Add(-er.policy.DefaultMaxAge))
+		if err != nil {
+			er.log.WithError(err).Error("failed to delete expired events of other types")
+		} else if n > 0 {
+			er.log.WithField("deleted", n).Info("expired events of other types deleted")
+		}
+	}
+}
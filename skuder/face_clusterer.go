@@ -0,0 +1,186 @@
+package skuder
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+type FaceClustersStorage interface {
+	EachEvent(fn func(entity.Event) error, fs ...entity.EventsFilter) error
+	FaceClusters(minCount int) ([]entity.FaceCluster, error)
+	FaceClusteringCursor() (entity.EventsCursor, error)
+	SetFaceClusteringCursor(entity.EventsCursor) error
+	AddFaceClusterFace(entity.FaceCluster, entity.FaceClusterFace) (entity.FaceCluster, error)
+}
+
+// FaceClusterer periodically puts unknown faces of face_recognize events to
+// clusters of similar faces, so frequent unknown visitors can be enrolled.
+// Face joins the cluster with the closest descriptor if it is closer than
+// distance, otherwise it starts a new cluster. At most batchSize events are
+// clustered per check.
+type FaceClusterer struct {
+	distance  float64
+	batchSize int
+	storage   FaceClustersStorage
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewFaceClusterer(distance float64, batchSize int, checkPeriod time.Duration,
+	s FaceClustersStorage) *FaceClusterer {
+
+	fc := &FaceClusterer{
+		distance:  distance,
+		batchSize: batchSize,
+		storage:   s,
+		log:       logrus.WithField("subsystem", "skuder_face_clusterer"),
+		stop:      make(chan struct{}),
+	}
+
+	fc.wg.Add(1)
+	go func() {
+		defer fc.wg.Done()
+
+		ticker := time.NewTicker(checkPeriod)
+		defer ticker.Stop()
+
+		for {
+			fc.cluster()
+
+			select {
+			case <-fc.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return fc
+}
+
+func (fc *FaceClusterer) Stop() {
+	close(fc.stop)
+	fc.wg.Wait()
+}
+
+func (fc *FaceClusterer) cluster() {
+	cursor, err := fc.storage.FaceClusteringCursor()
+	if err != nil {
+		fc.log.WithError(err).Error("failed to get face clustering cursor")
+		return
+	}
+
+	var es []entity.Event
+
+	err = fc.storage.EachEvent(func(e entity.Event) error {
+		es = append(es, e)
+		return nil
+	}, entity.EventsType(entity.FaceRecognize), entity.EventsOrderBy("time"),
+		entity.EventsOrderDirection("asc"), entity.EventsAfter(cursor), entity.EventsLimit(fc.batchSize))
+	if err != nil {
+		fc.log.WithError(err).Error("failed to get face recognize events")
+		return
+	}
+
+	if len(es) == 0 {
+		return
+	}
+
+	fcs, err := fc.storage.FaceClusters(1)
+	if err != nil {
+		fc.log.WithError(err).Error("failed to get face clusters")
+		return
+	}
+
+	var clustered int
+
+	for _, e := range es {
+		log := fc.log.WithField("event_id", e.ID)
+
+		var data entity.FaceRecognizedData
+
+		err = json.Unmarshal(e.Data, &data)
+		if err != nil {
+			log.WithError(err).Warn("failed to JSON unmarshal face recognized data, skipping")
+
+			err = fc.storage.SetFaceClusteringCursor(entity.EventsCursor{Time: e.Time, ID: e.ID})
+			if err != nil {
+				log.WithError(err).Error("failed to set face clustering cursor")
+				return
+			}
+
+			continue
+		}
+
+		var (
+			i int
+			c entity.FaceCluster
+		)
+
+		for {
+			i = closestFaceCluster(fcs, data.FaceDescriptor, fc.distance)
+			if i < 0 {
+				i = len(fcs)
+				fcs = append(fcs, entity.FaceCluster{})
+			}
+
+			c = fcs[i]
+
+			distance := c.AddFace(data.FaceDescriptor, e.Time)
+
+			c, err = fc.storage.AddFaceClusterFace(c, entity.FaceClusterFace{
+				EventID:    e.ID,
+				Time:       e.Time,
+				Descriptor: data.FaceDescriptor,
+				PhotoID:    data.PhotoID,
+				Distance:   distance,
+			})
+			if err != entity.ErrFaceClusterNotFound {
+				break
+			}
+
+			// Cluster is enrolled or removed by admin meanwhile, so it is
+			// forgotten and face is clustered again.
+			log.WithField("face_cluster_id", c.ID).Info("face cluster is gone, clustering face again")
+			fcs = append(fcs[:i], fcs[i+1:]...)
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to add face cluster face")
+			if fcs[i].ID == 0 {
+				fcs = fcs[:i]
+			}
+			return
+		}
+
+		fcs[i] = c
+		clustered++
+	}
+
+	fc.log.WithFields(logrus.Fields{
+		"clustered": clustered,
+		"clusters":  len(fcs),
+	}).Info("unknown faces clustered")
+}
+
+// closestFaceCluster returns index of cluster with descriptor closest to fd
+// and closer than maxDistance, or -1 if there is no such one.
+func closestFaceCluster(fcs []entity.FaceCluster, fd entity.FaceDescriptor, maxDistance float64) int {
+	closest := -1
+
+	for i, c := range fcs {
+		d := entity.FaceDescriptorDistance(c.Descriptor, fd)
+		if d < maxDistance {
+			closest = i
+			maxDistance = d
+		}
+	}
+
+	return closest
+}
@@ -0,0 +1,42 @@
+package skuder
+
+import (
+	"sync"
+	"time"
+)
+
+// personLimiter limits how often the same thing is recorded for person, since
+// person stays in front of camera for many frames.
+type personLimiter struct {
+	period time.Duration
+	times  map[int64]time.Time
+	mx     sync.Mutex
+}
+
+func newPersonLimiter(period time.Duration) *personLimiter {
+	return &personLimiter{
+		period: period,
+		times:  map[int64]time.Time{},
+	}
+}
+
+// recorded returns whether it was recorded for person within period,
+// otherwise remembers it as recorded at t.
+func (pl *personLimiter) recorded(personID int64, t time.Time) bool {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+
+	for id, rt := range pl.times {
+		if t.Sub(rt) >= pl.period {
+			delete(pl.times, id)
+		}
+	}
+
+	if _, exists := pl.times[personID]; exists {
+		return true
+	}
+
+	pl.times[personID] = t
+
+	return false
+}
@@ -0,0 +1,71 @@
+package skuder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// livenessFailEventPeriod is how often liveness fail of the same person is
+// recorded, spoofing attempt lasts many frames.
+const livenessFailEventPeriod = 10 * time.Second
+
+// checkLiveness returns true if face is live enough to open passage. Face
+// without liveness score fails the check when threshold is set, since facer
+// is either not configured to check liveness or failed to check it. Fails are
+// recorded once per livenessFailEventPeriod for person.
+func (rfh *RecognizedFaceHandler) checkLiveness(log *logrus.Entry, rf entity.RecognizedFace, personID int64,
+	photoID string, distance float64) bool {
+
+	if rfh.livenessThreshold <= 0 {
+		return true
+	}
+
+	if rf.Liveness != nil && *rf.Liveness >= rfh.livenessThreshold {
+		return true
+	}
+
+	log = log.WithField("person_id", personID)
+
+	if rf.Liveness != nil {
+		log = log.WithField("liveness", *rf.Liveness)
+	}
+
+	now := time.Now()
+
+	if rfh.livenessFails.recorded(personID, now) {
+		log.Debug("face is not live enough, passage not opened")
+		return false
+	}
+
+	data, err := json.Marshal(entity.LivenessFailData{
+		PhotoID:             photoID,
+		PersonID:            personID,
+		PassageID:           rfh.passageID,
+		Liveness:            rf.Liveness,
+		LivenessThreshold:   rfh.livenessThreshold,
+		DetectConfidence:    rf.DetectConfidence,
+		DescriptorsDistance: distance,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal liveness fail data")
+		return false
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      now,
+		PassageID: rfh.passageID,
+		Type:      entity.LivenessFail,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add liveness fail event to DB storage")
+	}
+
+	log.Warn("face is not live enough, passage not opened")
+
+	return false
+}
@@ -0,0 +1,112 @@
+package skuder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// checkPassback checks whether person's last passage in the zone allows to
+// pass in handler's direction and records violations if record is set. It
+// returns false if passage must not be opened. Person without recorded state
+// is allowed to pass in any direction, so that entering before anti-passback
+// enabling or state reset doesn't lock anyone.
+func (rfh *RecognizedFaceHandler) checkPassback(log *logrus.Entry, p entity.Person, photoID string,
+	record bool) bool {
+
+	if rfh.passbackMode == entity.PassbackOff || rfh.zone == "" {
+		return true
+	}
+
+	ps, err := rfh.dbStorage.PassbackState(p.ID, rfh.zone)
+	if err != nil {
+		if err == entity.ErrPassbackStateNotFound {
+			return true
+		}
+		// Failing DB storage shouldn't lock everyone in or out.
+		log.WithError(err).Error("failed to get passback state from DB storage")
+		return true
+	}
+
+	if ps.Direction != rfh.direction {
+		return true
+	}
+
+	reason := entity.PassbackAlreadyIn
+	if rfh.direction == entity.Out {
+		reason = entity.PassbackAlreadyOut
+	}
+
+	opened := rfh.passbackMode == entity.PassbackSoft
+
+	log = log.WithFields(logrus.Fields{
+		"person_id":         p.ID,
+		"zone":              rfh.zone,
+		"passback_mode":     rfh.passbackMode,
+		"passback_reason":   reason,
+		"last_passage_id":   ps.PassageID,
+		"last_passage_time": ps.Time,
+	})
+
+	if !record {
+		log.Debug("passback violation")
+		return opened
+	}
+
+	data, err := json.Marshal(entity.PassbackDeniedData{
+		PhotoID:         photoID,
+		PersonID:        p.ID,
+		PersonName:      p.Name,
+		PersonPosition:  p.Position,
+		PersonUnit:      p.Unit,
+		PassageID:       rfh.passageID,
+		Zone:            rfh.zone,
+		Mode:            rfh.passbackMode,
+		Reason:          reason,
+		LastPassageID:   ps.PassageID,
+		LastPassageTime: ps.Time,
+		Opened:          opened,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal passback denied data")
+		return opened
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      time.Now(),
+		PassageID: rfh.passageID,
+		Type:      entity.PassbackDenied,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add passback denied event to DB storage")
+	}
+
+	if opened {
+		log.Warn("passback violation, passage opened in soft mode")
+	} else {
+		log.Warn("passback violation, passage not opened")
+	}
+
+	return opened
+}
+
+func (rfh *RecognizedFaceHandler) setPassbackState(log *logrus.Entry, personID int64, t time.Time) {
+	if rfh.passbackMode == entity.PassbackOff || rfh.zone == "" {
+		return
+	}
+
+	err := rfh.dbStorage.SetPassbackState(entity.PassbackState{
+		PersonID:  personID,
+		Zone:      rfh.zone,
+		Direction: rfh.direction,
+		PassageID: rfh.passageID,
+		Time:      t,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to set passback state in DB storage")
+	}
+}
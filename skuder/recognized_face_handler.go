@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,8 +14,16 @@ import (
 
 type DBStorage interface {
 	Person(personID int64) (entity.Person, error)
-	FindClosestPersonFace(entity.FaceDescriptor) (entity.PersonFace, float64, bool)
+	FindClosestPersons(fd entity.FaceDescriptor, k int) []entity.PersonMatch
 	AddEvent(entity.Event) error
+	PassbackState(personID int64, zone string) (entity.PassbackState, error)
+	SetPassbackState(entity.PassbackState) error
+	PersonAccessGroups(personID int64) ([]entity.AccessGroup, error)
+	Holiday(date string) (entity.Holiday, error)
+	AddAlert(entity.Alert) (entity.Alert, error)
+	Credential(ct entity.CredentialType, value string) (entity.Credential, error)
+	Visitor(personID int64) (entity.Visitor, error)
+	AddVisitorEntry(personID int64) error
 }
 
 type PassageOpener interface {
@@ -22,41 +31,166 @@ type PassageOpener interface {
 	LastOpenTime() time.Time
 }
 
+// PersonPassageOpener is implemented by passage openers which open passage
+// depending on person let in.
+type PersonPassageOpener interface {
+	OpenPassageFor(p entity.Person) error
+}
+
 type PhotoStorage interface {
 	AddPhoto(photoID string, photo []byte) error
 }
 
 type RecognizedFaceHandler struct {
 	passageID             string
+	direction             entity.Direction
+	zone                  string
+	passbackMode          entity.PassbackMode
+	accessControl         bool
 	waitAfterOpen         time.Duration
+	voter                 *voter
+	livenessThreshold     float64
+	mode                  entity.PassageMode
+	secondFactorWindow    time.Duration
 	matchDistance         float64
+	ambiguityMargin       float64
+	candidatesCount       int
 	detectConfidenceLimit float64
 	dbStorage             DBStorage
 	photoStorage          PhotoStorage
 	passageOpener         PassageOpener
-	log                   *logrus.Entry
+	notifiers             []Notifier
+
+	visits   map[int64]*visit
+	visitsMx sync.Mutex
+
+	livenessFails   *personLimiter
+	watchlistAlerts *personLimiter
+
+	pendingFaces       map[int64]pendingFactor
+	pendingCredentials map[int64]pendingFactor
+	pendingMx          sync.Mutex
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// RecognizedFaceHandlerConfig holds settings of handler of passage
+// PassageID. Among CandidatesCount closest person faces the best person must
+// be closer than MatchDistance and than the second best one by at least non
+// zero AmbiguityMargin, otherwise match is rejected as ambiguous. Faces with
+// detect confidence below DetectConfidenceLimit are skipped, and ones with
+// liveness score below non zero LivenessThreshold don't count as matches.
+// Passage is opened only after person gets enough matches according to
+// Voting. Person must be allowed to pass by visitor pass or, with
+// AccessControl, by access groups, and by anti-passback in PassbackMode, with
+// passages in Direction tracked in Zone. Mode defines whether card reads and
+// PIN entries reported by passage opener open passage instead of face or
+// together with it within SecondFactorWindow. Recognitions of person within
+// WaitAfterOpen after the previous one are collapsed into a visit event,
+// recorded once per visit and skipped once passage is opened.
+type RecognizedFaceHandlerConfig struct {
+	PassageID             string
+	Direction             entity.Direction
+	Zone                  string
+	PassbackMode          entity.PassbackMode
+	AccessControl         bool
+	WaitAfterOpen         time.Duration
+	Voting                VotingPolicy
+	LivenessThreshold     float64
+	Mode                  entity.PassageMode
+	SecondFactorWindow    time.Duration
+	MatchDistance         float64
+	AmbiguityMargin       float64
+	CandidatesCount       int
+	DetectConfidenceLimit float64
 }
 
-func NewRecognizedFaceHandler(passageID string, waitAfterOpen time.Duration, matchDistance float64,
-	detectConfidenceLimit float64, dbs DBStorage, ps PhotoStorage, po PassageOpener) *RecognizedFaceHandler {
+// NewRecognizedFaceHandler creates handler which opens passage for person
+// closest to recognized face. Watchlisted persons are never let in, notifiers
+// are notified about them instead. Controller events reported by passage
+// opener are recorded as events.
+func NewRecognizedFaceHandler(c RecognizedFaceHandlerConfig, dbs DBStorage, ps PhotoStorage, po PassageOpener,
+	ns []Notifier) *RecognizedFaceHandler {
+
+	if c.CandidatesCount < 2 {
+		c.CandidatesCount = 2
+	}
 
-	return &RecognizedFaceHandler{
-		passageID:             passageID,
-		waitAfterOpen:         waitAfterOpen,
-		matchDistance:         matchDistance,
-		detectConfidenceLimit: detectConfidenceLimit,
+	rfh := &RecognizedFaceHandler{
+		passageID:             c.PassageID,
+		direction:             c.Direction,
+		zone:                  c.Zone,
+		passbackMode:          c.PassbackMode,
+		accessControl:         c.AccessControl,
+		waitAfterOpen:         c.WaitAfterOpen,
+		voter:                 newVoter(c.Voting),
+		livenessThreshold:     c.LivenessThreshold,
+		mode:                  c.Mode,
+		secondFactorWindow:    c.SecondFactorWindow,
+		matchDistance:         c.MatchDistance,
+		ambiguityMargin:       c.AmbiguityMargin,
+		candidatesCount:       c.CandidatesCount,
+		detectConfidenceLimit: c.DetectConfidenceLimit,
 		dbStorage:             dbs,
 		photoStorage:          ps,
 		passageOpener:         po,
+		notifiers:             ns,
+		visits:                map[int64]*visit{},
+		livenessFails:         newPersonLimiter(livenessFailEventPeriod),
+		watchlistAlerts:       newPersonLimiter(watchlistAlertPeriod),
+		pendingFaces:          map[int64]pendingFactor{},
+		pendingCredentials:    map[int64]pendingFactor{},
 		log:                   logrus.WithField("subsystem", "facer_recognized_face_handler"),
+		stop:                  make(chan struct{}),
 	}
+
+	if c.WaitAfterOpen > 0 {
+		rfh.wg.Add(1)
+		go func() {
+			defer rfh.wg.Done()
+
+			ticker := time.NewTicker(visitsCheckPeriod)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-rfh.stop:
+					rfh.endVisits(time.Now().Add(c.WaitAfterOpen))
+					return
+				case now := <-ticker.C:
+					rfh.endVisits(now.Add(-c.WaitAfterOpen))
+				}
+			}
+		}()
+	}
+
+	if cr, ok := po.(CredentialReader); ok && cr.CredentialReads() != nil {
+		rfh.wg.Add(1)
+		go rfh.readCredentials(cr)
+	}
+
+	if cer, ok := po.(ControllerEventReader); ok && cer.ControllerEvents() != nil {
+		rfh.wg.Add(1)
+		go rfh.readControllerEvents(cer)
+	}
+
+	return rfh
+}
+
+// Stop stops handler, records visits in progress and waits for pending alert
+// notifications.
+func (rfh *RecognizedFaceHandler) Stop() {
+	close(rfh.stop)
+	rfh.wg.Wait()
 }
 
 func (rfh *RecognizedFaceHandler) HandleRecognizedFace(rf entity.RecognizedFace) {
 	photoMD5Sum := md5.Sum(rf.Photo)
 	photoID := hex.EncodeToString(photoMD5Sum[:])
 
-	log := logrus.WithFields(logrus.Fields{
+	log := rfh.log.WithFields(logrus.Fields{
 		"photo_id":           photoID,
 		"frame_time":         rf.FrameTime,
 		"detect_time":        rf.DetectTime,
@@ -66,7 +200,6 @@ func (rfh *RecognizedFaceHandler) HandleRecognizedFace(rf entity.RecognizedFace)
 		"recognize_duration": rf.RecognizeTime.Sub(rf.DetectTime),
 	})
 
-
 	err := rfh.photoStorage.AddPhoto(photoID, rf.Photo)
 	if err != nil {
 		log.WithError(err).Error("failed to add photo to photo storage")
@@ -77,24 +210,78 @@ func (rfh *RecognizedFaceHandler) HandleRecognizedFace(rf entity.RecognizedFace)
 		return
 	}
 
-	pf, distance, found := rfh.dbStorage.FindClosestPersonFace(rf.Descriptor)
+	pms := rfh.dbStorage.FindClosestPersons(rf.Descriptor, rfh.candidatesCount)
 
-	if found && distance < rfh.matchDistance {
-		p, err := rfh.dbStorage.Person(pf.PersonID)
-		if err != nil {
-			if err == entity.ErrPersonNotFound {
-				rfh.addFaceRecognizeEvent(log, rf, photoID)
-			} else {
-				log.WithError(err).Error("failed to get person from DB storage")
-			}
-			return
+	if len(pms) == 0 || pms[0].Distance >= rfh.matchDistance {
+		rfh.addFaceRecognizeEvent(log, rf, photoID)
+		return
+	}
+
+	if len(pms) > 1 && pms[1].Distance-pms[0].Distance < rfh.ambiguityMargin {
+		rfh.addAmbiguousMatchEvent(log, rf, pms, photoID)
+		return
+	}
+
+	pf, distance := pms[0].Faces[0].PersonFace, pms[0].Distance
+
+	now := time.Now()
+
+	visiting, skip := rfh.continueVisit(pf.PersonID, now, photoID, distance, rf.DetectConfidence)
+	if skip {
+		log.WithField("person_id", pf.PersonID).Debug("person is still visiting, skipping")
+		return
+	}
+
+	if !rfh.checkLiveness(log, rf, pf.PersonID, photoID, distance) {
+		return
+	}
+
+	voted, avgDistance := rfh.voter.vote(pf.PersonID, now, distance)
+	if !voted {
+		log.WithFields(logrus.Fields{
+			"person_id":        pf.PersonID,
+			"distance":         distance,
+			"average_distance": avgDistance,
+		}).Debug("not enough votes for person, waiting for more matches")
+		return
+	}
+
+	p, err := rfh.dbStorage.Person(pf.PersonID)
+	if err != nil {
+		if err == entity.ErrPersonNotFound {
+			rfh.addFaceRecognizeEvent(log, rf, photoID)
+		} else {
+			log.WithError(err).Error("failed to get person from DB storage")
 		}
+		return
+	}
 
+	if !visiting {
 		rfh.addPersonRecognizeEvent(log, rf, pf, p, photoID, distance)
-		rfh.openPassage(log, pf, p)
-	} else {
-		rfh.addFaceRecognizeEvent(log, rf, photoID)
 	}
+
+	if p.Watchlist != "" {
+		rfh.handleWatchlistHit(log, rf, p, photoID, distance)
+		rfh.startVisit(p, now, photoID, distance, rf.DetectConfidence, false)
+		return
+	}
+
+	var opened bool
+
+	if visitor, allowed := rfh.mayPass(log, p, photoID, !visiting); allowed {
+		if rfh.mode == entity.FaceAndCardMode {
+			ct, paired := rfh.pairFace(p.ID, visitor, now)
+			if paired {
+				opened = rfh.openPassage(log, p, ct, visitor)
+			} else {
+				log.WithField("person_id", p.ID).Info("face matched, waiting for credential")
+			}
+		} else {
+			opened = rfh.openPassage(log, p, "", visitor)
+		}
+	}
+
+	rfh.startVisit(p, now, photoID, distance, rf.DetectConfidence, opened)
 }
 
 func (rfh *RecognizedFaceHandler) addFaceRecognizeEvent(log *logrus.Entry, rf entity.RecognizedFace, photoID string) {
@@ -108,12 +295,11 @@ func (rfh *RecognizedFaceHandler) addFaceRecognizeEvent(log *logrus.Entry, rf en
 		return
 	}
 
-	rf.PassageID
-
 	err = rfh.dbStorage.AddEvent(entity.Event{
-		Time: time.Now(),
-		Type: entity.FaceRecognize,
-		Data: data,
+		Time:      time.Now(),
+		PassageID: rfh.passageID,
+		Type:      entity.FaceRecognize,
+		Data:      data,
 	})
 	if err != nil {
 		log.WithError(err).Error("failed to add face recognized event")
@@ -142,9 +328,10 @@ func (rfh *RecognizedFaceHandler) addPersonRecognizeEvent(log *logrus.Entry, rf
 	}
 
 	err = rfh.dbStorage.AddEvent(entity.Event{
-		Time: time.Now(),
-		Type: entity.PersonRecognize,
-		Data: rpData,
+		Time:      time.Now(),
+		PassageID: rfh.passageID,
+		Type:      entity.PersonRecognize,
+		Data:      rpData,
 	})
 	if err != nil {
 		log.WithError(err).Error("failed to add person recognized event")
@@ -154,38 +341,133 @@ func (rfh *RecognizedFaceHandler) addPersonRecognizeEvent(log *logrus.Entry, rf
 	log.Info("person recognized")
 }
 
-func (rfh *RecognizedFaceHandler) openPassage(log *logrus.Entry, pf entity.PersonFace, p entity.Person) {
-	err := rfh.passageOpener.OpenPassage()
+func (rfh *RecognizedFaceHandler) addAmbiguousMatchEvent(log *logrus.Entry, rf entity.RecognizedFace,
+	pms []entity.PersonMatch, photoID string) {
+
+	var cs []entity.MatchCandidate
+
+	for _, pm := range pms {
+		if pm.Distance >= rfh.matchDistance+rfh.ambiguityMargin {
+			break
+		}
+
+		c := entity.MatchCandidate{
+			PersonID: pm.PersonID,
+			Distance: pm.Distance,
+		}
+
+		p, err := rfh.dbStorage.Person(pm.PersonID)
+		if err != nil {
+			log.WithError(err).WithField("person_id", pm.PersonID).
+				Warn("failed to get ambiguous match candidate from DB storage")
+		} else {
+			c.PersonName = p.Name
+		}
+
+		cs = append(cs, c)
+	}
+
+	data, err := json.Marshal(entity.AmbiguousMatchData{
+		PhotoID:          photoID,
+		PassageID:        rfh.passageID,
+		DetectConfidence: rf.DetectConfidence,
+		FaceDescriptor:   rf.Descriptor,
+		Candidates:       cs,
+	})
 	if err != nil {
-		log.WithError(err).Error("failed to open passage")
+		log.WithError(err).Error("failed to JSON marshal ambiguous match data")
+		return
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      time.Now(),
+		PassageID: rfh.passageID,
+		Type:      entity.AmbiguousMatch,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add ambiguous match event")
 		return
 	}
 
+	log.WithFields(logrus.Fields{
+		"best_person_id":        pms[0].PersonID,
+		"best_distance":         pms[0].Distance,
+		"second_best_person_id": pms[1].PersonID,
+		"second_best_distance":  pms[1].Distance,
+	}).Warn("ambiguous match, passage not opened")
+}
+
+// mayPass checks whether person may pass the passage now: visitor by the
+// pass, other persons by access groups and everyone by anti-passback. Denials
+// are recorded as events if record is set. It returns whether person is a
+// visitor and whether person may pass.
+func (rfh *RecognizedFaceHandler) mayPass(log *logrus.Entry, p entity.Person, photoID string,
+	record bool) (bool, bool) {
+
+	visitor, allowed := rfh.checkVisitor(log, p, photoID, record)
+	if !allowed {
+		return visitor, false
+	}
+
+	if !visitor && !rfh.checkAccess(log, p, photoID, record) {
+		return false, false
+	}
+
+	return visitor, rfh.checkPassback(log, p, photoID, record)
+}
+
+// openPassage opens passage for person and records it. Credential is the one
+// used to pass besides or instead of face, entries of visitor are counted.
+func (rfh *RecognizedFaceHandler) openPassage(log *logrus.Entry, p entity.Person, ct entity.CredentialType,
+	visitor bool) bool {
+	var err error
+
+	if ppo, ok := rfh.passageOpener.(PersonPassageOpener); ok {
+		err = ppo.OpenPassageFor(p)
+	} else {
+		err = rfh.passageOpener.OpenPassage()
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to open passage")
+		return false
+	}
+
 	openTime := time.Now()
 
 	log.WithField("passage_open_time", openTime).Info("passage opened")
 
 	data, err := json.Marshal(entity.PassageOpenData{
-		PersonID:       pf.PersonID,
+		PersonID:       p.ID,
 		PersonName:     p.Name,
 		PersonPosition: p.Position,
 		PersonUnit:     p.Unit,
 		PassageID:      rfh.passageID,
+		Credential:     ct,
 	})
 	if err != nil {
 		log.WithError(err).Error("failed to JSON marshal passage open data")
-		return
+		return true
 	}
 
 	err = rfh.dbStorage.AddEvent(entity.Event{
-		Time: openTime,
-		Type: entity.PassageOpen,
-		Data: data,
+		Time:      openTime,
+		PassageID: rfh.passageID,
+		Type:      entity.PassageOpen,
+		Data:      data,
 	})
 	if err != nil {
 		log.WithError(err).Error("failed to add passage open event to DB storage")
-		return
 	}
 
-	log.Info("passage opened")
+	rfh.setPassbackState(log, p.ID, openTime)
+
+	if visitor && rfh.direction == entity.In {
+		err = rfh.dbStorage.AddVisitorEntry(p.ID)
+		if err != nil {
+			log.WithError(err).Error("failed to add visitor entry to DB storage")
+		}
+	}
+
+	return true
 }
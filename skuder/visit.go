@@ -0,0 +1,165 @@
+package skuder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// visitsCheckPeriod is how often finished visits are looked for.
+const visitsCheckPeriod = time.Second
+
+// visit is person standing in front of passage camera. It lasts while person
+// is recognized again within waitAfterOpen after the last recognition.
+type visit struct {
+	person           entity.Person
+	firstSeen        time.Time
+	lastSeen         time.Time
+	recognitions     int
+	photoID          string
+	distance         float64
+	detectConfidence float64
+	opened           bool
+}
+
+// continueVisit extends person's visit if there is one. It returns whether
+// the visit continues, so person recognition and denials are already recorded
+// for it, and whether recognition must be skipped: passage is already opened
+// during the visit or person is watchlisted. Other recognitions go through
+// opening again, e.g. retrying after opening failure or pairing with a late
+// credential.
+func (rfh *RecognizedFaceHandler) continueVisit(personID int64, t time.Time, photoID string,
+	distance float64, detectConfidence float64) (bool, bool) {
+
+	rfh.visitsMx.Lock()
+	defer rfh.visitsMx.Unlock()
+
+	v, exists := rfh.visits[personID]
+	if !exists || t.Sub(v.lastSeen) >= rfh.waitAfterOpen {
+		return false, false
+	}
+
+	v.lastSeen = t
+	v.recognitions++
+
+	if distance < v.distance {
+		v.photoID = photoID
+		v.distance = distance
+		v.detectConfidence = detectConfidence
+	}
+
+	return true, v.opened || v.person.Watchlist != ""
+}
+
+// startVisit starts person's visit or marks the one in progress as opened.
+func (rfh *RecognizedFaceHandler) startVisit(p entity.Person, t time.Time, photoID string,
+	distance float64, detectConfidence float64, opened bool) {
+
+	if rfh.waitAfterOpen <= 0 {
+		return
+	}
+
+	rfh.visitsMx.Lock()
+
+	v, exists := rfh.visits[p.ID]
+	if exists && t.Sub(v.lastSeen) < rfh.waitAfterOpen {
+		v.opened = v.opened || opened
+		rfh.visitsMx.Unlock()
+		return
+	}
+
+	rfh.visits[p.ID] = &visit{
+		person:           p,
+		firstSeen:        t,
+		lastSeen:         t,
+		recognitions:     1,
+		photoID:          photoID,
+		distance:         distance,
+		detectConfidence: detectConfidence,
+		opened:           opened,
+	}
+
+	rfh.visitsMx.Unlock()
+
+	// Previous visit is expired but not collected yet.
+	if exists {
+		rfh.addVisitEvent(v)
+	}
+}
+
+// setVisitOpened marks person's visit as the one passage was opened during,
+// when passage is opened by second factor after face match.
+func (rfh *RecognizedFaceHandler) setVisitOpened(personID int64) {
+	rfh.visitsMx.Lock()
+	defer rfh.visitsMx.Unlock()
+
+	if v, exists := rfh.visits[personID]; exists {
+		v.opened = true
+	}
+}
+
+// endVisits records and forgets visits with last recognition before given
+// time. Visits are recorded after unlocking, so that DB doesn't hold up
+// recognitions.
+func (rfh *RecognizedFaceHandler) endVisits(before time.Time) {
+	var ended []*visit
+
+	rfh.visitsMx.Lock()
+
+	for personID, v := range rfh.visits {
+		if v.lastSeen.Before(before) {
+			ended = append(ended, v)
+			delete(rfh.visits, personID)
+		}
+	}
+
+	rfh.visitsMx.Unlock()
+
+	for _, v := range ended {
+		rfh.addVisitEvent(v)
+	}
+}
+
+func (rfh *RecognizedFaceHandler) addVisitEvent(v *visit) {
+	log := rfh.log.WithFields(logrus.Fields{
+		"person_id":    v.person.ID,
+		"first_seen":   v.firstSeen,
+		"last_seen":    v.lastSeen,
+		"recognitions": v.recognitions,
+	})
+
+	data, err := json.Marshal(entity.VisitData{
+		PhotoID:             v.photoID,
+		PersonID:            v.person.ID,
+		PersonName:          v.person.Name,
+		PersonPosition:      v.person.Position,
+		PersonUnit:          v.person.Unit,
+		PassageID:           rfh.passageID,
+		FirstSeen:           v.firstSeen,
+		LastSeen:            v.lastSeen,
+		Recognitions:        v.recognitions,
+		DetectConfidence:    v.detectConfidence,
+		DescriptorsDistance: v.distance,
+		Opened:              v.opened,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal visit data")
+		return
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      v.firstSeen,
+		PassageID: rfh.passageID,
+		Type:      entity.Visit,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add visit event to DB storage")
+		return
+	}
+
+	log.Info("visit ended")
+}
@@ -0,0 +1,138 @@
+package skuder
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// checkVisitor checks pass of person if person is a visitor and records
+// visitor denied event on failure if record is set. It returns whether person
+// is a visitor and false if passage must not be opened.
+func (rfh *RecognizedFaceHandler) checkVisitor(log *logrus.Entry, p entity.Person, photoID string,
+	record bool) (bool, bool) {
+
+	v, err := rfh.dbStorage.Visitor(p.ID)
+	if err != nil {
+		if err == entity.ErrVisitorNotFound {
+			return false, true
+		}
+		log.WithError(err).Error("failed to get visitor from DB storage")
+		return false, false
+	}
+
+	now := time.Now()
+
+	reason := v.Check(rfh.passageID, rfh.direction, now)
+	if reason == "" {
+		return true, true
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"person_id":      p.ID,
+		"visitor_reason": reason,
+		"valid_from":     v.ValidFrom,
+		"valid_to":       v.ValidTo,
+		"entries":        v.Entries,
+		"max_entries":    v.MaxEntries,
+	})
+
+	if !record {
+		log.Debug("visitor pass denied, passage not opened")
+		return true, false
+	}
+
+	data, err := json.Marshal(entity.VisitorDeniedData{
+		PhotoID:      photoID,
+		PersonID:     p.ID,
+		PersonName:   p.Name,
+		HostPersonID: v.HostPersonID,
+		PassageID:    rfh.passageID,
+		Reason:       reason,
+		ValidFrom:    v.ValidFrom,
+		ValidTo:      v.ValidTo,
+		Entries:      v.Entries,
+		MaxEntries:   v.MaxEntries,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal visitor denied data")
+		return true, false
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      now,
+		PassageID: rfh.passageID,
+		Type:      entity.VisitorDenied,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add visitor denied event to DB storage")
+	}
+
+	log.Warn("visitor pass denied, passage not opened")
+
+	return true, false
+}
+
+type VisitorsStorage interface {
+	RemoveExpiredVisitorFaces(before time.Time) (int64, error)
+}
+
+// VisitorCleaner periodically removes faces of visitors with expired passes,
+// so they are not recognized anymore.
+type VisitorCleaner struct {
+	storage VisitorsStorage
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewVisitorCleaner(checkPeriod time.Duration, s VisitorsStorage) *VisitorCleaner {
+	vc := &VisitorCleaner{
+		storage: s,
+		log:     logrus.WithField("subsystem", "skuder_visitor_cleaner"),
+		stop:    make(chan struct{}),
+	}
+
+	vc.wg.Add(1)
+	go func() {
+		defer vc.wg.Done()
+
+		ticker := time.NewTicker(checkPeriod)
+		defer ticker.Stop()
+
+		for {
+			vc.clean()
+
+			select {
+			case <-vc.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return vc
+}
+
+func (vc *VisitorCleaner) Stop() {
+	close(vc.stop)
+	vc.wg.Wait()
+}
+
+func (vc *VisitorCleaner) clean() {
+	n, err := vc.storage.RemoveExpiredVisitorFaces(time.Now())
+	if err != nil {
+		vc.log.WithError(err).Error("failed to remove expired visitor faces")
+		return
+	}
+
+	if n > 0 {
+		vc.log.WithField("removed", n).Info("expired visitor faces removed")
+	}
+}
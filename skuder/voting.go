@@ -0,0 +1,80 @@
+package skuder
+
+import "time"
+
+// VotingPolicy defines how many frames must match the same person before
+// passage is opened. Person must be matched at least MinMatches times within
+// Window and, if MaxAverageDistance is not zero, average descriptors distance
+// of these matches must not exceed it. Zero policy opens on the first match.
+type VotingPolicy struct {
+	MinMatches         int
+	Window             time.Duration
+	MaxAverageDistance float64
+}
+
+func (vp VotingPolicy) enabled() bool {
+	return vp.MinMatches > 1 || vp.MaxAverageDistance > 0
+}
+
+type vote struct {
+	time     time.Time
+	distance float64
+}
+
+// voter collects person matches within voting window. It is not safe for
+// concurrent use, recognized faces of a passage are handled one by one.
+type voter struct {
+	policy VotingPolicy
+	votes  map[int64][]vote
+}
+
+func newVoter(p VotingPolicy) *voter {
+	return &voter{
+		policy: p,
+		votes:  map[int64][]vote{},
+	}
+}
+
+// vote adds person match and returns whether person got enough votes and
+// average distance of the votes. Votes of the person are reset on success.
+func (v *voter) vote(personID int64, t time.Time, distance float64) (bool, float64) {
+	if !v.policy.enabled() {
+		return true, distance
+	}
+
+	since := t.Add(-v.policy.Window)
+
+	for id, vs := range v.votes {
+		i := 0
+		for i < len(vs) && vs[i].time.Before(since) {
+			i++
+		}
+		if i == len(vs) {
+			delete(v.votes, id)
+		} else {
+			v.votes[id] = vs[i:]
+		}
+	}
+
+	vs := append(v.votes[personID], vote{time: t, distance: distance})
+	v.votes[personID] = vs
+
+	var sum float64
+	for _, vt := range vs {
+		sum += vt.distance
+	}
+
+	avg := sum / float64(len(vs))
+
+	if len(vs) < v.policy.MinMatches {
+		return false, avg
+	}
+
+	if v.policy.MaxAverageDistance > 0 && avg > v.policy.MaxAverageDistance {
+		return false, avg
+	}
+
+	delete(v.votes, personID)
+
+	return true, avg
+}
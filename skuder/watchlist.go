@@ -0,0 +1,95 @@
+package skuder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// watchlistAlertPeriod is how often watchlist hit of the same person at
+// passage raises alert, regardless of visits.
+const watchlistAlertPeriod = time.Minute
+
+// Notifier delivers alerts to security.
+type Notifier interface {
+	Notify(entity.Alert) error
+}
+
+// handleWatchlistHit records watchlist hit event and raises alert once per
+// watchlistAlertPeriod for person. Passage is never opened for watchlisted
+// person.
+func (rfh *RecognizedFaceHandler) handleWatchlistHit(log *logrus.Entry, rf entity.RecognizedFace,
+	p entity.Person, photoID string, distance float64) {
+
+	log = log.WithFields(logrus.Fields{
+		"person_id": p.ID,
+		"watchlist": p.Watchlist,
+	})
+
+	now := time.Now()
+
+	if rfh.watchlistAlerts.recorded(p.ID, now) {
+		log.Debug("watchlist hit, passage not opened")
+		return
+	}
+
+	data, err := json.Marshal(entity.WatchlistHitData{
+		PhotoID:             photoID,
+		PersonID:            p.ID,
+		PersonName:          p.Name,
+		PersonPosition:      p.Position,
+		PersonUnit:          p.Unit,
+		Watchlist:           p.Watchlist,
+		PassageID:           rfh.passageID,
+		DetectConfidence:    rf.DetectConfidence,
+		FaceDescriptor:      rf.Descriptor,
+		DescriptorsDistance: distance,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to JSON marshal watchlist hit data")
+		return
+	}
+
+	err = rfh.dbStorage.AddEvent(entity.Event{
+		Time:      now,
+		PassageID: rfh.passageID,
+		Type:      entity.WatchlistHit,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add watchlist hit event to DB storage")
+	}
+
+	personID := p.ID
+
+	a, err := rfh.dbStorage.AddAlert(entity.Alert{
+		Time:      now,
+		Type:      entity.WatchlistAlert,
+		Status:    entity.AlertActive,
+		PassageID: rfh.passageID,
+		PersonID:  &personID,
+		Data:      data,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to add watchlist alert to DB storage")
+		return
+	}
+
+	log.WithField("alert_id", a.ID).Warn("watchlist hit, passage not opened")
+
+	// Notifiers may be slow, recognized faces handling shouldn't wait for them.
+	for _, n := range rfh.notifiers {
+		rfh.wg.Add(1)
+		go func(n Notifier) {
+			defer rfh.wg.Done()
+
+			err := n.Notify(a)
+			if err != nil {
+				log.WithError(err).WithField("alert_id", a.ID).Error("failed to notify about alert")
+			}
+		}(n)
+	}
+}
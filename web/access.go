@@ -0,0 +1,164 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func (s *Server) getAPIAccessGroups(c echo.Context) error {
+	ags, err := s.dbStorage.AccessGroups()
+	if err != nil {
+		return fmt.Errorf("dbStorage.AccessGroups: %w", err)
+	}
+	if ags == nil {
+		ags = []entity.AccessGroup{}
+	}
+	return c.JSON(http.StatusOK, ags)
+}
+
+func (s *Server) getAPIAccessGroup(c echo.Context) error {
+	accessGroupID, err := strconv.ParseInt(c.Param("access_group_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse access_group_id: "+err.Error())
+	}
+
+	ag, err := s.dbStorage.AccessGroup(accessGroupID)
+	if err != nil {
+		if err == entity.ErrAccessGroupNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.AccessGroup: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, ag)
+}
+
+func (s *Server) postAPIAccessGroups(c echo.Context) error {
+	ag, err := bindAccessGroup(c)
+	if err != nil {
+		return err
+	}
+
+	ag, err = s.dbStorage.AddAccessGroup(ag)
+	if err != nil {
+		return fmt.Errorf("dbStorage.AddAccessGroup: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, ag)
+}
+
+func (s *Server) putAPIAccessGroups(c echo.Context) error {
+	ag, err := bindAccessGroup(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.dbStorage.SetAccessGroup(ag)
+	if err != nil {
+		if err == entity.ErrAccessGroupNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.SetAccessGroup: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIAccessGroup(c echo.Context) error {
+	accessGroupID, err := strconv.ParseInt(c.Param("access_group_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse access_group_id: "+err.Error())
+	}
+
+	err = s.dbStorage.RemoveAccessGroup(accessGroupID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.RemoveAccessGroup: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func bindAccessGroup(c echo.Context) (entity.AccessGroup, error) {
+	var ag entity.AccessGroup
+
+	err := c.Bind(&ag)
+	if err != nil {
+		return ag, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind access group: %w", err))
+	}
+
+	ag.Name = strings.TrimSpace(ag.Name)
+	if ag.Name == "" {
+		return ag, echo.NewHTTPError(http.StatusBadRequest, "empty name")
+	}
+
+	for i, tw := range ag.Windows {
+		err = tw.Validate()
+		if err != nil {
+			return ag, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid windows %d: %s", i, err))
+		}
+	}
+
+	for i, tw := range ag.HolidayWindows {
+		err = tw.Validate()
+		if err != nil {
+			return ag, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid holiday_windows %d: %s", i, err))
+		}
+	}
+
+	return ag, nil
+}
+
+func (s *Server) getAPIHolidays(c echo.Context) error {
+	hs, err := s.dbStorage.Holidays()
+	if err != nil {
+		return fmt.Errorf("dbStorage.Holidays: %w", err)
+	}
+	if hs == nil {
+		hs = []entity.Holiday{}
+	}
+	return c.JSON(http.StatusOK, hs)
+}
+
+func (s *Server) putAPIHolidays(c echo.Context) error {
+	var h entity.Holiday
+
+	err := c.Bind(&h)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind holiday: %w", err))
+	}
+
+	_, err = time.Parse("2006-01-02", h.Date)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date")
+	}
+
+	err = s.dbStorage.SetHoliday(h)
+	if err != nil {
+		return fmt.Errorf("dbStorage.SetHoliday: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIHoliday(c echo.Context) error {
+	date := c.Param("date")
+
+	_, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date")
+	}
+
+	err = s.dbStorage.RemoveHoliday(date)
+	if err != nil {
+		return fmt.Errorf("dbStorage.RemoveHoliday: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+const (
+	defaultAlertsLimit = 100
+
+	// alertsStreamPingPeriod keeps alerts stream alive through proxies.
+	alertsStreamPingPeriod = 30 * time.Second
+)
+
+// Notify delivers alert to subscribed web UI clients. Slow clients miss
+// alerts instead of blocking others, they get actual state on reload anyway.
+func (s *Server) Notify(a entity.Alert) error {
+	s.alertSubscribersMx.Lock()
+	defer s.alertSubscribersMx.Unlock()
+
+	for sub := range s.alertSubscribers {
+		select {
+		case sub <- a:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) subscribeAlerts() chan entity.Alert {
+	sub := make(chan entity.Alert, 16)
+
+	s.alertSubscribersMx.Lock()
+	s.alertSubscribers[sub] = struct{}{}
+	s.alertSubscribersMx.Unlock()
+
+	return sub
+}
+
+func (s *Server) unsubscribeAlerts(sub chan entity.Alert) {
+	s.alertSubscribersMx.Lock()
+	delete(s.alertSubscribers, sub)
+	s.alertSubscribersMx.Unlock()
+}
+
+func (s *Server) getAPIAlerts(c echo.Context) error {
+	limit := defaultAlertsLimit
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	var statuses []entity.AlertStatus
+
+	for _, st := range c.QueryParams()["status"] {
+		switch entity.AlertStatus(st) {
+		case entity.AlertActive, entity.AlertAcknowledged, entity.AlertResolved:
+			statuses = append(statuses, entity.AlertStatus(st))
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+		}
+	}
+
+	as, err := s.dbStorage.Alerts(limit, statuses...)
+	if err != nil {
+		return fmt.Errorf("dbStorage.Alerts: %w", err)
+	}
+	if as == nil {
+		as = []entity.Alert{}
+	}
+
+	return c.JSON(http.StatusOK, as)
+}
+
+// getAPIAlertsStream streams new and changed alerts as server-sent events.
+func (s *Server) getAPIAlertsStream(c echo.Context) error {
+	sub := s.subscribeAlerts()
+	defer s.unsubscribeAlerts(sub)
+
+	res := c.Response()
+
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(alertsStreamPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return nil
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+			_, err := res.Write([]byte(": ping\n\n"))
+			if err != nil {
+				return nil
+			}
+		case a := <-sub:
+			data, err := json.Marshal(a)
+			if err != nil {
+				return fmt.Errorf("JSON marshal alert: %w", err)
+			}
+			_, err = fmt.Fprintf(res, "id: %d\nevent: alert\ndata: %s\n\n", a.ID, data)
+			if err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+func (s *Server) postAPIAlertAcknowledge(c echo.Context) error {
+	alertID, err := strconv.ParseInt(c.Param("alert_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse alert_id: "+err.Error())
+	}
+
+	a, err := s.dbStorage.AcknowledgeAlert(alertID, c.Get("user").(entity.User).Login)
+	if err != nil {
+		return alertUpdateError("dbStorage.AcknowledgeAlert", err)
+	}
+
+	_ = s.Notify(a)
+
+	return c.JSON(http.StatusOK, a)
+}
+
+func (s *Server) postAPIAlertResolve(c echo.Context) error {
+	alertID, err := strconv.ParseInt(c.Param("alert_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse alert_id: "+err.Error())
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+
+	err = c.Bind(&req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind resolve request: %w", err))
+	}
+
+	a, err := s.dbStorage.ResolveAlert(alertID, c.Get("user").(entity.User).Login, req.Comment)
+	if err != nil {
+		return alertUpdateError("dbStorage.ResolveAlert", err)
+	}
+
+	_ = s.Notify(a)
+
+	return c.JSON(http.StatusOK, a)
+}
+
+func alertUpdateError(method string, err error) error {
+	switch err {
+	case entity.ErrAlertNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, err)
+	case entity.ErrAlertStatusConflict:
+		return echo.NewHTTPError(http.StatusConflict, err)
+	}
+	return fmt.Errorf("%s: %w", method, err)
+}
@@ -0,0 +1,211 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// exportFlushEvery is number of rows after which export response is flushed
+// to the client.
+const exportFlushEvery = 500
+
+var eventsExportHeader = []string{
+	"id", "time", "type", "passage_id", "passage_name",
+	"person_id", "person_name", "person_position", "person_unit",
+	"photo_id", "detect_confidence", "descriptors_distance",
+}
+
+// exportEventData is union of event data fields worth exporting. Face
+// descriptors are left out on purpose.
+type exportEventData struct {
+	PersonID            *int64   `json:"person_id"`
+	PersonName          string   `json:"person_name"`
+	PersonPosition      string   `json:"person_position"`
+	PersonUnit          string   `json:"person_unit"`
+	PassageID           string   `json:"passage_id"`
+	PhotoID             string   `json:"photo_id"`
+	DetectConfidence    *float64 `json:"detect_confidence"`
+	DescriptorsDistance *float64 `json:"descriptors_distance"`
+}
+
+type rowWriter interface {
+	WriteRow([]string) error
+	Flush() error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (cw csvRowWriter) WriteRow(cells []string) error {
+	return cw.w.Write(cells)
+}
+
+func (cw csvRowWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw csvRowWriter) Close() error {
+	return cw.Flush()
+}
+
+func (s *Server) getAPIEventsExport(c echo.Context) error {
+	eventsFilters, err := parseEventsFilters(c)
+	if err != nil {
+		return err
+	}
+
+	// Events are exported in time order unless ordered otherwise. Limited
+	// and continued exports are already ordered by DB storage like pages.
+	qs := c.QueryParams()
+	if qs.Get("order_by") == "" && qs.Get("limit") == "" && qs.Get("cursor") == "" {
+		eventsFilters = append(eventsFilters, entity.EventsOrderBy("time"))
+	}
+
+	format := c.QueryParam("format")
+
+	var contentType string
+
+	switch format {
+	case "", "csv":
+		format = "csv"
+		contentType = "text/csv; charset=utf-8"
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid format")
+	}
+
+	res := c.Response()
+
+	// Headers are written lazily with the first row, so that invalid filters
+	// are still reported with proper status code.
+	var rw rowWriter
+
+	begin := func() error {
+		res.Header().Set(echo.HeaderContentType, contentType)
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="events_%s.%s"`,
+			time.Now().Format("20060102_150405"), format))
+		res.WriteHeader(http.StatusOK)
+
+		if format == "xlsx" {
+			xw, err := newXLSXWriter(res, "events")
+			if err != nil {
+				return fmt.Errorf("create XLSX writer: %w", err)
+			}
+			rw = xw
+		} else {
+			rw = csvRowWriter{w: csv.NewWriter(res)}
+		}
+
+		return rw.WriteRow(eventsExportHeader)
+	}
+
+	var rows int
+
+	err = s.dbStorage.EachEvent(func(e entity.Event) error {
+		if rw == nil {
+			err := begin()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := rw.WriteRow(s.eventExportRow(e))
+		if err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+
+		rows++
+
+		if rows%exportFlushEvery == 0 {
+			err = rw.Flush()
+			if err != nil {
+				return fmt.Errorf("flush rows: %w", err)
+			}
+			res.Flush()
+		}
+
+		return nil
+	}, eventsFilters...)
+
+	if err != nil {
+		if tErr, ok := err.(entity.InvalidParamErr); ok && rw == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+strcase.ToSnake(tErr.Param))
+		}
+		if rw == nil {
+			return fmt.Errorf("dbStorage.EachEvent: %w", err)
+		}
+		// Response is already committed, all we can do is to cut it short.
+		s.log.WithError(err).WithField("rows", rows).Error("failed to export events")
+		return nil
+	}
+
+	if rw == nil {
+		err = begin()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rw.Close()
+	if err != nil {
+		s.log.WithError(err).Error("failed to finish events export")
+	}
+
+	return nil
+}
+
+func (s *Server) eventExportRow(e entity.Event) []string {
+	var d exportEventData
+
+	if len(e.Data) > 0 {
+		err := json.Unmarshal(e.Data, &d)
+		if err != nil {
+			s.log.WithError(err).WithField("event_id", e.ID).Warn("failed to JSON unmarshal event data")
+		}
+	}
+
+	passageID := e.PassageID
+	if passageID == "" {
+		passageID = d.PassageID
+	}
+
+	var personID, detectConfidence, distance string
+
+	if d.PersonID != nil {
+		personID = strconv.FormatInt(*d.PersonID, 10)
+	}
+	if d.DetectConfidence != nil {
+		detectConfidence = strconv.FormatFloat(*d.DetectConfidence, 'f', -1, 64)
+	}
+	if d.DescriptorsDistance != nil {
+		distance = strconv.FormatFloat(*d.DescriptorsDistance, 'f', -1, 64)
+	}
+
+	return []string{
+		strconv.FormatInt(e.ID, 10),
+		e.Time.Format(time.RFC3339),
+		string(e.Type),
+		passageID,
+		s.passageNames[passageID],
+		personID,
+		d.PersonName,
+		d.PersonPosition,
+		d.PersonUnit,
+		d.PhotoID,
+		detectConfidence,
+		distance,
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+// getAPIFaceClusters returns face clusters of at least min_count faces, two
+// by default, since single unknown faces are rarely worth enrolling.
+func (s *Server) getAPIFaceClusters(c echo.Context) error {
+	minCount := 2
+
+	if mc := c.QueryParam("min_count"); mc != "" {
+		var err error
+
+		minCount, err = strconv.Atoi(mc)
+		if err != nil || minCount < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid min_count")
+		}
+	}
+
+	fcs, err := s.dbStorage.FaceClusters(minCount)
+	if err != nil {
+		return fmt.Errorf("dbStorage.FaceClusters: %w", err)
+	}
+	if fcs == nil {
+		fcs = []entity.FaceCluster{}
+	}
+
+	return c.JSON(http.StatusOK, fcs)
+}
+
+func (s *Server) getAPIFaceCluster(c echo.Context) error {
+	faceClusterID, err := strconv.ParseInt(c.Param("face_cluster_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse face_cluster_id: "+err.Error())
+	}
+
+	fc, err := s.dbStorage.FaceCluster(faceClusterID)
+	if err != nil {
+		if err == entity.ErrFaceClusterNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.FaceCluster: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, fc)
+}
+
+func (s *Server) getAPIFaceClusterFaces(c echo.Context) error {
+	faceClusterID, err := strconv.ParseInt(c.Param("face_cluster_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse face_cluster_id: "+err.Error())
+	}
+
+	fcfs, err := s.dbStorage.FaceClusterFaces(faceClusterID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.FaceClusterFaces: %w", err)
+	}
+	if fcfs == nil {
+		fcfs = []entity.FaceClusterFace{}
+	}
+
+	return c.JSON(http.StatusOK, fcfs)
+}
+
+// postAPIFaceClusterPerson creates person from face cluster with all its
+// faces enrolled.
+func (s *Server) postAPIFaceClusterPerson(c echo.Context) error {
+	faceClusterID, err := strconv.ParseInt(c.Param("face_cluster_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse face_cluster_id: "+err.Error())
+	}
+
+	var p entity.Person
+
+	err = c.Bind(&p)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind person: %w", err))
+	}
+
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty name")
+	}
+
+	p, err = s.dbStorage.AddFaceClusterPerson(faceClusterID, p)
+	if err != nil {
+		if err == entity.ErrFaceClusterNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.AddFaceClusterPerson: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+func (s *Server) deleteAPIFaceCluster(c echo.Context) error {
+	faceClusterID, err := strconv.ParseInt(c.Param("face_cluster_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse face_cluster_id: "+err.Error())
+	}
+
+	err = s.dbStorage.RemoveFaceCluster(faceClusterID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.RemoveFaceCluster: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
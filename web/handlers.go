@@ -16,6 +16,7 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/iancoleman/strcase"
 	"github.com/labstack/echo"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/bennyharvey/soma/entity"
@@ -259,6 +260,127 @@ func (s *Server) deleteAPIPerson(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+func (s *Server) getAPIPersonPassback(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"),
+		10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	pss, err := s.dbStorage.PassbackStates(personID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.PassbackStates: %w", err)
+	}
+
+	if pss == nil {
+		pss = []entity.PassbackState{}
+	}
+
+	return c.JSON(http.StatusOK, pss)
+}
+
+// deleteAPIPersonPassback resets person's anti-passback state in zone given
+// by query param or in all zones.
+func (s *Server) deleteAPIPersonPassback(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"),
+		10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+
+	err = s.dbStorage.ResetPassbackStates(personID, zone)
+	if err != nil {
+		return fmt.Errorf("dbStorage.ResetPassbackStates: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"person_id": personID,
+		"zone":      zone,
+		"login":     c.Get("user").(entity.User).Login,
+	}).Info("passback state reset")
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIPersonCredentials(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"),
+		10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	cs, err := s.dbStorage.PersonCredentials(personID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.PersonCredentials: %w", err)
+	}
+
+	if cs == nil {
+		cs = []entity.Credential{}
+	}
+
+	return c.JSON(http.StatusOK, cs)
+}
+
+func (s *Server) postAPIPersonCredentials(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"),
+		10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	var cr entity.Credential
+
+	err = c.Bind(&cr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind credential: %w", err))
+	}
+
+	cr.PersonID = personID
+
+	switch cr.Type {
+	case entity.Card, entity.PIN:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid type")
+	}
+
+	cr.Value = strings.TrimSpace(cr.Value)
+	if cr.Value == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty value")
+	}
+
+	_, err = s.dbStorage.Credential(cr.Type, cr.Value)
+	if err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "credential is already assigned")
+	}
+	if err != entity.ErrCredentialNotFound {
+		return fmt.Errorf("dbStorage.Credential: %w", err)
+	}
+
+	cr, err = s.dbStorage.AddCredential(cr)
+	if err != nil {
+		return fmt.Errorf("dbStorage.AddCredential: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, cr)
+}
+
+func (s *Server) deleteAPICredential(c echo.Context) error {
+	credentialID, err := strconv.ParseInt(c.Param("credential_id"),
+		10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse credential_id: "+err.Error())
+	}
+
+	err = s.dbStorage.RemoveCredential(credentialID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.RemoveCredential: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 func (s *Server) getAPIPersonFaces(c echo.Context) error {
 	personID, err := strconv.ParseInt(c.Param("person_id"),
 		10, 64)
@@ -403,6 +525,29 @@ func (s *Server) getAPIPassageNames(c echo.Context) error {
 }
 
 func (s *Server) getAPIEvents(c echo.Context) error {
+	eventsFilters, err := parseEventsFilters(c)
+	if err != nil {
+		return err
+	}
+
+	ep, err := s.dbStorage.EventsPage(eventsFilters...)
+	if err != nil {
+		switch tErr := err.(type) {
+		case entity.InvalidParamErr:
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+strcase.ToSnake(tErr.Param))
+		default:
+			return fmt.Errorf("dbStorage.EventsPage: %w", err)
+		}
+	}
+
+	if ep.Events == nil {
+		ep.Events = []entity.Event{}
+	}
+
+	return c.JSON(http.StatusOK, ep)
+}
+
+func parseEventsFilters(c echo.Context) ([]entity.EventsFilter, error) {
 	var eventsFilters []entity.EventsFilter
 
 	for key, values := range c.QueryParams() {
@@ -410,13 +555,13 @@ func (s *Server) getAPIEvents(c echo.Context) error {
 		case "from":
 			from, err := time.Parse(time.RFC3339, values[0])
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "invalid from")
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid from")
 			}
 			eventsFilters = append(eventsFilters, entity.EventsFrom(from))
 		case "to":
 			to, err := time.Parse(time.RFC3339, values[0])
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "invalid to")
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid to")
 			}
 			eventsFilters = append(eventsFilters, entity.EventsTo(to.Add(time.Second-1)))
 		case "passage_id":
@@ -424,11 +569,17 @@ func (s *Server) getAPIEvents(c echo.Context) error {
 		case "person_id":
 			personID, err := strconv.ParseInt(values[0], 10, 64)
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "invalid person_id")
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid person_id")
 			}
 			eventsFilters = append(eventsFilters, entity.EventsPersonID(personID))
+		case "type":
+			eventsFilters = append(eventsFilters, entity.EventsType(entity.EventType(values[0])))
 		case "person_name":
 			eventsFilters = append(eventsFilters, entity.EventsPersonName(values[0]))
+		case "person_position":
+			eventsFilters = append(eventsFilters, entity.EventsPersonPosition(values[0]))
+		case "person_unit":
+			eventsFilters = append(eventsFilters, entity.EventsPersonUnit(values[0]))
 		case "order_by":
 			eventsFilters = append(eventsFilters, entity.EventsOrderBy(values[0]))
 		case "order_direction":
@@ -436,31 +587,31 @@ func (s *Server) getAPIEvents(c echo.Context) error {
 		case "limit":
 			limit, err := strconv.Atoi(values[0])
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
 			}
 			eventsFilters = append(eventsFilters, entity.EventsLimit(limit))
 		case "offset":
 			offset, err := strconv.Atoi(values[0])
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "invalid offset")
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid offset")
 			}
 			eventsFilters = append(eventsFilters, entity.EventsOffset(offset))
+		case "cursor":
+			cursor, err := entity.ParseEventsCursor(values[0])
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+			}
+			eventsFilters = append(eventsFilters, entity.EventsAfter(cursor))
+		case "with_total":
+			withTotal, err := strconv.ParseBool(values[0])
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid with_total")
+			}
+			if withTotal {
+				eventsFilters = append(eventsFilters, entity.EventsWithTotal())
+			}
 		}
 	}
 
-	e, err := s.dbStorage.Events(eventsFilters...)
-	if err != nil {
-		switch tErr := err.(type) {
-		case entity.InvalidParamErr:
-			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+strcase.ToSnake(tErr.Param))
-		default:
-			return fmt.Errorf("dbStorage.Event: %w", err)
-		}
-	}
-
-	if e == nil {
-		e = []entity.Event{}
-	}
-
-	return c.JSON(http.StatusOK, e)
+	return eventsFilters, nil
 }
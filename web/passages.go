@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+type passageStatus struct {
+	entity.PassageStatus
+	Name string `json:"name"`
+}
+
+// getAPIPassages returns statuses of passages sorted by ID.
+func (s *Server) getAPIPassages(c echo.Context) error {
+	pss := []passageStatus{}
+
+	for passageID, p := range s.passages {
+		pss = append(pss, passageStatus{
+			PassageStatus: p.Status(),
+			Name:          s.passageNames[passageID],
+		})
+	}
+
+	sort.Slice(pss, func(i, j int) bool {
+		return pss[i].PassageID < pss[j].PassageID
+	})
+
+	return c.JSON(http.StatusOK, pss)
+}
+
+// postAPIPassageOpen opens passage by operator. Reason is required, person
+// let in is optional.
+func (s *Server) postAPIPassageOpen(c echo.Context) error {
+	passageID := c.Param("passage_id")
+
+	p, exists := s.passages[passageID]
+	if !exists {
+		return echo.NewHTTPError(http.StatusNotFound, "passage not found")
+	}
+
+	var req struct {
+		Reason   string `json:"reason"`
+		PersonID int64  `json:"person_id"`
+	}
+
+	err := c.Bind(&req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind open request: %w", err))
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty reason")
+	}
+
+	data := entity.PassageOpenData{
+		PassageID:     passageID,
+		OperatorLogin: c.Get("user").(entity.User).Login,
+		Reason:        req.Reason,
+	}
+
+	var person entity.Person
+
+	if req.PersonID != 0 {
+		person, err = s.dbStorage.Person(req.PersonID)
+		if err != nil {
+			if err == entity.ErrPersonNotFound {
+				return echo.NewHTTPError(http.StatusBadRequest, "person not found")
+			}
+			return fmt.Errorf("dbStorage.Person: %w", err)
+		}
+
+		data.PersonID = person.ID
+		data.PersonName = person.Name
+		data.PersonPosition = person.Position
+		data.PersonUnit = person.Unit
+	}
+
+	if pp, ok := p.(PersonPassage); ok && person.ID != 0 {
+		err = pp.OpenPassageFor(person)
+	} else {
+		err = p.OpenPassage()
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, "open passage: "+err.Error())
+	}
+
+	openTime := time.Now()
+
+	log := s.log.WithFields(logrus.Fields{
+		"passage_id": passageID,
+		"login":      data.OperatorLogin,
+		"reason":     data.Reason,
+		"person_id":  data.PersonID,
+	})
+
+	log.Info("passage opened manually")
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("JSON marshal passage open data: %w", err)
+	}
+
+	err = s.dbStorage.AddEvent(entity.Event{
+		Time:      openTime,
+		PassageID: passageID,
+		Type:      entity.PassageOpen,
+		Data:      dataJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("dbStorage.AddEvent: %w", err)
+	}
+
+	if person.ID != 0 {
+		s.recordPersonPass(log, passageID, person, openTime)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// recordPersonPass keeps anti-passback state and visitor entries of person let
+// in manually, like recognized face handler does.
+func (s *Server) recordPersonPass(log *logrus.Entry, passageID string, p entity.Person, t time.Time) {
+	direction := s.passageDirections[passageID]
+
+	if zone, enforced := s.passbackZones[passageID]; enforced {
+		err := s.dbStorage.SetPassbackState(entity.PassbackState{
+			PersonID:  p.ID,
+			Zone:      zone,
+			Direction: direction,
+			PassageID: passageID,
+			Time:      t,
+		})
+		if err != nil {
+			log.WithError(err).Error("failed to set passback state in DB storage")
+		}
+	}
+
+	if direction != entity.In {
+		return
+	}
+
+	_, err := s.dbStorage.Visitor(p.ID)
+	if err != nil {
+		if err != entity.ErrVisitorNotFound {
+			log.WithError(err).Error("failed to get visitor from DB storage")
+		}
+		return
+	}
+
+	err = s.dbStorage.AddVisitorEntry(p.ID)
+	if err != nil {
+		log.WithError(err).Error("failed to add visitor entry to DB storage")
+	}
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/report"
+)
+
+const defaultWorkStart = 9 * time.Hour
+
+// getAPIReportsAttendance returns per day attendance of persons. Dates are
+// inclusive YYYY-MM-DD in tz time zone (server's local by default),
+// work_start is HH:MM after which first entry is late.
+func (s *Server) getAPIReportsAttendance(c echo.Context) error {
+	loc := time.Local
+
+	if tz := c.QueryParam("tz"); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid tz")
+		}
+	}
+
+	from, to, err := report.ParseDateRange(c.QueryParam("from"), c.QueryParam("to"), loc)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date range")
+	}
+
+	workStart := defaultWorkStart
+
+	if ws := c.QueryParam("work_start"); ws != "" {
+		t, err := time.Parse("15:04", ws)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid work_start")
+		}
+		workStart = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	eventsFilters := []entity.EventsFilter{
+		entity.EventsType(entity.PassageOpen),
+		entity.EventsFrom(from),
+		entity.EventsTo(to.Add(-time.Microsecond)),
+		entity.EventsOrderBy("time"),
+		entity.EventsOrderDirection("asc"),
+	}
+
+	if personIDStr := c.QueryParam("person_id"); personIDStr != "" {
+		personID, err := strconv.ParseInt(personIDStr, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid person_id")
+		}
+		eventsFilters = append(eventsFilters, entity.EventsPersonID(personID))
+	}
+
+	if unit := c.QueryParam("person_unit"); unit != "" {
+		eventsFilters = append(eventsFilters, entity.EventsPersonUnit(unit))
+	}
+
+	ab := report.NewAttendanceBuilder(loc, workStart, s.passageDirections)
+
+	err = s.dbStorage.EachEvent(ab.Add, eventsFilters...)
+	if err != nil {
+		if tErr, ok := err.(entity.InvalidParamErr); ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+strcase.ToSnake(tErr.Param))
+		}
+		return fmt.Errorf("dbStorage.EachEvent: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, ab.Days())
+}
@@ -37,7 +37,55 @@ type DBStorage interface {
 	AddPersonFace(entity.PersonFace) (entity.PersonFace, error)
 	RemovePersonFace(personFaceID int64) error
 
-	Events(...entity.EventsFilter) ([]entity.Event, error)
+	Credential(ct entity.CredentialType, value string) (entity.Credential, error)
+	PersonCredentials(personID int64) ([]entity.Credential, error)
+	AddCredential(entity.Credential) (entity.Credential, error)
+	RemoveCredential(credentialID int64) error
+
+	Visitor(personID int64) (entity.Visitor, error)
+	Visitors() ([]entity.Visitor, error)
+	AddVisitor(entity.Visitor) (entity.Visitor, error)
+	SetVisitor(entity.Visitor) error
+	AddVisitorEntry(personID int64) error
+
+	FaceCluster(faceClusterID int64) (entity.FaceCluster, error)
+	FaceClusters(minCount int) ([]entity.FaceCluster, error)
+	FaceClusterFaces(faceClusterID int64) ([]entity.FaceClusterFace, error)
+	AddFaceClusterPerson(faceClusterID int64, p entity.Person) (entity.Person, error)
+	RemoveFaceCluster(faceClusterID int64) error
+
+	PassbackStates(personID int64) ([]entity.PassbackState, error)
+	SetPassbackState(entity.PassbackState) error
+	ResetPassbackStates(personID int64, zone string) error
+
+	AccessGroup(accessGroupID int64) (entity.AccessGroup, error)
+	AccessGroups() ([]entity.AccessGroup, error)
+	AddAccessGroup(entity.AccessGroup) (entity.AccessGroup, error)
+	SetAccessGroup(entity.AccessGroup) error
+	RemoveAccessGroup(accessGroupID int64) error
+
+	Holidays() ([]entity.Holiday, error)
+	SetHoliday(entity.Holiday) error
+	RemoveHoliday(date string) error
+
+	Alerts(limit int, statuses ...entity.AlertStatus) ([]entity.Alert, error)
+	AcknowledgeAlert(alertID int64, login string) (entity.Alert, error)
+	ResolveAlert(alertID int64, login string, comment string) (entity.Alert, error)
+
+	AddEvent(entity.Event) error
+	EventsPage(...entity.EventsFilter) (entity.EventsPage, error)
+	EachEvent(fn func(entity.Event) error, fs ...entity.EventsFilter) error
+}
+
+type Passage interface {
+	OpenPassage() error
+	Status() entity.PassageStatus
+}
+
+// PersonPassage is implemented by passages which open depending on person let
+// in.
+type PersonPassage interface {
+	OpenPassageFor(p entity.Person) error
 }
 
 type PhotoStorage interface {
@@ -66,12 +114,18 @@ type Server struct {
 	jwtSigningKey         string
 	detectConfidenseLimit float64
 	passageNames          map[string]string
+	passageDirections     map[string]entity.Direction
+	passbackZones         map[string]string
+	passages              map[string]Passage
 
 	dbStorage      DBStorage
 	photoStorage   PhotoStorage
 	faceDetector   FaceDetector
 	faceRecognizer FaceRecognizer
 
+	alertSubscribers   map[chan entity.Alert]struct{}
+	alertSubscribersMx sync.Mutex
+
 	echo *echo.Echo
 
 	log  *logrus.Entry
@@ -80,17 +134,22 @@ type Server struct {
 }
 
 func NewServer(bindAddr, jwtSigningKey, tlsCrtFilePath, tlsKeyFilePath string, detectConfidenseLimit float64,
-	passageNames map[string]string, debug bool,
+	passageNames map[string]string, passageDirections map[string]entity.Direction, passbackZones map[string]string,
+	passages map[string]Passage, debug bool,
 	dbs DBStorage, ps PhotoStorage, fd FaceDetector, fr FaceRecognizer) (*Server, error) {
 
 	s := &Server{
 		jwtSigningKey:         jwtSigningKey,
 		detectConfidenseLimit: detectConfidenseLimit,
 		passageNames:          passageNames,
+		passageDirections:     passageDirections,
+		passbackZones:         passbackZones,
+		passages:              passages,
 		dbStorage:             dbs,
 		photoStorage:          ps,
 		faceDetector:          fd,
 		faceRecognizer:        fr,
+		alertSubscribers:      map[chan entity.Alert]struct{}{},
 		log:                   logrus.WithField("subsystem", "web_server"),
 	}
 
@@ -187,12 +246,51 @@ func NewServer(bindAddr, jwtSigningKey, tlsCrtFilePath, tlsKeyFilePath string, d
 	aa.DELETE("/persons/:person_id", s.deleteAPIPerson, adminWithSecurity)
 	aa.GET("/persons/:person_id/faces", s.getAPIPersonFaces, adminWithSecurity)
 	aa.POST("/persons/:person_id/faces", s.postAPIPersonFaces, adminWithSecurity)
+	aa.GET("/persons/:person_id/passback", s.getAPIPersonPassback, adminWithSecurity)
+	aa.DELETE("/persons/:person_id/passback", s.deleteAPIPersonPassback, adminWithSecurity)
+
+	aa.GET("/persons/:person_id/credentials", s.getAPIPersonCredentials, adminWithSecurity)
+	aa.POST("/persons/:person_id/credentials", s.postAPIPersonCredentials, adminWithSecurity)
 
 	aa.DELETE("/person_faces/:person_face_id", s.deleteAPIPersonFace, adminWithSecurity)
 
+	aa.DELETE("/credentials/:credential_id", s.deleteAPICredential, adminWithSecurity)
+
+	aa.GET("/visitors", s.getAPIVisitors, adminWithSecurity)
+	aa.GET("/visitors/:person_id", s.getAPIVisitor, adminWithSecurity)
+	aa.POST("/visitors", s.postAPIVisitors, adminWithSecurity)
+	aa.PUT("/visitors", s.putAPIVisitors, adminWithSecurity)
+	aa.DELETE("/visitors/:person_id", s.deleteAPIVisitor, adminWithSecurity)
+
+	aa.GET("/face_clusters", s.getAPIFaceClusters, adminWithSecurity)
+	aa.GET("/face_clusters/:face_cluster_id", s.getAPIFaceCluster, adminWithSecurity)
+	aa.GET("/face_clusters/:face_cluster_id/faces", s.getAPIFaceClusterFaces, adminWithSecurity)
+	aa.POST("/face_clusters/:face_cluster_id/person", s.postAPIFaceClusterPerson, adminOnly)
+	aa.DELETE("/face_clusters/:face_cluster_id", s.deleteAPIFaceCluster, adminOnly)
+
+	aa.GET("/access_groups", s.getAPIAccessGroups, adminWithSecurity)
+	aa.GET("/access_groups/:access_group_id", s.getAPIAccessGroup, adminWithSecurity)
+	aa.POST("/access_groups", s.postAPIAccessGroups, adminOnly)
+	aa.PUT("/access_groups", s.putAPIAccessGroups, adminOnly)
+	aa.DELETE("/access_groups/:access_group_id", s.deleteAPIAccessGroup, adminOnly)
+
+	aa.GET("/holidays", s.getAPIHolidays, adminWithSecurity)
+	aa.PUT("/holidays", s.putAPIHolidays, adminOnly)
+	aa.DELETE("/holidays/:date", s.deleteAPIHoliday, adminOnly)
+
+	aa.GET("/alerts", s.getAPIAlerts, adminWithSecurity)
+	aa.GET("/alerts/stream", s.getAPIAlertsStream, adminWithSecurity)
+	aa.POST("/alerts/:alert_id/acknowledge", s.postAPIAlertAcknowledge, adminWithSecurity)
+	aa.POST("/alerts/:alert_id/resolve", s.postAPIAlertResolve, adminWithSecurity)
+
 	aa.GET("/passage_names", s.getAPIPassageNames, adminWithSecurity)
+	aa.GET("/passages", s.getAPIPassages, adminWithSecurity)
+	aa.POST("/passages/:passage_id/open", s.postAPIPassageOpen, adminWithSecurity)
 
 	aa.GET("/events", s.getAPIEvents, adminWithSecurity)
+	aa.GET("/events/export", s.getAPIEventsExport, adminWithSecurity)
+
+	aa.GET("/reports/attendance", s.getAPIReportsAttendance, adminWithSecurity)
 
 	e.GET("/", func(c echo.Context) error {
 		return c.HTML(http.StatusOK, indexHTML)
@@ -0,0 +1,112 @@
+import React, { useEffect } from 'react'
+import { connect } from 'react-redux'
+import { Button, Col, Container, Row, Table } from 'react-bootstrap'
+import {
+    loadAlerts,
+    subscribeAlerts,
+    unsubscribeAlerts,
+    acknowledgeAlert,
+    resolveAlert
+} from '../../reducers/alerts'
+import { photosURL } from '../../reducers/skuder'
+import { formatDateTime } from '../../utils'
+import './index.css'
+
+const alertStatusNames = {
+    active: 'Новая',
+    acknowledged: 'В работе',
+    resolved: 'Закрыта'
+}
+
+const Alerts = (props) => {
+
+    const { onLoad, onUnload } = props
+
+    useEffect(() => {
+        onLoad()
+        return onUnload
+    }, [onLoad, onUnload])
+
+    if (!props.alerts) {
+        return ''
+    }
+
+    return (
+        <Container fluid>
+            <Row>
+                <Col md='12'>
+                    <Table size='sm' striped bordered>
+                        <thead>
+                            <tr>
+                                <th>Время</th>
+                                <th>Проход</th>
+                                <th>Персона</th>
+                                <th>Стоп-лист</th>
+                                <th>Фото</th>
+                                <th>Статус</th>
+                                <th className='manage-col'>Управление</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            {props.alerts.map(a =>
+                                <tr key={a.id} className={'alert-' + a.status}>
+                                    <td>{formatDateTime(new Date(a.time))}</td>
+                                    <td>{props.passageNames[a.passage_id] || a.passage_id}</td>
+                                    <td>{a.data.person_name}</td>
+                                    <td>{a.data.watchlist}</td>
+                                    <td>
+                                        <img className='alert-photo' src={`${photosURL}/${a.data.photo_id}`} alt={a.data.photo_id}/>
+                                    </td>
+                                    <td>
+                                        {alertStatusNames[a.status]}
+                                        {a.acknowledged_by ? ` (${a.acknowledged_by})` : ''}
+                                    </td>
+                                    <td className='manage-col'>
+                                        {a.status === 'active'
+                                            ? <Button size='sm' variant='primary' onClick={() => props.onAcknowledge(a.id)}>Принять</Button>
+                                            : ''
+                                        }
+                                        <Button size='sm' variant='success' onClick={() => {
+                                            const comment = window.prompt('Комментарий')
+                                            if (comment !== null) {
+                                                props.onResolve(a.id, comment)
+                                            }
+                                        }}>Закрыть</Button>
+                                    </td>
+                                </tr>
+                            )}
+                        </tbody>
+                    </Table>
+                </Col>
+            </Row>
+        </Container>
+    )
+}
+
+const mapStateToProps = state => ({
+    alerts: state.alerts.alerts,
+    passageNames: state.events.passageNames
+})
+
+const mapDispatchToProps = dispatch => {
+    return {
+        onLoad: () => {
+            dispatch(loadAlerts())
+            dispatch(subscribeAlerts())
+        },
+        onUnload: () => {
+            dispatch(unsubscribeAlerts())
+        },
+        onAcknowledge: (alertID) => {
+            dispatch(acknowledgeAlert(alertID))
+        },
+        onResolve: (alertID, comment) => {
+            dispatch(resolveAlert(alertID, comment))
+        }
+    }
+}
+
+export default connect(
+    mapStateToProps,
+    mapDispatchToProps
+)(Alerts)
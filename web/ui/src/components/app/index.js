@@ -9,6 +9,7 @@ import { logout, ADMIN, SECURITY } from '../../reducers/skuder'
 import Users from '../users'
 import Persons from '../persons'
 import Events from '../events'
+import Alerts from '../alerts'
 import NotFound from './not-found'
 import 'semantic-ui-css/semantic.min.css'
 import { 
@@ -62,6 +63,13 @@ const pages = [
         </LinkContainer>,
         route: <Route exact path='/events' component={Events} key='events'/>,
         roles: [ADMIN, SECURITY]
+    },
+    {
+        menu: <LinkContainer to='/alerts' key='alerts'>
+            <Nav.Link>Тревоги</Nav.Link>
+        </LinkContainer>,
+        route: <Route exact path='/alerts' component={Alerts} key='alerts'/>,
+        roles: [ADMIN, SECURITY]
     }
 ]
 
@@ -104,6 +112,10 @@ const App = ({ user, onLogout }) => {
                     <Icon name='camera' />
                     События
                 </Menu.Item>
+                <Menu.Item as={Link} to='/alerts' >
+                    <Icon name='warning sign' />
+                    Тревоги
+                </Menu.Item>
             </Sidebar>
         )
 
@@ -114,6 +126,7 @@ const App = ({ user, onLogout }) => {
                 <Route path="/users" component={Users} />
                 <Route path="/persons" component={Persons} />
                 <Route path="/events" component={Events} />
+                <Route path="/alerts" component={Alerts} />
             </div>
         )
     } else {
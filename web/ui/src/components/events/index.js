@@ -25,7 +25,8 @@ import { photosURL } from '../../reducers/skuder'
 const eventTypeNames = {
     face_recognize: 'Лицо распознано',
     person_recognize: 'Персона распознана',
-    passage_open: 'Открытие прохода'
+    passage_open: 'Открытие прохода',
+    watchlist_hit: 'Персона из стоп-листа'
 }
 
 const Event = ({ type, passageNames, data }) => {
@@ -53,6 +54,15 @@ const Event = ({ type, passageNames, data }) => {
                     <div className="event-info-row">Имя персоны: {data.person_name}</div>
                 </div>
             </div>
+        case 'watchlist_hit':
+            return <div className="event-info">
+                <img className="event-info-photo" src={`${photosURL}/${data.photo_id}`} alt={data.photo_id}/>
+                <div className="event-info-data">
+                    <div className="event-info-row">Имя персоны: {data.person_name}</div>
+                    <div className="event-info-row">Стоп-лист: {data.watchlist}</div>
+                    <div className="event-info-row">Название прохода: {passageNames[data.passage_id] || data.passage_id}</div>
+                </div>
+            </div>
         default:
             return <code>{JSON.stringify(data, null, 2)}</code>
     }
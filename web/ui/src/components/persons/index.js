@@ -17,6 +17,7 @@ import {
     setNewPersonPosition,
     setNewPersonUnit,
     setEditPersonUnit,
+    setEditPersonWatchlist,
     addNewPersonPhoto,
     removeNewPersonPhoto,
     uploadNewPersonPhotos,
@@ -186,6 +187,15 @@ const EditPersonModal = (props) => {
                             props.onEditPersonUnitChange(e.currentTarget.value)
                         }}/>
                     </Form.Group>
+                    <Form.Group controlId='edit-person-watchlist'>
+                        <Form.Label>Стоп-лист</Form.Label>
+                        <Form.Control autoComplete='new-password' placeholder='Категория стоп-листа' value={props.editPerson.watchlist || ''} onChange={(e) => {
+                            props.onEditPersonWatchlistChange(e.currentTarget.value)
+                        }}/>
+                        <Form.Text className='text-muted'>
+                            Для персоны из стоп-листа проход не открывается, а охране отправляется тревога.
+                        </Form.Text>
+                    </Form.Group>
                     <Form.Group>
                         <Form.Label>Фотографии</Form.Label>
                         <div className='photos'>
@@ -271,6 +281,7 @@ const Persons = ({ loadPersons, ...props }) => {
                                 <th>Имя</th>
                                 <th>Тип досье</th>
                                 <th>Подразделение</th>
+                                <th>Стоп-лист</th>
                                 <th className='manage-col'>Управление</th>
                             </tr>
                         </thead>
@@ -281,6 +292,7 @@ const Persons = ({ loadPersons, ...props }) => {
                                     <td>{p.name}</td>
                                     <td>{p.position}</td>
                                     <td>{p.unit}</td>
+                                    <td>{p.watchlist}</td>
                                     <td className='manage-col'>
                                         <Button size='sm' variant='primary' onClick={() => props.onEditPerson(p)}>Редактировать</Button>
                                         <Button size='sm' variant='danger' onClick={() => props.onRemovePerson(p.id)}>Удалить</Button>
@@ -351,6 +363,9 @@ const mapDispatchToProps = dispatch => {
         onEditPersonUnitChange: (unit) => {
             dispatch(setEditPersonUnit(unit))
         },
+        onEditPersonWatchlistChange: (watchlist) => {
+            dispatch(setEditPersonWatchlist(watchlist))
+        },
         onEditPersonFaceRemove: (personFaceID) => {
             dispatch(setEditPersonFaceToRemove(personFaceID))
         },
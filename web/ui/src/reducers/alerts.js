@@ -0,0 +1,87 @@
+import * as axios from 'axios'
+import { handleAuthError, alertsURL } from './skuder'
+
+const SET_ALERTS = 'alerts/SET_ALERTS'
+const UPDATE_ALERT = 'alerts/UPDATE_ALERT'
+
+const initialState = {
+    alerts: null,
+}
+
+export default (state = initialState, action) => {
+    switch (action.type) {
+
+        case SET_ALERTS:
+            return {
+                ...state,
+                alerts: action.alerts
+            }
+
+        case UPDATE_ALERT: {
+            const alerts = (state.alerts || []).filter(a => a.id !== action.alert.id)
+            if (action.alert.status !== 'resolved') {
+                alerts.unshift(action.alert)
+            }
+            return {
+                ...state,
+                alerts
+            }
+        }
+
+        default:
+            return state
+    }
+}
+
+export const loadAlerts = () => {
+    return (dispatch, getState) => {
+        axios.get(`${alertsURL}?status=active&status=acknowledged`).then(res => {
+            dispatch({ type: SET_ALERTS, alerts: res.data })
+        }).catch(err => {
+            handleAuthError(dispatch, getState, err)
+        })
+    }
+}
+
+let alertsStream = null
+
+export const subscribeAlerts = () => {
+    return dispatch => {
+        if (alertsStream) {
+            return
+        }
+        alertsStream = new EventSource(`${alertsURL}/stream`, { withCredentials: true })
+        alertsStream.addEventListener('alert', e => {
+            dispatch({ type: UPDATE_ALERT, alert: JSON.parse(e.data) })
+        })
+    }
+}
+
+export const unsubscribeAlerts = () => {
+    return () => {
+        if (alertsStream) {
+            alertsStream.close()
+            alertsStream = null
+        }
+    }
+}
+
+export const acknowledgeAlert = (alertID) => {
+    return (dispatch, getState) => {
+        axios.post(`${alertsURL}/${alertID}/acknowledge`).then(res => {
+            dispatch({ type: UPDATE_ALERT, alert: res.data })
+        }).catch(err => {
+            handleAuthError(dispatch, getState, err)
+        })
+    }
+}
+
+export const resolveAlert = (alertID, comment) => {
+    return (dispatch, getState) => {
+        axios.post(`${alertsURL}/${alertID}/resolve`, { comment }).then(res => {
+            dispatch({ type: UPDATE_ALERT, alert: res.data })
+        }).catch(err => {
+            handleAuthError(dispatch, getState, err)
+        })
+    }
+}
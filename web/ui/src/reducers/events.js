@@ -93,7 +93,7 @@ const _loadEvents = (dispatch, getState) => {
             offset: (state.events.page-1)*state.events.recordsPerPages,
         }
     }).then(res => {
-        dispatch({ type: SET_EVENTS, events: res.data })
+        dispatch({ type: SET_EVENTS, events: res.data.events })
     }).catch(err => {
         handleAuthError(dispatch, getState, err)
     })
@@ -6,6 +6,7 @@ import login from './login'
 import users from './users'
 import persons from './persons'
 import events from './events'
+import alerts from './alerts'
 
 export const history = createBrowserHistory()
 
@@ -15,5 +16,6 @@ export default combineReducers({
     login,
     users,
     persons,
-    events
+    events,
+    alerts
 })
\ No newline at end of file
@@ -21,6 +21,7 @@ const CLEAR_EDIT_PERSON = 'persons/CLEAR_EDIT_PERSON'
 const SET_EDIT_PERSON_NAME = 'persons/SET_EDIT_PERSON_NAME'
 const SET_EDIT_PERSON_POSITION = 'persons/SET_EDIT_PERSON_POSITION'
 const SET_EDIT_PERSON_UNIT = 'persons/SET_EDIT_PERSON_UNIT'
+const SET_EDIT_PERSON_WATCHLIST = 'persons/SET_EDIT_PERSON_WATCHLIST'
 const SET_EDIT_PERSON_FACE_TO_REMOVE = 'persons/SET_EDIT_PERSON_FACE_TO_REMOVE'
 const RESTORE_EDIT_PERSON_FACE = 'persons/RESTORE_EDIT_PERSON_FACE'
 const ADD_EDIT_PERSON_FACE = 'persons/ADD_EDIT_PERSON_FACE'
@@ -201,6 +202,15 @@ export default (state = initialState, action) => {
                 }
             }
 
+        case SET_EDIT_PERSON_WATCHLIST:
+            return {
+                ...state,
+                editPerson: {
+                    ...state.editPerson,
+                    watchlist: action.watchlist
+                }
+            }
+
         case SET_EDIT_PERSON_FACE_TO_REMOVE:
             return {
                 ...state,
@@ -625,6 +635,11 @@ export const setEditPersonUnit = (unit) => ({
     unit
 })
 
+export const setEditPersonWatchlist = (watchlist) => ({
+    type: SET_EDIT_PERSON_WATCHLIST,
+    watchlist
+})
+
 export const setEditPersonFaceToRemove = (personFaceID) => ({
     type: SET_EDIT_PERSON_FACE_TO_REMOVE,
     personFaceID,
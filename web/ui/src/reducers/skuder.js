@@ -20,6 +20,7 @@ export const photosURL = `${apiBaseURL}/photos`
 export const personFacesURL = `${apiBaseURL}/person_faces`
 export const eventsURL = `${apiBaseURL}/events`
 export const passageNamesURL = `${apiBaseURL}/passage_names`
+export const alertsURL = `${apiBaseURL}/alerts`
 
 const LOGIN = 'skuder/LOGIN'
 const LOGOUT = 'skuder/LOGOUT'
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+func (s *Server) getAPIVisitors(c echo.Context) error {
+	vs, err := s.dbStorage.Visitors()
+	if err != nil {
+		return fmt.Errorf("dbStorage.Visitors: %w", err)
+	}
+	if vs == nil {
+		vs = []entity.Visitor{}
+	}
+	return c.JSON(http.StatusOK, vs)
+}
+
+func (s *Server) getAPIVisitor(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	v, err := s.dbStorage.Visitor(personID)
+	if err != nil {
+		if err == entity.ErrVisitorNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.Visitor: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+func (s *Server) postAPIVisitors(c echo.Context) error {
+	v, err := s.bindVisitor(c)
+	if err != nil {
+		return err
+	}
+
+	v, err = s.dbStorage.AddVisitor(v)
+	if err != nil {
+		return fmt.Errorf("dbStorage.AddVisitor: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+func (s *Server) putAPIVisitors(c echo.Context) error {
+	v, err := s.bindVisitor(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.dbStorage.SetVisitor(v)
+	if err != nil {
+		if err == entity.ErrVisitorNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.SetVisitor: %w", err)
+	}
+
+	// Updated visitor tells with faces_removed whether faces must be enrolled
+	// again.
+	v, err = s.dbStorage.Visitor(v.PersonID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.Visitor: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+// deleteAPIVisitor removes visitor person along with the pass and faces.
+func (s *Server) deleteAPIVisitor(c echo.Context) error {
+	personID, err := strconv.ParseInt(c.Param("person_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "parse person_id: "+err.Error())
+	}
+
+	_, err = s.dbStorage.Visitor(personID)
+	if err != nil {
+		if err == entity.ErrVisitorNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		}
+		return fmt.Errorf("dbStorage.Visitor: %w", err)
+	}
+
+	err = s.dbStorage.RemovePerson(personID)
+	if err != nil {
+		return fmt.Errorf("dbStorage.RemovePerson: %w", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) bindVisitor(c echo.Context) (entity.Visitor, error) {
+	var v entity.Visitor
+
+	err := c.Bind(&v)
+	if err != nil {
+		return v, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("bind visitor: %w", err))
+	}
+
+	v.Name = strings.TrimSpace(v.Name)
+	v.Purpose = strings.TrimSpace(v.Purpose)
+
+	err = v.Validate()
+	if err != nil {
+		return v, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	for _, passageID := range v.PassageIDs {
+		if _, exists := s.passageDirections[passageID]; !exists {
+			return v, echo.NewHTTPError(http.StatusBadRequest, "unknown passage "+passageID)
+		}
+	}
+
+	_, err = s.dbStorage.Person(*v.HostPersonID)
+	if err != nil {
+		if err == entity.ErrPersonNotFound {
+			return v, echo.NewHTTPError(http.StatusBadRequest, "host person not found")
+		}
+		return v, fmt.Errorf("dbStorage.Person: %w", err)
+	}
+
+	return v, nil
+}
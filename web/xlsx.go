@@ -0,0 +1,140 @@
+package web
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// xlsxWriter writes single sheet XLSX workbook with inline string cells row
+// by row, so that rows are never held in memory.
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+const (
+	xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+
+	xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+
+	xlsxSheetHeader = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+)
+
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+
+	var escapedSheetName bytes.Buffer
+	err := xml.EscapeText(&escapedSheetName, []byte(sheetName))
+	if err != nil {
+		return nil, fmt.Errorf("escape sheet name: %w", err)
+	}
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escapedSheetName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+
+	for _, p := range parts {
+		pw, err := zw.Create(p.name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", p.name, err)
+		}
+		_, err = io.WriteString(pw, p.content)
+		if err != nil {
+			return nil, fmt.Errorf("write %s: %w", p.name, err)
+		}
+	}
+
+	sw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("create sheet: %w", err)
+	}
+
+	xw := &xlsxWriter{
+		zw:    zw,
+		sheet: bufio.NewWriter(sw),
+	}
+
+	_, err = xw.sheet.WriteString(xlsxSheetHeader)
+	if err != nil {
+		return nil, fmt.Errorf("write sheet header: %w", err)
+	}
+
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(cells []string) error {
+	xw.rows++
+
+	row := strconv.Itoa(xw.rows)
+
+	xw.sheet.WriteString(`<row r="` + row + `">`)
+
+	for i, cell := range cells {
+		xw.sheet.WriteString(`<c r="` + xlsxColumn(i) + row + `" t="inlineStr"><is><t xml:space="preserve">`)
+		err := xml.EscapeText(xw.sheet, []byte(cell))
+		if err != nil {
+			return fmt.Errorf("escape cell: %w", err)
+		}
+		xw.sheet.WriteString(`</t></is></c>`)
+	}
+
+	_, err := xw.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+// Flush flushes buffered rows to the zip stream. Zip writer itself
+// compresses and writes data as it comes.
+func (xw *xlsxWriter) Flush() error {
+	return xw.sheet.Flush()
+}
+
+func (xw *xlsxWriter) Close() error {
+	_, err := xw.sheet.WriteString(xlsxSheetFooter)
+	if err != nil {
+		return fmt.Errorf("write sheet footer: %w", err)
+	}
+
+	err = xw.sheet.Flush()
+	if err != nil {
+		return fmt.Errorf("flush sheet: %w", err)
+	}
+
+	return xw.zw.Close()
+}
+
+// xlsxColumn returns spreadsheet column name for zero based index: A, B, ...,
+// Z, AA, AB and so on.
+func xlsxColumn(i int) string {
+	var name []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = append([]byte{byte('A' + (i-1)%26)}, name...)
+	}
+	return string(name)
+}
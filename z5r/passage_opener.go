@@ -1,61 +1,317 @@
 package z5r
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
 )
 
-type PassageOpener struct {
-	BaseURI   string
-	Direction entity.Direction
-	lastOpen  time.Time
+func init() {
+	passage.Register(entity.Z5R, newPassageOpener)
+	passage.RegisterCredentialsReporter(entity.Z5R, reportsCredentials)
 }
 
-func NewPassageOpener(baseURI string, direction entity.Direction) *PassageOpener {
-	return &PassageOpener{
-		BaseURI:   baseURI,
-		Direction: direction,
+const (
+	defaultInterval       = 1 * time.Second
+	defaultCommandTimeout = 5 * time.Second
+	shutdownTimeout       = 5 * time.Second
+
+	eventsBufferSize          = 16
+	credentialReadsBufferSize = 16
+)
+
+type options struct {
+	SN             int    `yaml:"sn"`
+	Username       string `yaml:"username"`
+	Password       string `yaml:"password"`
+	Interval       string `yaml:"interval"`
+	CommandTimeout string `yaml:"command_timeout"`
+}
+
+// reportsCredentials returns true, controller always reports its events.
+func reportsCredentials(passage.Config) bool {
+	return true
+}
+
+func parseDuration(name, s string, d time.Duration) (time.Duration, error) {
+	if s == "" {
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s parse: %w", name, err)
 	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("%s is invalid", name)
+	}
+
+	return d, nil
 }
 
-const (
-	openAPIPath = `/cgi-bin/command`
+// newPassageOpener adds controller to server listening at config address,
+// passages of the same controller share server and controller.
+func newPassageOpener(c passage.Config) (passage.Opener, error) {
+	var o options
+
+	err := c.DecodeOptions(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.SN <= 0 {
+		return nil, errors.New("sn is invalid")
+	}
+
+	cc := ControllerConfig{
+		SN:       o.SN,
+		Username: o.Username,
+		Password: o.Password,
+	}
+
+	cc.Interval, err = parseDuration("interval", o.Interval, defaultInterval)
+	if err != nil {
+		return nil, err
+	}
 
-	openBodyPrefix = `DIR=`
+	if cc.Interval%time.Second != 0 {
+		return nil, errors.New("interval must be whole seconds")
+	}
 
-	inDirectionNum  = "0"
-	outDirectionNum = "1"
+	cc.CommandTimeout, err = parseDuration("command_timeout", o.CommandTimeout, defaultCommandTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if cc.CommandTimeout <= 2*cc.Interval {
+		return nil, errors.New("command_timeout must exceed two intervals")
+	}
+
+	controller, release, err := addController(c.Address, cc)
+	if err != nil {
+		return nil, err
+	}
+
+	po := NewPassageOpener(controller, c.Direction)
+	po.release = release
+
+	return po, nil
+}
+
+// listener serves controllers requests at bind address.
+type listener struct {
+	server     *Server
+	httpServer *http.Server
+	wg         sync.WaitGroup
+}
+
+var (
+	listeners   = map[string]*listener{}
+	listenersMx sync.Mutex
 )
 
-func (po *PassageOpener) OpenPassage() error {
-	var directionNum string
+// addController adds controller to server listening at bind address, server
+// is started for the first controller. Returned release function removes
+// controller and stops server after the last controller.
+func addController(bindAddr string, cc ControllerConfig) (*Controller, func(), error) {
+	listenersMx.Lock()
+	defer listenersMx.Unlock()
+
+	l, exists := listeners[bindAddr]
+	if !exists {
+		nl, err := net.Listen("tcp", bindAddr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listen: %w", err)
+		}
+
+		l = &listener{server: NewServer()}
+		l.httpServer = &http.Server{Handler: l.server}
 
-	if po.Direction == entity.In {
-		directionNum = inDirectionNum
-	} else {
-		directionNum = outDirectionNum
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+
+			err := l.httpServer.Serve(nl)
+			if err != nil && err != http.ErrServerClosed {
+				l.server.log.WithError(err).Error("failed to serve controllers")
+			}
+		}()
+
+		listeners[bindAddr] = l
 	}
 
-	res, err := http.Post(po.BaseURI+openAPIPath, "text/plain",
-		strings.NewReader(openBodyPrefix+directionNum))
+	return l.server.AddController(cc), func() { removeController(bindAddr, cc.SN) }, nil
+}
+
+func removeController(bindAddr string, sn int) {
+	listenersMx.Lock()
+	defer listenersMx.Unlock()
+
+	l := listeners[bindAddr]
+
+	if !l.server.RemoveController(sn) {
+		return
+	}
+
+	delete(listeners, bindAddr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := l.httpServer.Shutdown(ctx)
 	if err != nil {
-		return fmt.Errorf("HTTP post: %w", err)
+		l.server.log.WithError(err).Error("failed to shutdown server")
 	}
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("expected 200 status code but got %d",
-			res.StatusCode)
+	l.wg.Wait()
+}
+
+// PassageOpener opens Z5R-Web controller door in its direction and reports
+// cards read by reader of its direction as credential reads. Events logged
+// before opener is created are skipped.
+type PassageOpener struct {
+	controller *Controller
+	direction  entity.Direction
+	started    time.Time
+
+	lastOpen   time.Time
+	lastOpenMx sync.Mutex
+
+	events          <-chan Event
+	credentialReads chan entity.CredentialRead
+	release         func()
+
+	log  *logrus.Entry
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewPassageOpener(c *Controller, direction entity.Direction) *PassageOpener {
+	po := &PassageOpener{
+		controller:      c,
+		direction:       direction,
+		started:         time.Now().Truncate(time.Second),
+		events:          c.Subscribe(eventsBufferSize),
+		credentialReads: make(chan entity.CredentialRead, credentialReadsBufferSize),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": "z5r_passage_opener",
+			"sn":        c.config.SN,
+		}),
+		stop: make(chan struct{}),
 	}
 
+	po.wg.Add(1)
+	go po.readEvents()
+
+	return po
+}
+
+// Close stops reading events.
+func (po *PassageOpener) Close() error {
+	close(po.stop)
+	po.controller.Unsubscribe(po.events)
+	po.wg.Wait()
+
+	if po.release != nil {
+		po.release()
+	}
+
+	return nil
+}
+
+// Controller returns controller, which is used to manage card list.
+func (po *PassageOpener) Controller() *Controller {
+	return po.controller
+}
+
+func (po *PassageOpener) OpenPassage() error {
+	err := po.controller.OpenDoor(po.direction)
+	if err != nil {
+		return err
+	}
+
+	po.lastOpenMx.Lock()
 	po.lastOpen = time.Now()
+	po.lastOpenMx.Unlock()
 
 	return nil
 }
 
 func (po *PassageOpener) LastOpenTime() time.Time {
+	po.lastOpenMx.Lock()
+	defer po.lastOpenMx.Unlock()
 	return po.lastOpen
 }
+
+func (po *PassageOpener) Ping() error {
+	return po.controller.Ping()
+}
+
+// CredentialReads returns card reads.
+func (po *PassageOpener) CredentialReads() <-chan entity.CredentialRead {
+	return po.credentialReads
+}
+
+func (po *PassageOpener) readEvents() {
+	defer po.wg.Done()
+	defer close(po.credentialReads)
+
+	for e := range po.events {
+		r, ok := po.credentialRead(e)
+		if !ok || r.Time.Before(po.started) {
+			continue
+		}
+
+		select {
+		case po.credentialReads <- r:
+		case <-po.stop:
+			return
+		}
+	}
+}
+
+// credentialRead converts controller event to card read if it is card event
+// of opener direction reader.
+func (po *PassageOpener) credentialRead(e Event) (entity.CredentialRead, bool) {
+	switch e.Event {
+	case CardNotFoundInEvent, CardNotFoundOutEvent, CardFoundInEvent, CardFoundOutEvent,
+		CardDeniedInEvent, CardDeniedOutEvent:
+	default:
+		return entity.CredentialRead{}, false
+	}
+
+	if e.Card == "" {
+		return entity.CredentialRead{}, false
+	}
+
+	direction := entity.In
+	if e.Event%2 == 1 {
+		direction = entity.Out
+	}
+
+	if direction != po.direction {
+		return entity.CredentialRead{}, false
+	}
+
+	t, err := time.ParseInLocation(TimeLayout, e.Time, time.Local)
+	if err != nil {
+		po.log.WithError(err).WithField("event_time", e.Time).Warn("failed to parse event time")
+		t = time.Now()
+	}
+
+	return entity.CredentialRead{
+		Type:  entity.Card,
+		Value: e.Card,
+		Time:  t,
+	}, true
+}
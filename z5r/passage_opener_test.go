@@ -0,0 +1,364 @@
+package z5r_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bennyharvey/soma/entity"
+	"github.com/bennyharvey/soma/passage"
+	"github.com/bennyharvey/soma/z5r"
+	"github.com/bennyharvey/soma/z5r/z5rfake"
+)
+
+const (
+	testSN            = 42
+	testRequestPeriod = 10 * time.Millisecond
+)
+
+// waitTimeout is how long waitFor waits. It is generous for slow and loaded
+// machines, passing tests don't wait for it.
+const waitTimeout = 10 * time.Second
+
+// waitFor calls f until it returns true or waitTimeout passes.
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testControllerConfig() z5r.ControllerConfig {
+	return z5r.ControllerConfig{
+		SN:             testSN,
+		Username:       "user",
+		Password:       "pass",
+		Interval:       time.Second,
+		CommandTimeout: time.Second,
+	}
+}
+
+// newTestController returns controller of server and fake controller posting
+// to it.
+func newTestController(t *testing.T, cc z5r.ControllerConfig) (*z5r.Controller, *z5rfake.Controller) {
+	t.Helper()
+
+	s := z5r.NewServer()
+	c := s.AddController(cc)
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	return c, z5rfake.NewController(ts.URL, cc.SN, cc.Username, cc.Password)
+}
+
+func startFake(t *testing.T, fc *z5rfake.Controller) {
+	t.Helper()
+
+	fc.Start(testRequestPeriod)
+	t.Cleanup(fc.Stop)
+}
+
+func TestPassageOpenerOpenPassage(t *testing.T) {
+	c, fc := newTestController(t, testControllerConfig())
+
+	po := z5r.NewPassageOpener(c, entity.Out)
+	defer po.Close()
+
+	if !errors.Is(po.Ping(), z5r.ErrNotConnected) {
+		t.Fatal("ping succeeded before controller request")
+	}
+
+	startFake(t, fc)
+
+	err := po.OpenPassage()
+	if err != nil {
+		t.Fatalf("open passage: %v", err)
+	}
+
+	opens := fc.Opens()
+	if len(opens) != 1 || opens[0] != z5r.OutDirection {
+		t.Fatalf("got opens %v, want [%d]", opens, z5r.OutDirection)
+	}
+
+	if po.LastOpenTime().IsZero() {
+		t.Error("last open time is not set")
+	}
+
+	if !fc.Active() {
+		t.Error("controller is not activated")
+	}
+
+	err = po.Ping()
+	if err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestControllerSetMode(t *testing.T) {
+	c, fc := newTestController(t, testControllerConfig())
+	startFake(t, fc)
+
+	err := c.SetMode(z5r.BlockMode)
+	if err != nil {
+		t.Fatalf("set block mode: %v", err)
+	}
+
+	if fc.Mode() != z5r.BlockMode {
+		t.Fatalf("got mode %d, want %d", fc.Mode(), z5r.BlockMode)
+	}
+
+	err = c.OpenDoor(entity.In)
+	if err == nil {
+		t.Fatal("door opened in block mode")
+	}
+
+	err = c.SetMode(z5r.NormalMode)
+	if err != nil {
+		t.Fatalf("set normal mode: %v", err)
+	}
+
+	err = c.OpenDoor(entity.In)
+	if err != nil {
+		t.Fatalf("open door in normal mode: %v", err)
+	}
+
+	opens := fc.Opens()
+	if len(opens) != 1 || opens[0] != z5r.InDirection {
+		t.Fatalf("got opens %v, want [%d]", opens, z5r.InDirection)
+	}
+}
+
+func TestPassageOpenerCredentialReads(t *testing.T) {
+	c, fc := newTestController(t, testControllerConfig())
+
+	now := time.Now()
+
+	fc.SetEventsBatch(2)
+	fc.AddEvent(z5r.CardFoundInEvent, "old", now.Add(-time.Hour))
+
+	po := z5r.NewPassageOpener(c, entity.In)
+	defer po.Close()
+
+	fc.AddEvent(z5r.CardFoundInEvent, "a", now)
+	fc.AddEvent(z5r.CardFoundOutEvent, "out", now)
+	fc.AddEvent(0, "", now)
+	fc.AddEvent(z5r.CardNotFoundInEvent, "b", now)
+	fc.AddEvent(z5r.CardDeniedInEvent, "", now)
+	fc.AddEvent(z5r.CardDeniedInEvent, "c", now)
+
+	startFake(t, fc)
+
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case r := <-po.CredentialReads():
+			if r.Type != entity.Card || r.Value != want {
+				t.Fatalf("got credential read %s %s, want card %s", r.Type, r.Value, want)
+			}
+			if r.Time.Unix() != now.Unix() {
+				t.Errorf("got credential read time %s, want %s", r.Time, now)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("no credential read of card %s", want)
+		}
+	}
+
+	waitFor(t, "events acceptance", func() bool { return fc.PendingEvents() == 0 })
+
+	select {
+	case r := <-po.CredentialReads():
+		t.Fatalf("unexpected credential read of card %s", r.Value)
+	default:
+	}
+}
+
+func TestControllerEventsBackpressure(t *testing.T) {
+	c, fc := newTestController(t, testControllerConfig())
+
+	events := c.Subscribe(1)
+	defer c.Unsubscribe(events)
+
+	now := time.Now()
+
+	fc.AddEvent(z5r.CardFoundInEvent, "a", now)
+	fc.AddEvent(z5r.CardFoundInEvent, "b", now)
+
+	err := fc.Request()
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	if fc.PendingEvents() != 1 {
+		t.Fatalf("got %d pending events, want 1", fc.PendingEvents())
+	}
+
+	if e := <-events; e.Card != "a" {
+		t.Fatalf("got event of card %s, want a", e.Card)
+	}
+
+	err = fc.Request()
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	if fc.PendingEvents() != 0 {
+		t.Fatalf("got %d pending events, want 0", fc.PendingEvents())
+	}
+
+	if e := <-events; e.Card != "b" {
+		t.Fatalf("got event of card %s, want b", e.Card)
+	}
+}
+
+func TestControllerCards(t *testing.T) {
+	c, fc := newTestController(t, testControllerConfig())
+	startFake(t, fc)
+
+	err := c.AddCards([]z5r.Card{
+		{Card: "a", TZ: 255},
+		{Card: "b", TZ: 1},
+		{Card: "c", TZ: 255},
+	})
+	if err != nil {
+		t.Fatalf("add cards: %v", err)
+	}
+
+	err = c.DeleteCards([]string{"b"})
+	if err != nil {
+		t.Fatalf("delete cards: %v", err)
+	}
+
+	cs := fc.Cards()
+	if len(cs) != 2 || cs[0].Card != "a" || cs[0].TZ != 255 || cs[1].Card != "c" {
+		t.Fatalf("got cards %v, want a and c", cs)
+	}
+
+	err = c.ClearCards()
+	if err != nil {
+		t.Fatalf("clear cards: %v", err)
+	}
+
+	if cs := fc.Cards(); len(cs) != 0 {
+		t.Fatalf("got cards %v after clear", cs)
+	}
+}
+
+func TestServerUnauthorized(t *testing.T) {
+	cc := testControllerConfig()
+
+	s := z5r.NewServer()
+	c := s.AddController(cc)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	fc := z5rfake.NewController(ts.URL, cc.SN, cc.Username, "wrong")
+
+	err := fc.Request()
+	if !errors.Is(err, z5rfake.ErrUnauthorized) {
+		t.Fatalf("got error %v, want %v", err, z5rfake.ErrUnauthorized)
+	}
+
+	if !errors.Is(c.Ping(), z5r.ErrNotConnected) {
+		t.Error("unauthorized controller is connected")
+	}
+
+	err = z5rfake.NewController(ts.URL, cc.SN+1, cc.Username, cc.Password).Request()
+	if err == nil {
+		t.Error("request of unknown controller succeeded")
+	}
+}
+
+func TestControllerCommandTimeout(t *testing.T) {
+	cc := testControllerConfig()
+	cc.CommandTimeout = 100 * time.Millisecond
+
+	c, fc := newTestController(t, cc)
+
+	err := c.OpenDoor(entity.In)
+	if !errors.Is(err, z5r.ErrNotConnected) {
+		t.Fatalf("got error %v of command not sent, want %v", err, z5r.ErrNotConnected)
+	}
+
+	fc.DropResults(true)
+	startFake(t, fc)
+
+	err = c.OpenDoor(entity.In)
+	if err == nil || errors.Is(err, z5r.ErrNotConnected) {
+		t.Fatalf("got error %v of command without result", err)
+	}
+
+	if len(fc.Opens()) != 1 {
+		t.Fatalf("got %d opens, want 1", len(fc.Opens()))
+	}
+}
+
+func TestPassageOpenersShareController(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	addr := l.Addr().String()
+	l.Close()
+
+	config := func(d entity.Direction) passage.Config {
+		return passage.Config{
+			PassageID: string(d),
+			Address:   addr,
+			Direction: d,
+			Options: map[string]interface{}{
+				"sn":       testSN,
+				"username": "user",
+				"password": "pass",
+			},
+		}
+	}
+
+	in, err := passage.NewOpener(entity.Z5R, config(entity.In))
+	if err != nil {
+		t.Fatalf("create in opener: %v", err)
+	}
+
+	out, err := passage.NewOpener(entity.Z5R, config(entity.Out))
+	if err != nil {
+		in.(io.Closer).Close()
+		t.Fatalf("create out opener: %v", err)
+	}
+
+	fc := z5rfake.NewController("http://"+addr+"/", testSN, "user", "pass")
+	fc.Start(testRequestPeriod)
+
+	for _, o := range []passage.Opener{in, out} {
+		err = o.OpenPassage()
+		if err != nil {
+			t.Errorf("open passage: %v", err)
+		}
+	}
+
+	fc.Stop()
+
+	opens := fc.Opens()
+	if len(opens) != 2 || opens[0] != z5r.InDirection || opens[1] != z5r.OutDirection {
+		t.Errorf("got opens %v, want [%d %d]", opens, z5r.InDirection, z5r.OutDirection)
+	}
+
+	in.(io.Closer).Close()
+	out.(io.Closer).Close()
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("server is not stopped after openers close: %v", err)
+	}
+
+	l.Close()
+}
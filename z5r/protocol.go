@@ -0,0 +1,121 @@
+package z5r
+
+// Z5R-Web JSON protocol. Controller periodically POSTs request with its
+// messages to server and gets response with replies to them and commands for
+// it. Controller reports command results with the command IDs in its next
+// request. Controllers are told apart by serial number.
+
+const ControllerType = "Z5RWEB"
+
+// Operations of controller messages.
+const (
+	PowerOnOperation     = "power_on"
+	PingOperation        = "ping"
+	EventsOperation      = "events"
+	CheckAccessOperation = "check_access"
+)
+
+// Operations of server messages.
+const (
+	SetActiveOperation  = "set_active"
+	OpenDoorOperation   = "open_door"
+	SetModeOperation    = "set_mode"
+	AddCardsOperation   = "add_cards"
+	DelCardsOperation   = "del_cards"
+	ClearCardsOperation = "clear_cards"
+)
+
+// Door directions of open_door operation.
+const (
+	InDirection  = 0
+	OutDirection = 1
+)
+
+// Mode is controller access mode.
+type Mode int
+
+const (
+	// NormalMode lets in by cards and commands.
+	NormalMode Mode = 0
+	// BlockMode doesn't let in anyone.
+	BlockMode Mode = 1
+	// FreeMode keeps door unlocked.
+	FreeMode Mode = 2
+)
+
+// Event codes with card, even codes are of entry reader and odd ones are of
+// exit reader.
+const (
+	CardNotFoundInEvent  = 2
+	CardNotFoundOutEvent = 3
+	CardFoundInEvent     = 4
+	CardFoundOutEvent    = 5
+	CardDeniedInEvent    = 6
+	CardDeniedOutEvent   = 7
+)
+
+// TimeLayout is layout of controller local time.
+const TimeLayout = "2006-01-02 15:04:05"
+
+// Card is card of controller card list. TZ is bit mask of controller time
+// zones card is valid in.
+type Card struct {
+	Card  string `json:"card"`
+	Flags int    `json:"flags"`
+	TZ    int    `json:"tz"`
+}
+
+// Event is controller event log record.
+type Event struct {
+	Event int    `json:"event"`
+	Card  string `json:"card,omitempty"`
+	Time  string `json:"time"`
+	Flag  int    `json:"flag"`
+}
+
+type Request struct {
+	Type     string           `json:"type"`
+	SN       int              `json:"sn"`
+	Messages []RequestMessage `json:"messages"`
+}
+
+// RequestMessage is controller message. It is result of server command with
+// its ID if operation is empty. Active and Mode are for power_on and ping,
+// Events are for events, Card and Reader are for check_access.
+type RequestMessage struct {
+	ID           int64   `json:"id"`
+	Operation    string  `json:"operation,omitempty"`
+	Success      *int    `json:"success,omitempty"`
+	FW           string  `json:"fw,omitempty"`
+	ConnFW       string  `json:"conn_fw,omitempty"`
+	ControllerIP string  `json:"controller_ip,omitempty"`
+	Active       *int    `json:"active,omitempty"`
+	Mode         *Mode   `json:"mode,omitempty"`
+	Events       []Event `json:"events,omitempty"`
+	Card         string  `json:"card,omitempty"`
+	Reader       int     `json:"reader,omitempty"`
+}
+
+// Response is server response. Interval is seconds till next controller
+// request.
+type Response struct {
+	Date     string            `json:"date"`
+	Interval int               `json:"interval"`
+	Messages []ResponseMessage `json:"messages"`
+}
+
+// ResponseMessage is reply to controller message with its ID or server
+// command. Active and Online are for set_active, EventsSuccess is count of
+// accepted events, Granted is for check_access, Direction is for open_door,
+// Mode is for set_mode, Cards are for add_cards and del_cards.
+type ResponseMessage struct {
+	ID            int64  `json:"id"`
+	Operation     string `json:"operation"`
+	Active        *int   `json:"active,omitempty"`
+	Online        *int   `json:"online,omitempty"`
+	EventsSuccess *int   `json:"events_success,omitempty"`
+	Granted       *int   `json:"granted,omitempty"`
+	Direction     *int   `json:"direction,omitempty"`
+	Mode          *Mode  `json:"mode,omitempty"`
+	Cards         []Card `json:"cards,omitempty"`
+}
@@ -0,0 +1,377 @@
+package z5r
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bennyharvey/soma/entity"
+)
+
+var ErrNotConnected = errors.New("controller is not connected")
+
+// missedRequests is how many controller requests may be missed before it is
+// considered not connected.
+const missedRequests = 3
+
+// Server is HTTP endpoint Z5R-Web controllers post their requests to.
+// Requests of controllers not added to server are rejected.
+type Server struct {
+	controllers map[int]*Controller
+	mx          sync.Mutex
+
+	log *logrus.Entry
+}
+
+func NewServer() *Server {
+	return &Server{
+		controllers: map[int]*Controller{},
+		log:         logrus.WithField("subsystem", "z5r_server"),
+	}
+}
+
+// ControllerConfig is controller settings. Controller must authorize its
+// requests with basic auth if username is not empty. Interval is time between
+// controller requests, commands fail if they are not done within command
+// timeout.
+type ControllerConfig struct {
+	SN             int
+	Username       string
+	Password       string
+	Interval       time.Duration
+	CommandTimeout time.Duration
+}
+
+// AddController adds controller or returns already added one with the same
+// serial number, which keeps its config. Controller is removed when it is
+// removed as many times as added.
+func (s *Server) AddController(cc ControllerConfig) *Controller {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	c, exists := s.controllers[cc.SN]
+	if !exists {
+		c = newController(cc)
+		s.controllers[cc.SN] = c
+	}
+
+	c.refs++
+
+	return c
+}
+
+// RemoveController removes controller and returns whether server has no
+// controllers left.
+func (s *Server) RemoveController(sn int) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	c, exists := s.controllers[sn]
+	if !exists {
+		return false
+	}
+
+	c.refs--
+	if c.refs > 0 {
+		return false
+	}
+
+	delete(s.controllers, sn)
+
+	return len(s.controllers) == 0
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.log.WithError(err).Warn("failed to JSON decode controller request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log := s.log.WithField("sn", req.SN)
+
+	s.mx.Lock()
+	c, exists := s.controllers[req.SN]
+	s.mx.Unlock()
+
+	if !exists {
+		log.Warn("request of unknown controller")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if c.config.Username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != c.config.Username || password != c.config.Password {
+			log.Warn("controller request is unauthorized")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(c.handle(req.Messages))
+	if err != nil {
+		log.WithError(err).Error("failed to write response")
+	}
+}
+
+// Controller is Z5R-Web controller connected to server. Commands are sent in
+// reply to the next controller request.
+type Controller struct {
+	config ControllerConfig
+	refs   int
+
+	lastSeen      time.Time
+	lastCommandID int64
+	commands      []ResponseMessage
+	results       map[int64]chan error
+	subscribers   []chan Event
+	mx            sync.Mutex
+}
+
+func newController(cc ControllerConfig) *Controller {
+	return &Controller{
+		config:  cc,
+		results: map[int64]chan error{},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// handle handles controller messages and returns response with replies and
+// pending commands. Events are accepted while all subscribers have room for
+// them, controller sends the rest again later.
+func (c *Controller) handle(ms []RequestMessage) Response {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.lastSeen = time.Now()
+
+	resp := Response{
+		Date:     c.lastSeen.Format(TimeLayout),
+		Interval: int(c.config.Interval / time.Second),
+		Messages: []ResponseMessage{},
+	}
+
+	if resp.Interval < 1 {
+		resp.Interval = 1
+	}
+
+	for _, m := range ms {
+		switch m.Operation {
+		case "":
+			result, exists := c.results[m.ID]
+			if !exists || m.Success == nil {
+				continue
+			}
+
+			delete(c.results, m.ID)
+
+			if *m.Success == 1 {
+				result <- nil
+			} else {
+				result <- errors.New("command failed")
+			}
+
+		case PowerOnOperation:
+			resp.Messages = append(resp.Messages, ResponseMessage{
+				ID:        m.ID,
+				Operation: SetActiveOperation,
+				Active:    intPtr(1),
+				Online:    intPtr(0),
+			})
+
+		case EventsOperation:
+			resp.Messages = append(resp.Messages, ResponseMessage{
+				ID:            m.ID,
+				Operation:     EventsOperation,
+				EventsSuccess: intPtr(c.acceptEvents(m.Events)),
+			})
+
+		case CheckAccessOperation:
+			resp.Messages = append(resp.Messages, ResponseMessage{
+				ID:        m.ID,
+				Operation: CheckAccessOperation,
+				Granted:   intPtr(0),
+			})
+		}
+	}
+
+	resp.Messages = append(resp.Messages, c.commands...)
+	c.commands = nil
+
+	return resp
+}
+
+// acceptEvents passes events to subscribers and returns how many are passed.
+// It is called with c.mx locked, so subscribers buffers only get more room
+// between checking and sending.
+func (c *Controller) acceptEvents(es []Event) int {
+	for i, e := range es {
+		for _, s := range c.subscribers {
+			if len(s) == cap(s) {
+				return i
+			}
+		}
+
+		for _, s := range c.subscribers {
+			s <- e
+		}
+	}
+
+	return len(es)
+}
+
+// Subscribe returns channel of controller events with buffer size.
+func (c *Controller) Subscribe(size int) <-chan Event {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	s := make(chan Event, size)
+	c.subscribers = append(c.subscribers, s)
+
+	return s
+}
+
+// Unsubscribe stops sending events to channel and closes it.
+func (c *Controller) Unsubscribe(events <-chan Event) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for i, s := range c.subscribers {
+		if s == events {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			close(s)
+			return
+		}
+	}
+}
+
+// command queues command and waits for its result.
+func (c *Controller) command(m ResponseMessage) error {
+	result := make(chan error, 1)
+
+	c.mx.Lock()
+	c.lastCommandID++
+	m.ID = c.lastCommandID
+	c.commands = append(c.commands, m)
+	c.results[m.ID] = result
+	c.mx.Unlock()
+
+	timer := time.NewTimer(c.config.CommandTimeout)
+	defer timer.Stop()
+
+	var err error
+
+	select {
+	case err = <-result:
+	case <-timer.C:
+		err = c.cancelCommand(m.ID, result)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.Operation, err)
+	}
+
+	return nil
+}
+
+// cancelCommand removes timed out command. Command result may be received
+// before removal.
+func (c *Controller) cancelCommand(id int64, result chan error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	delete(c.results, id)
+
+	for i, cm := range c.commands {
+		if cm.ID == id {
+			c.commands = append(c.commands[:i], c.commands[i+1:]...)
+			return ErrNotConnected
+		}
+	}
+
+	select {
+	case err := <-result:
+		return err
+	default:
+	}
+
+	return errors.New("no result")
+}
+
+func (c *Controller) OpenDoor(d entity.Direction) error {
+	direction := InDirection
+	if d == entity.Out {
+		direction = OutDirection
+	}
+
+	return c.command(ResponseMessage{
+		Operation: OpenDoorOperation,
+		Direction: &direction,
+	})
+}
+
+func (c *Controller) SetMode(m Mode) error {
+	return c.command(ResponseMessage{
+		Operation: SetModeOperation,
+		Mode:      &m,
+	})
+}
+
+// AddCards adds or updates cards of controller card list.
+func (c *Controller) AddCards(cs []Card) error {
+	return c.command(ResponseMessage{
+		Operation: AddCardsOperation,
+		Cards:     cs,
+	})
+}
+
+// DeleteCards deletes cards from controller card list.
+func (c *Controller) DeleteCards(cards []string) error {
+	cs := make([]Card, 0, len(cards))
+	for _, card := range cards {
+		cs = append(cs, Card{Card: card})
+	}
+
+	return c.command(ResponseMessage{
+		Operation: DelCardsOperation,
+		Cards:     cs,
+	})
+}
+
+// ClearCards deletes all cards from controller card list.
+func (c *Controller) ClearCards() error {
+	return c.command(ResponseMessage{
+		Operation: ClearCardsOperation,
+	})
+}
+
+// Ping returns ErrNotConnected if controller missed its requests.
+func (c *Controller) Ping() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.lastSeen.IsZero() || time.Since(c.lastSeen) > missedRequests*c.config.Interval {
+		return ErrNotConnected
+	}
+
+	return nil
+}
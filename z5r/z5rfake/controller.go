@@ -0,0 +1,325 @@
+// Package z5rfake implements fake Z5R-Web controller posting its requests to
+// z5r server. It is used to try z5r server without real controller, e.g.
+// served by httptest.NewServer.
+package z5rfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bennyharvey/soma/z5r"
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Controller is fake controller. It powers on with its first request, sends
+// at most events batch of pending events per request and keeps events until
+// server accepts them. Doors are not opened in block mode.
+type Controller struct {
+	url      string
+	sn       int
+	username string
+	password string
+	client   *http.Client
+
+	powered     bool
+	active      bool
+	lastID      int64
+	eventsBatch int
+	dropResults bool
+
+	mode    z5r.Mode
+	opens   []int
+	cards   map[string]z5r.Card
+	events  []z5r.Event
+	results []z5r.RequestMessage
+	mx      sync.Mutex
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewController(url string, sn int, username, password string) *Controller {
+	return &Controller{
+		url:         url,
+		sn:          sn,
+		username:    username,
+		password:    password,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		eventsBatch: 10,
+		cards:       map[string]z5r.Card{},
+	}
+}
+
+// SetEventsBatch sets max events count per request.
+func (c *Controller) SetEventsBatch(n int) {
+	c.mx.Lock()
+	c.eventsBatch = n
+	c.mx.Unlock()
+}
+
+// DropResults makes controller execute commands without reporting results.
+func (c *Controller) DropResults(drop bool) {
+	c.mx.Lock()
+	c.dropResults = drop
+	c.mx.Unlock()
+}
+
+// AddEvent logs event with code and card at time.
+func (c *Controller) AddEvent(code int, card string, t time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.events = append(c.events, z5r.Event{
+		Event: code,
+		Card:  card,
+		Time:  t.Format(z5r.TimeLayout),
+	})
+}
+
+// PendingEvents returns count of events not accepted by server.
+func (c *Controller) PendingEvents() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.events)
+}
+
+// Active returns whether server activated controller.
+func (c *Controller) Active() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.active
+}
+
+// Opens returns directions of door openings.
+func (c *Controller) Opens() []int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]int{}, c.opens...)
+}
+
+func (c *Controller) Mode() z5r.Mode {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.mode
+}
+
+// Cards returns card list sorted by card.
+func (c *Controller) Cards() []z5r.Card {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	cs := make([]z5r.Card, 0, len(c.cards))
+	for _, card := range c.cards {
+		cs = append(cs, card)
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Card < cs[j].Card
+	})
+
+	return cs
+}
+
+// Start makes requests with period until Stop.
+func (c *Controller) Start(period time.Duration) {
+	c.stop = make(chan struct{})
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		for {
+			_ = c.Request()
+
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *Controller) Stop() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+func (c *Controller) nextID() int64 {
+	c.lastID++
+	return c.lastID
+}
+
+// Request posts controller messages and handles response.
+func (c *Controller) Request() error {
+	c.mx.Lock()
+
+	req := z5r.Request{
+		Type:     z5r.ControllerType,
+		SN:       c.sn,
+		Messages: c.results,
+	}
+
+	c.results = nil
+
+	mode := c.mode
+
+	if !c.powered {
+		req.Messages = append(req.Messages, z5r.RequestMessage{
+			ID:        c.nextID(),
+			Operation: z5r.PowerOnOperation,
+			FW:        "fake",
+			Mode:      &mode,
+		})
+	}
+
+	events := c.events
+	if len(events) > c.eventsBatch {
+		events = events[:c.eventsBatch]
+	}
+
+	if len(events) > 0 {
+		req.Messages = append(req.Messages, z5r.RequestMessage{
+			ID:        c.nextID(),
+			Operation: z5r.EventsOperation,
+			Events:    events,
+		})
+	}
+
+	if len(req.Messages) == 0 {
+		req.Messages = append(req.Messages, z5r.RequestMessage{
+			ID:        c.nextID(),
+			Operation: z5r.PingOperation,
+			Mode:      &mode,
+		})
+	}
+
+	c.mx.Unlock()
+
+	resp, err := c.post(req)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.powered = true
+
+	for _, m := range resp.Messages {
+		c.handle(m)
+	}
+
+	return nil
+}
+
+func (c *Controller) post(req z5r.Request) (z5r.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return z5r.Response{}, fmt.Errorf("JSON marshal request: %w", err)
+	}
+
+	hr, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return z5r.Response{}, fmt.Errorf("create request: %w", err)
+	}
+
+	hr.Header.Set("Content-Type", "application/json")
+
+	if c.username != "" {
+		hr.SetBasicAuth(c.username, c.password)
+	}
+
+	hResp, err := c.client.Do(hr)
+	if err != nil {
+		return z5r.Response{}, err
+	}
+
+	defer hResp.Body.Close()
+
+	switch hResp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return z5r.Response{}, ErrUnauthorized
+	default:
+		return z5r.Response{}, fmt.Errorf("unexpected status code %d", hResp.StatusCode)
+	}
+
+	var resp z5r.Response
+
+	err = json.NewDecoder(hResp.Body).Decode(&resp)
+	if err != nil {
+		return z5r.Response{}, fmt.Errorf("JSON decode response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// handle handles server message with c.mx locked.
+func (c *Controller) handle(m z5r.ResponseMessage) {
+	success := 1
+
+	switch m.Operation {
+	case z5r.SetActiveOperation:
+		c.active = m.Active != nil && *m.Active == 1
+		return
+
+	case z5r.EventsOperation:
+		if m.EventsSuccess != nil {
+			c.events = c.events[*m.EventsSuccess:]
+		}
+		return
+
+	case z5r.CheckAccessOperation:
+		return
+
+	case z5r.OpenDoorOperation:
+		if m.Direction == nil || c.mode == z5r.BlockMode {
+			success = 0
+		} else {
+			c.opens = append(c.opens, *m.Direction)
+		}
+
+	case z5r.SetModeOperation:
+		if m.Mode == nil {
+			success = 0
+		} else {
+			c.mode = *m.Mode
+		}
+
+	case z5r.AddCardsOperation:
+		for _, card := range m.Cards {
+			c.cards[card.Card] = card
+		}
+
+	case z5r.DelCardsOperation:
+		for _, card := range m.Cards {
+			delete(c.cards, card.Card)
+		}
+
+	case z5r.ClearCardsOperation:
+		c.cards = map[string]z5r.Card{}
+
+	default:
+		success = 0
+	}
+
+	if c.dropResults {
+		return
+	}
+
+	c.results = append(c.results, z5r.RequestMessage{
+		ID:      m.ID,
+		Success: &success,
+	})
+}